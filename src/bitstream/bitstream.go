@@ -0,0 +1,135 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package bitstream
+
+import "fmt"
+
+// Writer appends bits, most significant bit first.
+type Writer struct {
+	octets   []byte
+	bitCount int
+}
+
+func NewWriter() *Writer {
+	return &Writer{}
+}
+
+// WriteBits writes the count lowest bits of value.
+func (w *Writer) WriteBits(value uint64, count int) error {
+	if count < 0 || count > 64 {
+		return fmt.Errorf("can not write %d bits", count)
+	}
+	if count < 64 && value >= uint64(1)<<uint(count) {
+		return fmt.Errorf("%d does not fit in %d bits", value, count)
+	}
+	for bit := count - 1; bit >= 0; bit-- {
+		if w.bitCount%8 == 0 {
+			w.octets = append(w.octets, 0)
+		}
+		if value&(uint64(1)<<uint(bit)) != 0 {
+			w.octets[len(w.octets)-1] |= 0x80 >> uint(w.bitCount%8)
+		}
+		w.bitCount++
+	}
+	return nil
+}
+
+func (w *Writer) WriteBool(value bool) {
+	if value {
+		w.WriteBits(1, 1)
+	} else {
+		w.WriteBits(0, 1)
+	}
+}
+
+// BitCount is the number of bits written so far.
+func (w *Writer) BitCount() int {
+	return w.bitCount
+}
+
+// Octets returns the written bits. The last octet is padded with zero bits.
+func (w *Writer) Octets() []byte {
+	return w.octets
+}
+
+// Reader reads bits written by Writer.
+type Reader struct {
+	octets      []byte
+	bitPosition int
+	bitCount    int
+}
+
+func NewReader(octets []byte) *Reader {
+	return &Reader{octets: octets, bitCount: len(octets) * 8}
+}
+
+// NewReaderWithBitCount reads at most bitCount bits from octets.
+func NewReaderWithBitCount(octets []byte, bitCount int) *Reader {
+	if bitCount > len(octets)*8 {
+		bitCount = len(octets) * 8
+	}
+	return &Reader{octets: octets, bitCount: bitCount}
+}
+
+func (r *Reader) ReadBits(count int) (uint64, error) {
+	if count < 0 || count > 64 {
+		return 0, fmt.Errorf("can not read %d bits", count)
+	}
+	if count > r.BitsLeft() {
+		return 0, fmt.Errorf("read %d bits, but only %d left", count, r.BitsLeft())
+	}
+	var value uint64
+	for index := 0; index < count; index++ {
+		octet := r.octets[r.bitPosition/8]
+		bit := (octet >> uint(7-r.bitPosition%8)) & 1
+		value = value<<1 | uint64(bit)
+		r.bitPosition++
+	}
+	return value, nil
+}
+
+func (r *Reader) ReadBool() (bool, error) {
+	bit, err := r.ReadBits(1)
+	return bit == 1, err
+}
+
+func (r *Reader) BitsLeft() int {
+	return r.bitCount - r.bitPosition
+}
+
+// IsPadding checks that the bits left are only the zero padding of the last octet.
+func (r *Reader) IsPadding() bool {
+	if r.BitsLeft() >= 8 {
+		return false
+	}
+	for position := r.bitPosition; position < r.bitCount; position++ {
+		if (r.octets[position/8]>>uint(7-position%8))&1 != 0 {
+			return false
+		}
+	}
+	return true
+}
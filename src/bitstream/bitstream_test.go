@@ -0,0 +1,101 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package bitstream
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestWriteBits(t *testing.T) {
+	writer := NewWriter()
+	writer.WriteBits(5, 3)
+	writer.WriteBool(true)
+	writer.WriteBits(0xabc, 12)
+	writer.WriteBits(1, 1)
+
+	if writer.BitCount() != 17 {
+		t.Errorf("wrong bit count %d", writer.BitCount())
+	}
+
+	expected := []byte{0xba, 0xbc, 0x80}
+	if !bytes.Equal(writer.Octets(), expected) {
+		t.Errorf("wrong octets %x", writer.Octets())
+	}
+
+	if writer.WriteBits(8, 3) == nil {
+		t.Errorf("value that does not fit should be reported")
+	}
+}
+
+func TestRandomRoundTrip(t *testing.T) {
+	random := rand.New(rand.NewSource(42))
+	for iteration := 0; iteration < 100; iteration++ {
+		var counts []int
+		var values []uint64
+		writer := NewWriter()
+		for index := 0; index < 50; index++ {
+			count := random.Intn(65)
+			value := random.Uint64()
+			if count < 64 {
+				value &= uint64(1)<<uint(count) - 1
+			}
+			if err := writer.WriteBits(value, count); err != nil {
+				t.Fatal(err)
+			}
+			counts = append(counts, count)
+			values = append(values, value)
+		}
+
+		reader := NewReaderWithBitCount(writer.Octets(), writer.BitCount())
+		for index, count := range counts {
+			value, err := reader.ReadBits(count)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if value != values[index] {
+				t.Fatalf("read %x, expected %x (%d bits)", value, values[index], count)
+			}
+		}
+		if reader.BitsLeft() != 0 {
+			t.Errorf("%d bits left", reader.BitsLeft())
+		}
+	}
+}
+
+func TestReadPastEnd(t *testing.T) {
+	reader := NewReader([]byte{0x80})
+	if _, err := reader.ReadBits(9); err == nil {
+		t.Errorf("reading past the end should be reported")
+	}
+
+	first, _ := reader.ReadBits(1)
+	if first != 1 || !reader.IsPadding() {
+		t.Errorf("the rest should be padding")
+	}
+}
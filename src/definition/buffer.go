@@ -26,13 +26,18 @@ SOFTWARE.
 
 package definition
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/piot/scrawl-go/src/token"
+)
 
 type Buffer struct {
-	name   string
-	meta   MetaData
-	fields []*Field
-	id     BufferTypeIndex
+	name     string
+	meta     MetaData
+	fields   []*Field
+	id       BufferTypeIndex
+	position token.Position
 }
 
 func NewBuffer(id BufferTypeIndex, name string, meta MetaData, fields []*Field) *Buffer {
@@ -62,3 +67,12 @@ func (e *Buffer) String() string {
 
 	return s
 }
+
+func (e *Buffer) SetPosition(position token.Position) {
+	e.position = position
+}
+
+// Position is where the definition starts in the source, or the zero position if unknown.
+func (e *Buffer) Position() token.Position {
+	return e.position
+}
@@ -0,0 +1,214 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package definition
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// WireMetaDataKeys are the only meta data keys that take part in the canonical form.
+// All other meta data is considered to be for tooling only and can change freely.
+var WireMetaDataKeys = []string{"bits", "capacity", "max", "min", "precision", "range"}
+
+type CanonicalDefinition struct {
+	kind   DefinitionKind
+	name   string
+	octets []byte
+}
+
+func (c *CanonicalDefinition) Kind() DefinitionKind {
+	return c.kind
+}
+
+func (c *CanonicalDefinition) Name() string {
+	return c.name
+}
+
+func (c *CanonicalDefinition) Octets() []byte {
+	return c.octets
+}
+
+func (c *CanonicalDefinition) String() string {
+	return fmt.Sprintf("[canonical %v '%v']", c.kind, c.name)
+}
+
+type canonicalWriter struct {
+	builder strings.Builder
+}
+
+func (w *canonicalWriter) line(format string, a ...interface{}) {
+	fmt.Fprintf(&w.builder, format, a...)
+	w.builder.WriteString("\n")
+}
+
+func (w *canonicalWriter) meta(meta MetaData) {
+	for _, key := range WireMetaDataKeys {
+		value, exists := meta.Values[key]
+		if !exists {
+			continue
+		}
+		w.line("meta %s %q", key, value)
+	}
+}
+
+func (w *canonicalWriter) fields(fields []*Field) {
+	for _, field := range fields {
+		w.line("field %d %s %s", field.Index(), field.Name(), field.FieldType())
+		w.meta(field.MetaData())
+	}
+}
+
+func (w *canonicalWriter) octets() []byte {
+	return []byte(w.builder.String())
+}
+
+func canonicalComponentDataType(c *ComponentDataType) []byte {
+	w := &canonicalWriter{}
+	w.line("component %s %d", c.Name(), c.Index())
+	if c.BaseName() != "" {
+		w.line("extends %s", c.BaseName())
+	}
+	w.meta(c.Meta())
+	w.fields(c.Fields())
+	return w.octets()
+}
+
+func canonicalUserType(u *UserType) []byte {
+	w := &canonicalWriter{}
+	w.line("type %s", u.TypeName())
+	w.fields(u.Fields())
+	return w.octets()
+}
+
+func canonicalEvent(e *Event) []byte {
+	w := &canonicalWriter{}
+	w.line("event %s %d", e.Name(), e.TypeIndex())
+	w.meta(e.Meta())
+	w.fields(e.Fields())
+	return w.octets()
+}
+
+func canonicalCommand(c *Command) []byte {
+	w := &canonicalWriter{}
+	w.line("command %s %d", c.Name(), c.TypeIndex())
+	w.meta(c.Meta())
+	w.fields(c.Fields())
+	return w.octets()
+}
+
+func canonicalBuffer(b *Buffer) []byte {
+	w := &canonicalWriter{}
+	w.line("buffer %s %d", b.Name(), b.TypeIndex())
+	w.meta(b.Meta())
+	w.fields(b.Fields())
+	return w.octets()
+}
+
+func canonicalEnum(e *Enum) []byte {
+	w := &canonicalWriter{}
+	w.line("enum %s", e.Name())
+	constants := make([]*EnumConstant, len(e.Constants()))
+	copy(constants, e.Constants())
+	sort.SliceStable(constants, func(i, j int) bool {
+		if constants[i].Value() != constants[j].Value() {
+			return constants[i].Value() < constants[j].Value()
+		}
+		return constants[i].Name() < constants[j].Name()
+	})
+	for _, constant := range constants {
+		w.line("constant %s %d", constant.Name(), constant.Value())
+	}
+	return w.octets()
+}
+
+func canonicalArchetype(a *EntityArchetype) []byte {
+	w := &canonicalWriter{}
+	w.line("archetype %s %d", a.Name(), a.Index().Value())
+	w.meta(a.Meta())
+	for _, lod := range a.Lods() {
+		w.line("lod %d", lod.Level())
+		for _, item := range lod.Items() {
+			w.line("item %s", item.Name())
+			w.meta(item.Meta())
+		}
+	}
+	return w.octets()
+}
+
+// CanonicalDefinitions returns the canonical form of every definition, sorted by kind and name.
+// Declaration order only matters where it decides a wire index, and that index is part of the form.
+func (r *Root) CanonicalDefinitions() []*CanonicalDefinition {
+	var definitions []*CanonicalDefinition
+
+	add := func(kind DefinitionKind, name string, octets []byte) {
+		definitions = append(definitions, &CanonicalDefinition{kind: kind, name: name, octets: octets})
+	}
+
+	for _, component := range r.componentDataTypes {
+		add(DefinitionKindComponent, component.Name(), canonicalComponentDataType(component))
+	}
+	for _, userType := range r.userTypes {
+		add(DefinitionKindUserType, userType.TypeName(), canonicalUserType(userType))
+	}
+	for _, event := range r.events {
+		add(DefinitionKindEvent, event.Name(), canonicalEvent(event))
+	}
+	for _, command := range r.commands {
+		add(DefinitionKindCommand, command.Name(), canonicalCommand(command))
+	}
+	for _, buffer := range r.buffers {
+		add(DefinitionKindBuffer, buffer.Name(), canonicalBuffer(buffer))
+	}
+	for _, enum := range r.enums {
+		add(DefinitionKindEnum, enum.Name(), canonicalEnum(enum))
+	}
+	for _, archetype := range r.archetypes {
+		add(DefinitionKindArchetype, archetype.Name(), canonicalArchetype(archetype))
+	}
+
+	sort.SliceStable(definitions, func(i, j int) bool {
+		if definitions[i].kind != definitions[j].kind {
+			return definitions[i].kind < definitions[j].kind
+		}
+		return definitions[i].name < definitions[j].name
+	})
+
+	return definitions
+}
+
+// CanonicalOctets returns the formatting independent form of the whole root.
+func (r *Root) CanonicalOctets() []byte {
+	w := &canonicalWriter{}
+	w.line("namespace %s", r.namespace)
+	w.line("name %s", r.name)
+	for _, definition := range r.CanonicalDefinitions() {
+		w.builder.Write(definition.Octets())
+	}
+	return w.octets()
+}
@@ -26,13 +26,18 @@ SOFTWARE.
 
 package definition
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/piot/scrawl-go/src/token"
+)
 
 type Command struct {
-	name   string
-	meta   MetaData
-	fields []*Field
-	id     CommandTypeIndex
+	name     string
+	meta     MetaData
+	fields   []*Field
+	id       CommandTypeIndex
+	position token.Position
 }
 
 func NewCommand(id CommandTypeIndex, name string, meta MetaData, fields []*Field) *Command {
@@ -62,3 +67,12 @@ func (e *Command) String() string {
 
 	return s
 }
+
+func (e *Command) SetPosition(position token.Position) {
+	e.position = position
+}
+
+// Position is where the definition starts in the source, or the zero position if unknown.
+func (e *Command) Position() token.Position {
+	return e.position
+}
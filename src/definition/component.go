@@ -26,17 +26,65 @@ SOFTWARE.
 
 package definition
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/piot/scrawl-go/src/token"
+)
 
 type ComponentDataType struct {
-	name   string
-	index  uint8
-	fields []*Field
-	meta   MetaData
+	name      string
+	index     uint8
+	fields    []*Field
+	ownFields []*Field
+	meta      MetaData
+	baseName  string
+	base      *ComponentDataType
+	position  token.Position
 }
 
 func NewComponentDataType(name string, index uint8, fields []*Field, meta MetaData) *ComponentDataType {
-	return &ComponentDataType{name: name, index: index, fields: fields, meta: meta}
+	return &ComponentDataType{name: name, index: index, fields: fields, ownFields: fields, meta: meta}
+}
+
+func (c *ComponentDataType) SetBaseName(baseName string) {
+	c.baseName = baseName
+}
+
+// BaseName is the name of the component that is extended, or empty if there is none.
+func (c *ComponentDataType) BaseName() string {
+	return c.baseName
+}
+
+// Base returns the extended component, or nil if there is none.
+func (c *ComponentDataType) Base() *ComponentDataType {
+	return c.base
+}
+
+// Extend flattens the fields of base into this component. The base must already be
+// extended itself. The inherited fields come first and keep their indices.
+func (c *ComponentDataType) Extend(base *ComponentDataType) error {
+	baseFields := base.Fields()
+	for _, field := range c.ownFields {
+		for _, baseField := range baseFields {
+			if baseField.Name() == field.Name() {
+				return fmt.Errorf("field '%v' in component '%v' shadows field in base component '%v'", field.Name(), c.name, base.Name())
+			}
+		}
+	}
+
+	fields := make([]*Field, 0, len(baseFields)+len(c.ownFields))
+	fields = append(fields, baseFields...)
+	for _, field := range c.ownFields {
+		field.ForceNewIndex(len(fields))
+		fields = append(fields, field)
+	}
+
+	c.base = base
+	c.baseName = base.Name()
+	c.fields = fields
+
+	return nil
 }
 
 func (c *ComponentDataType) Name() string {
@@ -47,10 +95,16 @@ func (c *ComponentDataType) Index() uint8 {
 	return c.index
 }
 
+// Fields returns all fields, including the ones inherited from the base components.
 func (c *ComponentDataType) Fields() []*Field {
 	return c.fields
 }
 
+// OwnFields returns only the fields declared in this component.
+func (c *ComponentDataType) OwnFields() []*Field {
+	return c.ownFields
+}
+
 func (c *ComponentDataType) Meta() MetaData {
 	return c.meta
 }
@@ -65,3 +119,12 @@ func (c *ComponentDataType) String() string {
 
 	return s
 }
+
+func (c *ComponentDataType) SetPosition(position token.Position) {
+	c.position = position
+}
+
+// Position is where the definition starts in the source, or the zero position if unknown.
+func (c *ComponentDataType) Position() token.Position {
+	return c.position
+}
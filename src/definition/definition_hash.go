@@ -0,0 +1,96 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package definition
+
+import "fmt"
+
+type DefinitionKind uint8
+
+const (
+	DefinitionKindComponent DefinitionKind = iota
+	DefinitionKindUserType
+	DefinitionKindEvent
+	DefinitionKindCommand
+	DefinitionKindBuffer
+	DefinitionKindEnum
+	DefinitionKindArchetype
+)
+
+func (k DefinitionKind) String() string {
+	switch k {
+	case DefinitionKindComponent:
+		return "component"
+	case DefinitionKindUserType:
+		return "type"
+	case DefinitionKindEvent:
+		return "event"
+	case DefinitionKindCommand:
+		return "command"
+	case DefinitionKindBuffer:
+		return "buffer"
+	case DefinitionKindEnum:
+		return "enum"
+	case DefinitionKindArchetype:
+		return "archetype"
+	}
+	return fmt.Sprintf("unknown-kind-%d", uint8(k))
+}
+
+func ParseDefinitionKind(name string) (DefinitionKind, error) {
+	for kind := DefinitionKindComponent; kind <= DefinitionKindArchetype; kind++ {
+		if kind.String() == name {
+			return kind, nil
+		}
+	}
+	return DefinitionKindComponent, fmt.Errorf("unknown definition kind '%v'", name)
+}
+
+type DefinitionHash struct {
+	kind DefinitionKind
+	name string
+	hash Hash
+}
+
+func NewDefinitionHash(kind DefinitionKind, name string, hash Hash) *DefinitionHash {
+	return &DefinitionHash{kind: kind, name: name, hash: hash}
+}
+
+func (d *DefinitionHash) Kind() DefinitionKind {
+	return d.kind
+}
+
+func (d *DefinitionHash) Name() string {
+	return d.name
+}
+
+func (d *DefinitionHash) Hash() Hash {
+	return d.hash
+}
+
+func (d *DefinitionHash) String() string {
+	return fmt.Sprintf("[definitionhash %v '%v' %v]", d.kind, d.name, d.hash)
+}
@@ -29,6 +29,8 @@ package definition
 import (
 	"fmt"
 	"sort"
+
+	"github.com/piot/scrawl-go/src/token"
 )
 
 type EntityArchetype struct {
@@ -37,6 +39,8 @@ type EntityArchetype struct {
 	index        EntityIndex
 	lods         []*EntityArchetypeLOD
 	meta         MetaData
+	base         *EntityArchetype
+	position     token.Position
 }
 
 func NewEntityArchetype(name string, index EntityIndex, lods []*EntityArchetypeLOD, meta MetaData) *EntityArchetype {
@@ -68,7 +72,7 @@ func (c *EntityArchetype) Name() string {
 
 func (c *EntityArchetype) NewLod(lodLevel int, items []*EntityArchetypeItem) (*EntityArchetypeLOD, error) {
 	lastIndex := len(c.lods)
-	if lastIndex+1 != lodLevel {
+	if lastIndex != lodLevel {
 		return nil, fmt.Errorf("must add lods in order %v (%v)", lodLevel, lastIndex)
 	}
 
@@ -98,6 +102,67 @@ func (c *EntityArchetype) Lods() []*EntityArchetypeLOD {
 	return c.lods
 }
 
+func itemsNotIn(items []*EntityArchetypeItem, lod *EntityArchetypeLOD) []*EntityArchetypeItem {
+	var missing []*EntityArchetypeItem
+	for _, item := range items {
+		if lod.FindItem(item.Name()) == nil {
+			missing = append(missing, item)
+		}
+	}
+	return missing
+}
+
+// DroppedItems returns the items in lodLevel-1 that are not in lodLevel.
+func (c *EntityArchetype) DroppedItems(lodLevel int) []*EntityArchetypeItem {
+	if lodLevel <= 0 || lodLevel >= len(c.lods) {
+		return nil
+	}
+	return itemsNotIn(c.lods[lodLevel-1].Items(), c.lods[lodLevel])
+}
+
+// AddedItems returns the items in lodLevel that are not in lodLevel-1.
+func (c *EntityArchetype) AddedItems(lodLevel int) []*EntityArchetypeItem {
+	if lodLevel <= 0 || lodLevel >= len(c.lods) {
+		return nil
+	}
+	return itemsNotIn(c.lods[lodLevel].Items(), c.lods[lodLevel-1])
+}
+
+// ValidateLods checks that every level of detail is a subset of the one before it, and that
+// specified distances are increasing.
+func (c *EntityArchetype) ValidateLods() error {
+	for level := 1; level < len(c.lods); level++ {
+		added := c.AddedItems(level)
+		if len(added) != 0 {
+			return fmt.Errorf("archetype '%v' lod%d is not a subset of lod%d. It adds %v", c.name, level, level-1, added[0].Name())
+		}
+		previousDistance := c.lods[level-1].Distance()
+		distance := c.lods[level].Distance()
+		if previousDistance != 0 && distance != 0 && distance <= previousDistance {
+			return fmt.Errorf("archetype '%v' lod%d distance %d must be greater than %d", c.name, level, distance, previousDistance)
+		}
+	}
+	return nil
+}
+
 func (c *EntityArchetype) Meta() MetaData {
 	return c.meta
 }
+
+func (c *EntityArchetype) SetBase(base *EntityArchetype) {
+	c.base = base
+}
+
+// Base returns the extended archetype, or nil if there is none.
+func (c *EntityArchetype) Base() *EntityArchetype {
+	return c.base
+}
+
+func (c *EntityArchetype) SetPosition(position token.Position) {
+	c.position = position
+}
+
+// Position is where the definition starts in the source, or the zero position if unknown.
+func (c *EntityArchetype) Position() token.Position {
+	return c.position
+}
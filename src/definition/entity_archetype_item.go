@@ -51,6 +51,12 @@ func NewEntityArchetypeItemUsingComponentDataTypeReference(componentDataType *Co
 	return &EntityArchetypeItem{variant: ComponentTypeComponentReference, componentDataType: componentDataType, meta: meta}
 }
 
+func (c *EntityArchetypeItem) withIndex(index int) *EntityArchetypeItem {
+	copied := *c
+	copied.index = index
+	return &copied
+}
+
 func (c *EntityArchetypeItem) HasFieldReference() bool {
 	return c.variant == ComponentTypeField
 }
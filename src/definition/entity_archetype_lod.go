@@ -3,8 +3,12 @@ package definition
 import "fmt"
 
 type EntityArchetypeLOD struct {
-	lodLevel int
-	items    []*EntityArchetypeItem
+	lodLevel    int
+	items       []*EntityArchetypeItem
+	derivedFrom int
+	meta        MetaData
+	distance    int
+	rate        int
 }
 
 func (c *EntityArchetypeLOD) Level() int {
@@ -19,6 +23,60 @@ func (c *EntityArchetypeLOD) Items() []*EntityArchetypeItem {
 	return c.items
 }
 
+func (c *EntityArchetypeLOD) FindItem(name string) *EntityArchetypeItem {
+	for _, item := range c.items {
+		if item.Name() == name {
+			return item
+		}
+	}
+	return nil
+}
+
+func (c *EntityArchetypeLOD) Meta() MetaData {
+	return c.meta
+}
+
+// SetMeta sets the meta data and reads the 'distance' and 'rate' values from it.
+func (c *EntityArchetypeLOD) SetMeta(meta MetaData) error {
+	distance, distanceErr := meta.IntWithDefault("distance", 0)
+	if distanceErr != nil || distance < 0 {
+		return fmt.Errorf("lod%d has illegal distance '%v'", c.lodLevel, meta.Field("distance"))
+	}
+	rate, rateErr := meta.IntWithDefault("rate", 0)
+	if rateErr != nil || rate < 0 {
+		return fmt.Errorf("lod%d has illegal rate '%v'", c.lodLevel, meta.Field("rate"))
+	}
+	c.meta = meta
+	c.distance = distance
+	c.rate = rate
+	return nil
+}
+
+// Distance is the distance up to which this level of detail is used. Zero if not specified.
+func (c *EntityArchetypeLOD) Distance() int {
+	return c.distance
+}
+
+// Rate is the number of updates per second for this level of detail. Zero if not specified.
+func (c *EntityArchetypeLOD) Rate() int {
+	return c.rate
+}
+
+// IsSubsetOf checks that every item in this level of detail also exists in other.
+func (c *EntityArchetypeLOD) IsSubsetOf(other *EntityArchetypeLOD) bool {
+	for _, item := range c.items {
+		if other.FindItem(item.Name()) == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// DerivedFrom returns the level this level of detail was derived from, or -1 if it wasn't.
+func (c *EntityArchetypeLOD) DerivedFrom() int {
+	return c.derivedFrom
+}
+
 func (c *EntityArchetypeLOD) String() string {
 	var s string
 	s += fmt.Sprintf("[lod%d items:%d\n", c.lodLevel, len(c.items))
@@ -32,6 +90,69 @@ func (c *EntityArchetypeLOD) String() string {
 	return s
 }
 
+// NewEntityArchetypeLOD creates a level of detail with the items indexed in the order they are given.
 func NewEntityArchetypeLOD(lodLevel int, items []*EntityArchetypeItem) *EntityArchetypeLOD {
-	return &EntityArchetypeLOD{lodLevel: lodLevel, items: items}
+	var indexed []*EntityArchetypeItem
+	for index, item := range items {
+		indexed = append(indexed, item.withIndex(index))
+	}
+	return &EntityArchetypeLOD{lodLevel: lodLevel, items: indexed, derivedFrom: -1}
+}
+
+// NewEntityArchetypeLODExtending creates a level of detail with the inherited items, except the ones
+// named in without, followed by items.
+func NewEntityArchetypeLODExtending(lodLevel int, inherited []*EntityArchetypeItem, without []string,
+	items []*EntityArchetypeItem) (*EntityArchetypeLOD, error) {
+	for _, name := range without {
+		found := false
+		for _, item := range inherited {
+			if item.Name() == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("lod%d can not remove '%v', it is not inherited", lodLevel, name)
+		}
+	}
+
+	var expanded []*EntityArchetypeItem
+	for _, item := range inherited {
+		removed := false
+		for _, name := range without {
+			if item.Name() == name {
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			expanded = append(expanded, item.withIndex(len(expanded)))
+		}
+	}
+
+	for _, item := range items {
+		for _, existing := range expanded {
+			if existing.Name() == item.Name() {
+				return nil, fmt.Errorf("lod%d already has '%v'", lodLevel, item.Name())
+			}
+		}
+		expanded = append(expanded, item.withIndex(len(expanded)))
+	}
+
+	return &EntityArchetypeLOD{lodLevel: lodLevel, items: expanded, derivedFrom: -1}, nil
+}
+
+func NewEntityArchetypeLODDerived(lodLevel int, source *EntityArchetypeLOD, without []string,
+	items []*EntityArchetypeItem) (*EntityArchetypeLOD, error) {
+	if source.Level() >= lodLevel {
+		return nil, fmt.Errorf("lod%d can only be derived from a higher level of detail, not lod%d", lodLevel, source.Level())
+	}
+
+	lod, err := NewEntityArchetypeLODExtending(lodLevel, source.Items(), without, items)
+	if err != nil {
+		return nil, err
+	}
+	lod.derivedFrom = source.Level()
+
+	return lod, nil
 }
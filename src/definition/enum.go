@@ -26,11 +26,16 @@ SOFTWARE.
 
 package definition
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/piot/scrawl-go/src/token"
+)
 
 type Enum struct {
 	name      string
 	constants []*EnumConstant
+	position  token.Position
 }
 
 func NewEnum(name string, constants []*EnumConstant) *Enum {
@@ -45,6 +50,63 @@ func (c *Enum) Constants() []*EnumConstant {
 	return c.constants
 }
 
+// ValueRange returns the lowest and highest constant value.
+func (c *Enum) ValueRange() (int, int) {
+	if len(c.constants) == 0 {
+		return 0, 0
+	}
+	lowest := c.constants[0].Value()
+	highest := lowest
+	for _, constant := range c.constants[1:] {
+		if constant.Value() < lowest {
+			lowest = constant.Value()
+		}
+		if constant.Value() > highest {
+			highest = constant.Value()
+		}
+	}
+	return lowest, highest
+}
+
+// StorageType returns the smallest integer type that can hold all the constants.
+func (c *Enum) StorageType() PrimitiveType {
+	lowest, highest := c.ValueRange()
+	if lowest >= 0 {
+		switch {
+		case highest <= 0xff:
+			return PrimitiveUint8
+		case highest <= 0xffff:
+			return PrimitiveUint16
+		}
+		return PrimitiveUint32
+	}
+	switch {
+	case lowest >= -0x80 && highest <= 0x7f:
+		return PrimitiveInt8
+	case lowest >= -0x8000 && highest <= 0x7fff:
+		return PrimitiveInt16
+	}
+	return PrimitiveInt32
+}
+
+func (c *Enum) FindConstant(name string) *EnumConstant {
+	for _, constant := range c.constants {
+		if constant.Name() == name {
+			return constant
+		}
+	}
+	return nil
+}
+
+func (c *Enum) FindConstantByValue(value int) *EnumConstant {
+	for _, constant := range c.constants {
+		if constant.Value() == value {
+			return constant
+		}
+	}
+	return nil
+}
+
 func (c *Enum) String() string {
 	var s string
 	s += fmt.Sprintf("[enum '%v' constants:%d]\n", c.name, len(c.constants))
@@ -54,3 +116,12 @@ func (c *Enum) String() string {
 
 	return s
 }
+
+func (c *Enum) SetPosition(position token.Position) {
+	c.position = position
+}
+
+// Position is where the definition starts in the source, or the zero position if unknown.
+func (c *Enum) Position() token.Position {
+	return c.position
+}
@@ -26,13 +26,18 @@ SOFTWARE.
 
 package definition
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/piot/scrawl-go/src/token"
+)
 
 type Event struct {
-	id     EventTypeIndex
-	name   string
-	meta   MetaData
-	fields []*Field
+	id       EventTypeIndex
+	name     string
+	meta     MetaData
+	fields   []*Field
+	position token.Position
 }
 
 func NewEvent(id EventTypeIndex, name string, meta MetaData, fields []*Field) *Event {
@@ -62,3 +67,12 @@ func (e *Event) String() string {
 
 	return s
 }
+
+func (e *Event) SetPosition(position token.Position) {
+	e.position = position
+}
+
+// Position is where the definition starts in the source, or the zero position if unknown.
+func (e *Event) Position() token.Position {
+	return e.position
+}
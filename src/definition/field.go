@@ -26,13 +26,18 @@ SOFTWARE.
 
 package definition
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/piot/scrawl-go/src/token"
+)
 
 type Field struct {
 	index     int
 	name      string
 	fieldType string
 	metaData  MetaData
+	position  token.Position
 }
 
 func NewField(index int, name string, fieldType string, metaData MetaData) *Field {
@@ -59,9 +64,32 @@ func (c *Field) MetaData() MetaData {
 	return c.metaData
 }
 
+// Capacity is the maximum number of elements when the field is an array, set with
+// the 'capacity' meta data. It is zero for fields that are not arrays.
+func (c *Field) Capacity() int {
+	capacity, capacityErr := c.metaData.IntWithDefault("capacity", 0)
+	if capacityErr != nil {
+		return 0
+	}
+	return capacity
+}
+
+func (c *Field) IsArray() bool {
+	return c.Capacity() > 0
+}
+
 func (c *Field) String() string {
 	var s string
 	s += fmt.Sprintf("[field '%v' %v]", c.name, c.fieldType)
 
 	return s
 }
+
+func (c *Field) SetPosition(position token.Position) {
+	c.position = position
+}
+
+// Position is where the definition starts in the source, or the zero position if unknown.
+func (c *Field) Position() token.Position {
+	return c.position
+}
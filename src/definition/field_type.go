@@ -0,0 +1,117 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package definition
+
+import "fmt"
+
+type FieldTypeVariant uint8
+
+const (
+	FieldTypeUnknown FieldTypeVariant = iota
+	FieldTypePrimitive
+	FieldTypeEnum
+	FieldTypeUserType
+	FieldTypeComponent
+)
+
+func (v FieldTypeVariant) String() string {
+	switch v {
+	case FieldTypePrimitive:
+		return "primitive"
+	case FieldTypeEnum:
+		return "enum"
+	case FieldTypeUserType:
+		return "type"
+	case FieldTypeComponent:
+		return "component"
+	}
+	return "unknown"
+}
+
+// ResolvedFieldType is what a field type name refers to in a root.
+type ResolvedFieldType struct {
+	name      string
+	variant   FieldTypeVariant
+	primitive PrimitiveType
+	enum      *Enum
+	userType  *UserType
+	component *ComponentDataType
+}
+
+func (t *ResolvedFieldType) Name() string {
+	return t.name
+}
+
+func (t *ResolvedFieldType) Variant() FieldTypeVariant {
+	return t.variant
+}
+
+func (t *ResolvedFieldType) Primitive() PrimitiveType {
+	return t.primitive
+}
+
+func (t *ResolvedFieldType) Enum() *Enum {
+	return t.enum
+}
+
+func (t *ResolvedFieldType) UserType() *UserType {
+	return t.userType
+}
+
+func (t *ResolvedFieldType) ComponentDataType() *ComponentDataType {
+	return t.component
+}
+
+func (t *ResolvedFieldType) String() string {
+	return fmt.Sprintf("[fieldtype '%v' %d]", t.name, t.variant)
+}
+
+// ResolveFieldType looks up a field type name. Primitives are checked first, then enums,
+// user types and components. Unknown names are returned with the FieldTypeUnknown variant.
+func (r *Root) ResolveFieldType(name string) *ResolvedFieldType {
+	primitive, isPrimitive := LookupPrimitiveType(name)
+	if isPrimitive {
+		return &ResolvedFieldType{name: name, variant: FieldTypePrimitive, primitive: primitive}
+	}
+
+	enum := r.FindEnum(name)
+	if enum != nil {
+		return &ResolvedFieldType{name: name, variant: FieldTypeEnum, enum: enum}
+	}
+
+	userType := r.FindUserType(name)
+	if userType != nil {
+		return &ResolvedFieldType{name: name, variant: FieldTypeUserType, userType: userType}
+	}
+
+	component := r.FindComponentDataType(name)
+	if component != nil {
+		return &ResolvedFieldType{name: name, variant: FieldTypeComponent, component: component}
+	}
+
+	return &ResolvedFieldType{name: name, variant: FieldTypeUnknown}
+}
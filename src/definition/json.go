@@ -0,0 +1,252 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package definition
+
+import (
+	"encoding/json"
+
+	"github.com/piot/scrawl-go/src/token"
+)
+
+// JSONFormatVersion is increased every time the JSON representation changes in a way
+// that is not backwards compatible.
+const JSONFormatVersion = 1
+
+type jsonPosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+type jsonHash struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"value"`
+}
+
+type jsonDefinitionHash struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	Hash string `json:"hash"`
+}
+
+type jsonField struct {
+	Index    int               `json:"index"`
+	Name     string            `json:"name"`
+	Type     string            `json:"type"`
+	Kind     string            `json:"kind"`
+	Meta     map[string]string `json:"meta,omitempty"`
+	Position *jsonPosition     `json:"position,omitempty"`
+}
+
+type jsonEnumConstant struct {
+	Index int    `json:"index"`
+	Name  string `json:"name"`
+	Value int    `json:"value"`
+}
+
+type jsonEnum struct {
+	Name      string             `json:"name"`
+	Constants []jsonEnumConstant `json:"constants"`
+	Position  *jsonPosition      `json:"position,omitempty"`
+}
+
+type jsonUserType struct {
+	Name     string        `json:"name"`
+	Fields   []jsonField   `json:"fields"`
+	Position *jsonPosition `json:"position,omitempty"`
+}
+
+type jsonComponent struct {
+	Name     string            `json:"name"`
+	Index    int               `json:"index"`
+	Base     string            `json:"base,omitempty"`
+	Fields   []jsonField       `json:"fields"`
+	Meta     map[string]string `json:"meta,omitempty"`
+	Position *jsonPosition     `json:"position,omitempty"`
+}
+
+type jsonMessage struct {
+	Name     string            `json:"name"`
+	Index    int               `json:"index"`
+	Fields   []jsonField       `json:"fields"`
+	Meta     map[string]string `json:"meta,omitempty"`
+	Position *jsonPosition     `json:"position,omitempty"`
+}
+
+type jsonItem struct {
+	Index          int               `json:"index"`
+	Name           string            `json:"name"`
+	Kind           string            `json:"kind"`
+	ComponentIndex *int              `json:"componentIndex,omitempty"`
+	Meta           map[string]string `json:"meta,omitempty"`
+}
+
+type jsonLod struct {
+	Level       int               `json:"level"`
+	DerivedFrom *int              `json:"derivedFrom,omitempty"`
+	Distance    int               `json:"distance,omitempty"`
+	Rate        int               `json:"rate,omitempty"`
+	Items       []jsonItem        `json:"items"`
+	Meta        map[string]string `json:"meta,omitempty"`
+}
+
+type jsonArchetype struct {
+	Name     string            `json:"name"`
+	Index    int               `json:"index"`
+	ID       int               `json:"id"`
+	Base     string            `json:"base,omitempty"`
+	Lods     []jsonLod         `json:"lods"`
+	Meta     map[string]string `json:"meta,omitempty"`
+	Position *jsonPosition     `json:"position,omitempty"`
+}
+
+type jsonRoot struct {
+	Format           string               `json:"format"`
+	Version          int                  `json:"version"`
+	Namespace        string               `json:"namespace,omitempty"`
+	Name             string               `json:"name,omitempty"`
+	Hash             jsonHash             `json:"hash"`
+	DefinitionHashes []jsonDefinitionHash `json:"definitionHashes"`
+	Enums            []jsonEnum           `json:"enums"`
+	UserTypes        []jsonUserType       `json:"types"`
+	Components       []jsonComponent      `json:"components"`
+	Events           []jsonMessage        `json:"events"`
+	Commands         []jsonMessage        `json:"commands"`
+	Buffers          []jsonMessage        `json:"buffers"`
+	Archetypes       []jsonArchetype      `json:"archetypes"`
+}
+
+const jsonFormatName = "scrawl-definition"
+
+func toJSONPosition(position token.Position) *jsonPosition {
+	if position.Line() == 0 {
+		return nil
+	}
+	return &jsonPosition{Line: position.Line(), Column: position.Column()}
+}
+
+func toJSONFields(root *Root, fields []*Field) []jsonField {
+	converted := []jsonField{}
+	for _, field := range fields {
+		converted = append(converted, jsonField{Index: field.Index(), Name: field.Name(), Type: field.FieldType(),
+			Kind: root.ResolveFieldType(field.FieldType()).Variant().String(), Meta: field.MetaData().Values,
+			Position: toJSONPosition(field.Position())})
+	}
+	return converted
+}
+
+func toJSONItems(items []*EntityArchetypeItem) []jsonItem {
+	converted := []jsonItem{}
+	for _, item := range items {
+		jsonItem := jsonItem{Index: item.Index(), Name: item.Name(), Kind: "field", Meta: item.Meta().Values}
+		if item.HasComponentReference() {
+			jsonItem.Kind = "component"
+			componentIndex := int(item.ComponentDataType().Index())
+			jsonItem.ComponentIndex = &componentIndex
+		}
+		converted = append(converted, jsonItem)
+	}
+	return converted
+}
+
+func toJSONArchetype(archetype *EntityArchetype) jsonArchetype {
+	converted := jsonArchetype{Name: archetype.Name(), Index: int(archetype.Index().Value()),
+		ID: int(archetype.ID().Value()), Lods: []jsonLod{}, Meta: archetype.Meta().Values,
+		Position: toJSONPosition(archetype.Position())}
+	if archetype.Base() != nil {
+		converted.Base = archetype.Base().Name()
+	}
+	for _, lod := range archetype.Lods() {
+		jsonLod := jsonLod{Level: lod.Level(), Distance: lod.Distance(), Rate: lod.Rate(),
+			Items: toJSONItems(lod.Items()), Meta: lod.Meta().Values}
+		if lod.DerivedFrom() >= 0 {
+			derivedFrom := lod.DerivedFrom()
+			jsonLod.DerivedFrom = &derivedFrom
+		}
+		converted.Lods = append(converted.Lods, jsonLod)
+	}
+	return converted
+}
+
+func (r *Root) toJSON() *jsonRoot {
+	converted := &jsonRoot{Format: jsonFormatName, Version: JSONFormatVersion, Namespace: r.namespace, Name: r.name,
+		Hash:             jsonHash{Algorithm: r.hash.Algorithm().String(), Value: r.hash.String()},
+		DefinitionHashes: []jsonDefinitionHash{}, Enums: []jsonEnum{}, UserTypes: []jsonUserType{},
+		Components: []jsonComponent{}, Events: []jsonMessage{}, Commands: []jsonMessage{}, Buffers: []jsonMessage{},
+		Archetypes: []jsonArchetype{}}
+
+	for _, definitionHash := range r.definitionHashes {
+		converted.DefinitionHashes = append(converted.DefinitionHashes, jsonDefinitionHash{
+			Kind: definitionHash.Kind().String(), Name: definitionHash.Name(), Hash: definitionHash.Hash().String()})
+	}
+
+	for _, enum := range r.enums {
+		jsonEnum := jsonEnum{Name: enum.Name(), Constants: []jsonEnumConstant{}, Position: toJSONPosition(enum.Position())}
+		for _, constant := range enum.Constants() {
+			jsonEnum.Constants = append(jsonEnum.Constants, jsonEnumConstant{Index: constant.Index(),
+				Name: constant.Name(), Value: constant.Value()})
+		}
+		converted.Enums = append(converted.Enums, jsonEnum)
+	}
+
+	for _, userType := range r.userTypes {
+		converted.UserTypes = append(converted.UserTypes, jsonUserType{Name: userType.TypeName(),
+			Fields: toJSONFields(r, userType.Fields()), Position: toJSONPosition(userType.Position())})
+	}
+
+	for _, component := range r.componentDataTypes {
+		converted.Components = append(converted.Components, jsonComponent{Name: component.Name(),
+			Index: int(component.Index()), Base: component.BaseName(), Fields: toJSONFields(r, component.Fields()),
+			Meta: component.Meta().Values, Position: toJSONPosition(component.Position())})
+	}
+
+	for _, event := range r.events {
+		converted.Events = append(converted.Events, jsonMessage{Name: event.Name(), Index: int(event.TypeIndex()),
+			Fields: toJSONFields(r, event.Fields()), Meta: event.Meta().Values, Position: toJSONPosition(event.Position())})
+	}
+
+	for _, command := range r.commands {
+		converted.Commands = append(converted.Commands, jsonMessage{Name: command.Name(), Index: int(command.TypeIndex()),
+			Fields: toJSONFields(r, command.Fields()), Meta: command.Meta().Values, Position: toJSONPosition(command.Position())})
+	}
+
+	for _, buffer := range r.buffers {
+		converted.Buffers = append(converted.Buffers, jsonMessage{Name: buffer.Name(), Index: int(buffer.TypeIndex()),
+			Fields: toJSONFields(r, buffer.Fields()), Meta: buffer.Meta().Values, Position: toJSONPosition(buffer.Position())})
+	}
+
+	for _, archetype := range r.archetypes {
+		converted.Archetypes = append(converted.Archetypes, toJSONArchetype(archetype))
+	}
+
+	return converted
+}
+
+// MarshalJSON writes the whole definition as a versioned JSON document. Component fields include
+// the inherited fields, and levels of detail are written with all their items expanded.
+func (r *Root) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.toJSON())
+}
@@ -0,0 +1,437 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package definition
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/piot/scrawl-go/src/scrawlhash"
+	"github.com/piot/scrawl-go/src/token"
+)
+
+type jsonReader struct {
+	root *Root
+}
+
+func fromJSONPosition(position *jsonPosition) token.Position {
+	if position == nil {
+		return token.Position{}
+	}
+	return token.NewPosition(position.Line, position.Column)
+}
+
+func fromJSONMeta(values map[string]string) MetaData {
+	return MetaData{Values: values}
+}
+
+func checkJSONIndex(kind string, name string, index int, expected int) error {
+	if index != expected {
+		return fmt.Errorf("%v '%v' has index %d, expected %d", kind, name, index, expected)
+	}
+	return nil
+}
+
+func fromJSONFields(owner string, fields []jsonField) ([]*Field, error) {
+	var converted []*Field
+	for index, field := range fields {
+		if field.Name == "" || field.Type == "" {
+			return nil, fmt.Errorf("'%v' has a field without name or type", owner)
+		}
+		if err := checkJSONIndex("field", owner+"."+field.Name, field.Index, index); err != nil {
+			return nil, err
+		}
+		for _, existing := range converted {
+			if existing.Name() == field.Name {
+				return nil, fmt.Errorf("'%v' has field '%v' more than once", owner, field.Name)
+			}
+		}
+		converted = append(converted, NewField(field.Index, field.Name, field.Type, fromJSONMeta(field.Meta)))
+		converted[index].SetPosition(fromJSONPosition(field.Position))
+	}
+	return converted, nil
+}
+
+func (r *jsonReader) enums(enums []jsonEnum) error {
+	for _, enum := range enums {
+		if r.root.FindEnum(enum.Name) != nil {
+			return fmt.Errorf("enum '%v' is defined more than once", enum.Name)
+		}
+		var constants []*EnumConstant
+		for index, constant := range enum.Constants {
+			if err := checkJSONIndex("enum constant", enum.Name+"."+constant.Name, constant.Index, index); err != nil {
+				return err
+			}
+			constants = append(constants, NewEnumConstant(constant.Index, constant.Name, constant.Value, nil))
+		}
+		converted := NewEnum(enum.Name, constants)
+		converted.SetPosition(fromJSONPosition(enum.Position))
+		r.root.AddEnum(converted)
+	}
+	return nil
+}
+
+func (r *jsonReader) userTypes(userTypes []jsonUserType) error {
+	for _, userType := range userTypes {
+		if r.root.FindUserType(userType.Name) != nil {
+			return fmt.Errorf("type '%v' is defined more than once", userType.Name)
+		}
+		fields, fieldsErr := fromJSONFields(userType.Name, userType.Fields)
+		if fieldsErr != nil {
+			return fieldsErr
+		}
+		converted := NewUserType(userType.Name, fields)
+		converted.SetPosition(fromJSONPosition(userType.Position))
+		r.root.AddUserType(converted)
+	}
+	return nil
+}
+
+// extendComponent links a component to its base. The dumped fields are flattened, so the
+// inherited fields are checked against the base and only the rest are kept as own fields.
+func (r *jsonReader) extendComponent(component *ComponentDataType, dumped map[*ComponentDataType][]jsonField,
+	visiting map[*ComponentDataType]bool) error {
+	fields, notExtended := dumped[component]
+	if !notExtended {
+		return nil
+	}
+	if visiting[component] {
+		return fmt.Errorf("component '%v' extends itself", component.Name())
+	}
+	visiting[component] = true
+
+	var base *ComponentDataType
+	if component.BaseName() != "" {
+		base = r.root.FindComponentDataType(component.BaseName())
+		if base == nil {
+			return fmt.Errorf("unknown base component '%v' for '%v'", component.BaseName(), component.Name())
+		}
+		if err := r.extendComponent(base, dumped, visiting); err != nil {
+			return err
+		}
+	}
+
+	converted, fieldsErr := fromJSONFields(component.Name(), fields)
+	if fieldsErr != nil {
+		return fieldsErr
+	}
+
+	if base == nil {
+		component.fields = converted
+		component.ownFields = converted
+		delete(dumped, component)
+		return nil
+	}
+
+	baseFields := base.Fields()
+	if len(converted) < len(baseFields) {
+		return fmt.Errorf("component '%v' is missing the fields inherited from '%v'", component.Name(), base.Name())
+	}
+	for index, baseField := range baseFields {
+		if converted[index].Name() != baseField.Name() || converted[index].FieldType() != baseField.FieldType() {
+			return fmt.Errorf("component '%v' field %d does not match base component '%v'", component.Name(), index, base.Name())
+		}
+	}
+	component.ownFields = converted[len(baseFields):]
+	delete(dumped, component)
+
+	return component.Extend(base)
+}
+
+func (r *jsonReader) components(components []jsonComponent) error {
+	dumped := make(map[*ComponentDataType][]jsonField)
+	for index, component := range components {
+		if r.root.FindComponentDataType(component.Name) != nil {
+			return fmt.Errorf("component '%v' is defined more than once", component.Name)
+		}
+		if err := checkJSONIndex("component", component.Name, component.Index, index); err != nil {
+			return err
+		}
+		converted := NewComponentDataType(component.Name, uint8(component.Index), nil, fromJSONMeta(component.Meta))
+		converted.SetBaseName(component.Base)
+		converted.SetPosition(fromJSONPosition(component.Position))
+		dumped[converted] = component.Fields
+		r.root.AddComponentDataType(converted)
+	}
+
+	visiting := make(map[*ComponentDataType]bool)
+	for _, component := range r.root.ComponentDataTypes() {
+		if err := r.extendComponent(component, dumped, visiting); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *jsonReader) messages(kind string, messages []jsonMessage, add func(index int, name string, meta MetaData,
+	fields []*Field, position token.Position)) error {
+	names := make(map[string]bool)
+	for index, message := range messages {
+		if names[message.Name] {
+			return fmt.Errorf("%v '%v' is defined more than once", kind, message.Name)
+		}
+		names[message.Name] = true
+		if err := checkJSONIndex(kind, message.Name, message.Index, index); err != nil {
+			return err
+		}
+		fields, fieldsErr := fromJSONFields(message.Name, message.Fields)
+		if fieldsErr != nil {
+			return fieldsErr
+		}
+		add(index, message.Name, fromJSONMeta(message.Meta), fields, fromJSONPosition(message.Position))
+	}
+	return nil
+}
+
+// item re-links an archetype item to the component it refers to. Components with index 0xff are
+// the predefined components that are allowed without being declared.
+func (r *jsonReader) item(archetype string, index int, item jsonItem) (*EntityArchetypeItem, error) {
+	if err := checkJSONIndex("item", archetype+"."+item.Name, item.Index, index); err != nil {
+		return nil, err
+	}
+	meta := fromJSONMeta(item.Meta)
+
+	switch item.Kind {
+	case "field":
+		return NewEntityArchetypeItemUsingFieldType(index, item.Name, meta), nil
+	case "component":
+		if item.ComponentIndex == nil {
+			return nil, fmt.Errorf("archetype '%v' item '%v' has no component index", archetype, item.Name)
+		}
+		componentIndex := *item.ComponentIndex
+		var component *ComponentDataType
+		if componentIndex == 0xff {
+			if r.root.FindComponentDataType(item.Name) != nil {
+				return nil, fmt.Errorf("archetype '%v' item '%v' refers to a declared component without its index", archetype, item.Name)
+			}
+			component = NewComponentDataType(item.Name, 0xff, nil, MetaData{})
+		} else {
+			if componentIndex < 0 || componentIndex >= len(r.root.ComponentDataTypes()) {
+				return nil, fmt.Errorf("archetype '%v' item '%v' has unknown component index %d", archetype, item.Name, componentIndex)
+			}
+			component = r.root.ComponentDataTypes()[componentIndex]
+			if component.Name() != item.Name {
+				return nil, fmt.Errorf("archetype '%v' item '%v' refers to component %d '%v'", archetype, item.Name,
+					componentIndex, component.Name())
+			}
+		}
+		return NewEntityArchetypeItemUsingComponentDataTypeReference(component, meta).withIndex(index), nil
+	}
+
+	return nil, fmt.Errorf("archetype '%v' item '%v' has unknown kind '%v'", archetype, item.Name, item.Kind)
+}
+
+func (r *jsonReader) lod(archetype string, level int, lod jsonLod) (*EntityArchetypeLOD, error) {
+	if lod.Level != level {
+		return nil, fmt.Errorf("archetype '%v' has lod%d, expected lod%d", archetype, lod.Level, level)
+	}
+
+	var items []*EntityArchetypeItem
+	for index, item := range lod.Items {
+		converted, itemErr := r.item(archetype, index, item)
+		if itemErr != nil {
+			return nil, itemErr
+		}
+		for _, existing := range items {
+			if existing.Name() == converted.Name() {
+				return nil, fmt.Errorf("archetype '%v' lod%d has '%v' more than once", archetype, level, item.Name)
+			}
+		}
+		items = append(items, converted)
+	}
+
+	converted := NewEntityArchetypeLOD(level, items)
+	if lod.DerivedFrom != nil {
+		if *lod.DerivedFrom < 0 || *lod.DerivedFrom >= level {
+			return nil, fmt.Errorf("archetype '%v' lod%d can not be derived from lod%d", archetype, level, *lod.DerivedFrom)
+		}
+		converted.derivedFrom = *lod.DerivedFrom
+	}
+
+	if err := converted.SetMeta(fromJSONMeta(lod.Meta)); err != nil {
+		return nil, fmt.Errorf("archetype '%v' %v", archetype, err)
+	}
+	if converted.Distance() != lod.Distance || converted.Rate() != lod.Rate {
+		return nil, fmt.Errorf("archetype '%v' lod%d distance and rate do not match the meta data", archetype, level)
+	}
+
+	return converted, nil
+}
+
+func (r *jsonReader) archetypes(archetypes []jsonArchetype) error {
+	for index, archetype := range archetypes {
+		if r.root.FindEntity(archetype.Name) != nil {
+			return fmt.Errorf("archetype '%v' is defined more than once", archetype.Name)
+		}
+		if err := checkJSONIndex("archetype", archetype.Name, archetype.Index, index); err != nil {
+			return err
+		}
+
+		var lods []*EntityArchetypeLOD
+		for level, lod := range archetype.Lods {
+			converted, lodErr := r.lod(archetype.Name, level, lod)
+			if lodErr != nil {
+				return lodErr
+			}
+			lods = append(lods, converted)
+		}
+
+		converted := NewEntityArchetype(archetype.Name, NewEntityIndex(uint8(index)), lods, fromJSONMeta(archetype.Meta))
+		if int(converted.ID().Value()) != archetype.ID {
+			return fmt.Errorf("archetype '%v' has id %d, expected %d", archetype.Name, archetype.ID, converted.ID().Value())
+		}
+		if archetype.Base != "" {
+			base := r.root.FindEntity(archetype.Base)
+			if base == nil {
+				return fmt.Errorf("unknown base archetype '%v' for '%v'", archetype.Base, archetype.Name)
+			}
+			converted.SetBase(base)
+		}
+		converted.SetPosition(fromJSONPosition(archetype.Position))
+		r.root.AddArchetype(converted)
+	}
+	return nil
+}
+
+// hash calculates the hashes from the loaded definitions and checks them against the dumped ones.
+// Documents written by other tools can leave the hashes out. Without an algorithm, FNV32a is used.
+func (r *jsonReader) hash(dumped jsonRoot) error {
+	algorithm := scrawlhash.FNV32a
+	if dumped.Hash.Algorithm != "" {
+		parsedAlgorithm, algorithmErr := scrawlhash.ParseAlgorithm(dumped.Hash.Algorithm)
+		if algorithmErr != nil {
+			return algorithmErr
+		}
+		algorithm = parsedAlgorithm
+	}
+
+	calculated := make(map[string]*DefinitionHash)
+	var definitionHashes []*DefinitionHash
+	for _, canonical := range r.root.CanonicalDefinitions() {
+		definitionHash := NewDefinitionHash(canonical.Kind(), canonical.Name(), scrawlhash.Calculate(algorithm, canonical.Octets()))
+		definitionHashes = append(definitionHashes, definitionHash)
+		calculated[canonical.Kind().String()+" "+canonical.Name()] = definitionHash
+	}
+
+	for _, definitionHash := range dumped.DefinitionHashes {
+		found := calculated[definitionHash.Kind+" "+definitionHash.Name]
+		if found == nil {
+			return fmt.Errorf("hash for unknown %v '%v'", definitionHash.Kind, definitionHash.Name)
+		}
+		if found.Hash().String() != definitionHash.Hash {
+			return fmt.Errorf("%v '%v' has hash %v, but the definition hashes to %v", definitionHash.Kind,
+				definitionHash.Name, definitionHash.Hash, found.Hash())
+		}
+	}
+
+	hash := scrawlhash.Calculate(algorithm, r.root.CanonicalOctets())
+	if dumped.Hash.Value != "" && hash.String() != dumped.Hash.Value {
+		return fmt.Errorf("hash is %v, but the definitions hash to %v", dumped.Hash.Value, hash)
+	}
+
+	r.root.SetDefinitionHashes(definitionHashes)
+	r.root.SetHash(hash)
+
+	return nil
+}
+
+func (r *jsonReader) read(dumped jsonRoot) error {
+	if dumped.Format != jsonFormatName {
+		return fmt.Errorf("not a scrawl definition, format is '%v'", dumped.Format)
+	}
+	if dumped.Version != JSONFormatVersion {
+		return fmt.Errorf("unsupported version %d, expected %d", dumped.Version, JSONFormatVersion)
+	}
+
+	r.root.SetNamespace(dumped.Namespace)
+	r.root.SetName(dumped.Name)
+
+	if err := r.enums(dumped.Enums); err != nil {
+		return err
+	}
+	if err := r.userTypes(dumped.UserTypes); err != nil {
+		return err
+	}
+	if err := r.components(dumped.Components); err != nil {
+		return err
+	}
+
+	eventsErr := r.messages("event", dumped.Events, func(index int, name string, meta MetaData, fields []*Field,
+		position token.Position) {
+		event := NewEvent(NewEventTypeIndex(index), name, meta, fields)
+		event.SetPosition(position)
+		r.root.AddEvent(event)
+	})
+	if eventsErr != nil {
+		return eventsErr
+	}
+
+	commandsErr := r.messages("command", dumped.Commands, func(index int, name string, meta MetaData, fields []*Field,
+		position token.Position) {
+		command := NewCommand(NewCommandTypeIndex(index), name, meta, fields)
+		command.SetPosition(position)
+		r.root.AddMethod(command)
+	})
+	if commandsErr != nil {
+		return commandsErr
+	}
+
+	buffersErr := r.messages("buffer", dumped.Buffers, func(index int, name string, meta MetaData, fields []*Field,
+		position token.Position) {
+		buffer := NewBuffer(NewBufferIndex(index), name, meta, fields)
+		buffer.SetPosition(position)
+		r.root.AddBuffer(buffer)
+	})
+	if buffersErr != nil {
+		return buffersErr
+	}
+
+	if err := r.archetypes(dumped.Archetypes); err != nil {
+		return err
+	}
+
+	return r.hash(dumped)
+}
+
+// UnmarshalJSON reads a document written by MarshalJSON. Indices, references between definitions
+// and the hashes are validated, and archetype items are linked to the loaded components.
+func (r *Root) UnmarshalJSON(octets []byte) error {
+	var dumped jsonRoot
+	if err := json.Unmarshal(octets, &dumped); err != nil {
+		return err
+	}
+
+	loaded := &Root{}
+	reader := &jsonReader{root: loaded}
+	if err := reader.read(dumped); err != nil {
+		return fmt.Errorf("definition json: %v", err)
+	}
+
+	*r = *loaded
+
+	return nil
+}
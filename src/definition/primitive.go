@@ -0,0 +1,141 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package definition
+
+import (
+	"fmt"
+	"strings"
+)
+
+type PrimitiveType uint8
+
+const (
+	PrimitiveUnknown PrimitiveType = iota
+	PrimitiveBool
+	PrimitiveInt8
+	PrimitiveUint8
+	PrimitiveInt16
+	PrimitiveUint16
+	PrimitiveInt32
+	PrimitiveUint32
+	PrimitiveInt64
+	PrimitiveUint64
+	PrimitiveFloat32
+	PrimitiveFloat64
+	PrimitiveString
+)
+
+var primitiveTypeNames = map[string]PrimitiveType{
+	"bool":    PrimitiveBool,
+	"boolean": PrimitiveBool,
+	"int8":    PrimitiveInt8,
+	"uint8":   PrimitiveUint8,
+	"byte":    PrimitiveUint8,
+	"int16":   PrimitiveInt16,
+	"uint16":  PrimitiveUint16,
+	"int":     PrimitiveInt32,
+	"integer": PrimitiveInt32,
+	"int32":   PrimitiveInt32,
+	"uint32":  PrimitiveUint32,
+	"int64":   PrimitiveInt64,
+	"uint64":  PrimitiveUint64,
+	"float":   PrimitiveFloat32,
+	"float32": PrimitiveFloat32,
+	"double":  PrimitiveFloat64,
+	"float64": PrimitiveFloat64,
+	"string":  PrimitiveString,
+}
+
+// LookupPrimitiveType finds the primitive type for a field type name. The lookup is case insensitive,
+// so 'Integer' and 'int32' are the same type.
+func LookupPrimitiveType(name string) (PrimitiveType, bool) {
+	primitive, found := primitiveTypeNames[strings.ToLower(name)]
+	return primitive, found
+}
+
+func (p PrimitiveType) String() string {
+	switch p {
+	case PrimitiveBool:
+		return "bool"
+	case PrimitiveInt8:
+		return "int8"
+	case PrimitiveUint8:
+		return "uint8"
+	case PrimitiveInt16:
+		return "int16"
+	case PrimitiveUint16:
+		return "uint16"
+	case PrimitiveInt32:
+		return "int32"
+	case PrimitiveUint32:
+		return "uint32"
+	case PrimitiveInt64:
+		return "int64"
+	case PrimitiveUint64:
+		return "uint64"
+	case PrimitiveFloat32:
+		return "float32"
+	case PrimitiveFloat64:
+		return "float64"
+	case PrimitiveString:
+		return "string"
+	}
+	return fmt.Sprintf("unknown-primitive-%d", uint8(p))
+}
+
+// BitSize is the size of the primitive in bits, or zero for strings.
+func (p PrimitiveType) BitSize() int {
+	switch p {
+	case PrimitiveBool:
+		return 1
+	case PrimitiveInt8, PrimitiveUint8:
+		return 8
+	case PrimitiveInt16, PrimitiveUint16:
+		return 16
+	case PrimitiveInt32, PrimitiveUint32, PrimitiveFloat32:
+		return 32
+	case PrimitiveInt64, PrimitiveUint64, PrimitiveFloat64:
+		return 64
+	}
+	return 0
+}
+
+func (p PrimitiveType) IsInteger() bool {
+	return p >= PrimitiveInt8 && p <= PrimitiveUint64
+}
+
+func (p PrimitiveType) IsSigned() bool {
+	switch p {
+	case PrimitiveInt8, PrimitiveInt16, PrimitiveInt32, PrimitiveInt64:
+		return true
+	}
+	return false
+}
+
+func (p PrimitiveType) IsFloat() bool {
+	return p == PrimitiveFloat32 || p == PrimitiveFloat64
+}
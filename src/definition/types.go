@@ -28,9 +28,12 @@ package definition
 
 import (
 	"fmt"
+
+	"github.com/piot/scrawl-go/src/scrawlhash"
 )
 
-type Hash uint32
+// Hash can hold digests of any length, depending on the algorithm used when parsing.
+type Hash = scrawlhash.Digest
 
 type Root struct {
 	componentDataTypes []*ComponentDataType
@@ -41,6 +44,7 @@ type Root struct {
 	events             []*Event
 	enums              []*Enum
 	hash               Hash
+	definitionHashes   []*DefinitionHash
 	namespace          string
 	name               string
 }
@@ -49,6 +53,10 @@ func (r *Root) SetHash(hash Hash) {
 	r.hash = hash
 }
 
+func (r *Root) SetDefinitionHashes(hashes []*DefinitionHash) {
+	r.definitionHashes = hashes
+}
+
 func (r *Root) SetNamespace(namespace string) {
 	r.namespace = namespace
 }
@@ -61,6 +69,19 @@ func (r *Root) Hash() Hash {
 	return r.hash
 }
 
+func (r *Root) DefinitionHashes() []*DefinitionHash {
+	return r.definitionHashes
+}
+
+func (r *Root) FindDefinitionHash(kind DefinitionKind, name string) *DefinitionHash {
+	for _, definitionHash := range r.definitionHashes {
+		if definitionHash.Kind() == kind && definitionHash.Name() == name {
+			return definitionHash
+		}
+	}
+	return nil
+}
+
 func (r *Root) FindComponentDataType(name string) *ComponentDataType {
 	for _, component := range r.componentDataTypes {
 		if component.Name() == name {
@@ -79,6 +100,15 @@ func (r *Root) FindEntity(name string) *EntityArchetype {
 	return nil
 }
 
+func (r *Root) FindEnum(name string) *Enum {
+	for _, enum := range r.enums {
+		if enum.Name() == name {
+			return enum
+		}
+	}
+	return nil
+}
+
 func (r *Root) FindUserType(name string) *UserType {
 	for _, userType := range r.userTypes {
 		if userType.name == name {
@@ -26,11 +26,16 @@ SOFTWARE.
 
 package definition
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/piot/scrawl-go/src/token"
+)
 
 type UserType struct {
-	name   string
-	fields []*Field
+	name     string
+	fields   []*Field
+	position token.Position
 }
 
 func NewUserType(name string, fields []*Field) *UserType {
@@ -48,3 +53,12 @@ func (u *UserType) TypeName() string {
 func (u *UserType) String() string {
 	return fmt.Sprintf("[usertype %v fields:%v]", u.name, u.fields)
 }
+
+func (u *UserType) SetPosition(position token.Position) {
+	u.position = position
+}
+
+// Position is where the definition starts in the source, or the zero position if unknown.
+func (u *UserType) Position() token.Position {
+	return u.position
+}
@@ -0,0 +1,259 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package delta
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+
+	"github.com/piot/scrawl-go/src/bitstream"
+	"github.com/piot/scrawl-go/src/definition"
+	"github.com/piot/scrawl-go/src/serialize"
+)
+
+// Codec writes the difference between two values of the same type.
+//
+// For every field, in field order, one bit tells if the field has changed. Changed fields that are
+// user types or components (and not arrays) are written as a new delta of their fields. Other
+// changed fields are written in full with serialize.BitCodec, arrays included. A field has changed
+// if its encoded bits differ, so changes smaller than the quantization precision are not sent.
+//
+// When the previous value is nil, every field is written.
+type Codec struct {
+	root *definition.Root
+	bits *serialize.BitCodec
+}
+
+func NewCodec(root *definition.Root) *Codec {
+	return &Codec{root: root, bits: serialize.NewBitCodec(root)}
+}
+
+// EncodeComponent writes the delta from previous to current, padded to a whole octet.
+func (c *Codec) EncodeComponent(component *definition.ComponentDataType, previous map[string]interface{},
+	current map[string]interface{}) ([]byte, error) {
+	writer := bitstream.NewWriter()
+	if err := c.WriteFields(writer, component.Fields(), previous, current); err != nil {
+		return nil, fmt.Errorf("component '%v': %v", component.Name(), err)
+	}
+	return writer.Octets(), nil
+}
+
+// DecodeComponent applies a delta to baseline and returns the new value. The baseline is not modified.
+func (c *Codec) DecodeComponent(component *definition.ComponentDataType, baseline map[string]interface{},
+	octets []byte) (map[string]interface{}, error) {
+	reader := bitstream.NewReader(octets)
+	value, err := c.ReadFields(reader, component.Fields(), baseline)
+	if err != nil {
+		return nil, fmt.Errorf("component '%v': %v", component.Name(), err)
+	}
+	if !reader.IsPadding() {
+		return nil, fmt.Errorf("component '%v': %d bits left after decoding", component.Name(), reader.BitsLeft())
+	}
+	return value, nil
+}
+
+// Changed reports if any field differs between previous and current.
+func (c *Codec) Changed(fields []*definition.Field, previous map[string]interface{}, current map[string]interface{}) (bool, error) {
+	if previous == nil {
+		return true, nil
+	}
+	for _, field := range fields {
+		changed, changedErr := c.fieldChanged(field, previous[field.Name()], current[field.Name()])
+		if changedErr != nil {
+			return false, fmt.Errorf("%v: %v", field.Name(), changedErr)
+		}
+		if changed {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (c *Codec) nestedFields(field *definition.Field) []*definition.Field {
+	if field.IsArray() {
+		return nil
+	}
+	resolved := c.root.ResolveFieldType(field.FieldType())
+	switch resolved.Variant() {
+	case definition.FieldTypeUserType:
+		return resolved.UserType().Fields()
+	case definition.FieldTypeComponent:
+		return resolved.ComponentDataType().Fields()
+	}
+	return nil
+}
+
+func (c *Codec) fieldChanged(field *definition.Field, previous interface{}, current interface{}) (bool, error) {
+	if previous == nil {
+		return true, nil
+	}
+	currentWriter := bitstream.NewWriter()
+	if err := c.bits.WriteField(currentWriter, field, current); err != nil {
+		return false, err
+	}
+	previousWriter := bitstream.NewWriter()
+	if err := c.bits.WriteField(previousWriter, field, previous); err != nil {
+		return false, err
+	}
+	return currentWriter.BitCount() != previousWriter.BitCount() ||
+		!bytes.Equal(currentWriter.Octets(), previousWriter.Octets()), nil
+}
+
+func mapValue(value interface{}) (map[string]interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	fields, isMap := value.(map[string]interface{})
+	if !isMap {
+		return nil, fmt.Errorf("expected map[string]interface{}, got %T", value)
+	}
+	return fields, nil
+}
+
+// WriteFields writes the changed mask and the changed fields.
+func (c *Codec) WriteFields(writer *bitstream.Writer, fields []*definition.Field, previous map[string]interface{},
+	current map[string]interface{}) error {
+	for name := range current {
+		found := false
+		for _, field := range fields {
+			if field.Name() == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown field '%v'", name)
+		}
+	}
+
+	for _, field := range fields {
+		currentValue, found := current[field.Name()]
+		if !found {
+			return fmt.Errorf("missing field '%v'", field.Name())
+		}
+		var previousValue interface{}
+		if previous != nil {
+			previousValue = previous[field.Name()]
+		}
+		changed, changedErr := c.fieldChanged(field, previousValue, currentValue)
+		if changedErr != nil {
+			return fmt.Errorf("%v: %v", field.Name(), changedErr)
+		}
+		writer.WriteBool(changed)
+		if !changed {
+			continue
+		}
+
+		nested := c.nestedFields(field)
+		if nested == nil {
+			if err := c.bits.WriteField(writer, field, currentValue); err != nil {
+				return err
+			}
+			continue
+		}
+		previousFields, previousErr := mapValue(previousValue)
+		if previousErr != nil {
+			return fmt.Errorf("%v: %v", field.Name(), previousErr)
+		}
+		currentFields, currentErr := mapValue(currentValue)
+		if currentErr != nil {
+			return fmt.Errorf("%v: %v", field.Name(), currentErr)
+		}
+		if err := c.WriteFields(writer, nested, previousFields, currentFields); err != nil {
+			return fmt.Errorf("%v: %v", field.Name(), err)
+		}
+	}
+	return nil
+}
+
+// ReadFields reads a delta written by WriteFields and returns baseline with the changes applied.
+// Fields that are not changed must exist in baseline.
+func (c *Codec) ReadFields(reader *bitstream.Reader, fields []*definition.Field, baseline map[string]interface{}) (map[string]interface{}, error) {
+	value := make(map[string]interface{})
+	for _, field := range fields {
+		changed, changedErr := reader.ReadBool()
+		if changedErr != nil {
+			return nil, fmt.Errorf("%v: %v", field.Name(), changedErr)
+		}
+		var baselineValue interface{}
+		if baseline != nil {
+			baselineValue = baseline[field.Name()]
+		}
+		if !changed {
+			if baselineValue == nil {
+				return nil, fmt.Errorf("%v: not changed, but missing in baseline", field.Name())
+			}
+			value[field.Name()] = Copy(baselineValue)
+			continue
+		}
+
+		nested := c.nestedFields(field)
+		if nested == nil {
+			fieldValue, fieldErr := c.bits.ReadField(reader, field)
+			if fieldErr != nil {
+				return nil, fieldErr
+			}
+			value[field.Name()] = fieldValue
+			continue
+		}
+		baselineFields, baselineErr := mapValue(baselineValue)
+		if baselineErr != nil {
+			return nil, fmt.Errorf("%v: %v", field.Name(), baselineErr)
+		}
+		fieldValue, fieldErr := c.ReadFields(reader, nested, baselineFields)
+		if fieldErr != nil {
+			return nil, fmt.Errorf("%v: %v", field.Name(), fieldErr)
+		}
+		value[field.Name()] = fieldValue
+	}
+	return value, nil
+}
+
+// Copy returns a deep copy of a value, so that it does not share maps or slices with the original.
+// Slices of any type are copied to []interface{}.
+func Copy(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			copied[key] = Copy(item)
+		}
+		return copied
+	case nil:
+		return nil
+	}
+	slice := reflect.ValueOf(value)
+	if slice.Kind() != reflect.Slice {
+		return value
+	}
+	copied := make([]interface{}, slice.Len())
+	for index := range copied {
+		copied[index] = Copy(slice.Index(index).Interface())
+	}
+	return copied
+}
@@ -0,0 +1,162 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package delta
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/piot/scrawl-go/src/bitstream"
+	"github.com/piot/scrawl-go/src/definition"
+	"github.com/piot/scrawl-go/src/parser"
+	"github.com/piot/scrawl-go/src/scrawl"
+	"github.com/piot/scrawl-go/src/serialize"
+)
+
+const testProtocol = `
+type Vector
+  x int16
+  y int16
+
+component Avatar
+  health uint8 [min "0" max "100"]
+  position Vector
+  angle float32 [min "0" max "360" precision "1"]
+  path Vector [capacity "3"]
+`
+
+func setupComponent(t *testing.T) (*definition.Root, *definition.ComponentDataType) {
+	root, err := scrawl.ParseStringWithOptions(testProtocol, parser.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root, root.FindComponentDataType("Avatar")
+}
+
+func avatar(health int, x int, y int, angle float32) map[string]interface{} {
+	return map[string]interface{}{
+		"health":   uint8(health),
+		"position": map[string]interface{}{"x": int16(x), "y": int16(y)},
+		"angle":    angle,
+		"path":     []interface{}{map[string]interface{}{"x": int16(1), "y": int16(2)}},
+	}
+}
+
+func TestUnchanged(t *testing.T) {
+	root, component := setupComponent(t)
+	codec := NewCodec(root)
+	value := avatar(50, 10, 20, 90)
+
+	writer := bitstream.NewWriter()
+	if err := codec.WriteFields(writer, component.Fields(), value, avatar(50, 10, 20, 90.2)); err != nil {
+		t.Fatal(err)
+	}
+	if writer.BitCount() != 4 {
+		t.Errorf("only the mask should be written, got %d bits", writer.BitCount())
+	}
+
+	changed, changedErr := codec.Changed(component.Fields(), value, avatar(50, 10, 20, 90))
+	if changedErr != nil || changed {
+		t.Errorf("should not be changed %v", changedErr)
+	}
+}
+
+func TestNestedChange(t *testing.T) {
+	root, component := setupComponent(t)
+	codec := NewCodec(root)
+	previous := avatar(50, 10, 20, 90)
+	current := avatar(50, 10, -5, 90)
+
+	writer := bitstream.NewWriter()
+	if err := codec.WriteFields(writer, component.Fields(), previous, current); err != nil {
+		t.Fatal(err)
+	}
+	if writer.BitCount() != 4+2+16 {
+		t.Errorf("wrong bit count %d", writer.BitCount())
+	}
+
+	decoded, decodeErr := codec.DecodeComponent(component, previous, writer.Octets())
+	if decodeErr != nil {
+		t.Fatal(decodeErr)
+	}
+	if !reflect.DeepEqual(decoded, current) {
+		t.Errorf("wrong value %v", decoded)
+	}
+	if previous["position"].(map[string]interface{})["y"] != int16(20) {
+		t.Errorf("baseline was modified")
+	}
+}
+
+func TestFullWithoutPrevious(t *testing.T) {
+	root, component := setupComponent(t)
+	codec := NewCodec(root)
+	current := avatar(100, -1, 1, 0)
+
+	octets, err := codec.EncodeComponent(component, nil, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, decodeErr := codec.DecodeComponent(component, nil, octets)
+	if decodeErr != nil {
+		t.Fatal(decodeErr)
+	}
+	if !reflect.DeepEqual(decoded, current) {
+		t.Errorf("wrong value %v", decoded)
+	}
+
+	path := current["path"].([]interface{})
+	current["path"] = append(path, map[string]interface{}{"x": int16(3), "y": int16(4)})
+	changedOctets, changedErr := codec.EncodeComponent(component, decoded, current)
+	if changedErr != nil {
+		t.Fatal(changedErr)
+	}
+	if _, err := codec.DecodeComponent(component, nil, changedOctets); err == nil {
+		t.Errorf("missing baseline should be reported")
+	}
+	applied, appliedErr := codec.DecodeComponent(component, decoded, changedOctets)
+	if appliedErr != nil {
+		t.Fatal(appliedErr)
+	}
+	if !reflect.DeepEqual(applied, current) {
+		t.Errorf("wrong value %v", applied)
+	}
+}
+
+func TestFieldsSize(t *testing.T) {
+	root, component := setupComponent(t)
+	codec := NewCodec(root)
+
+	size, err := codec.FieldsSize(component.Fields())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := serialize.Size{Min: 4, Max: 4 + 7 + 2 + 32 + 9 + 2 + 3*32, Typical: 4 + 7 + 2 + 32 + 9 + 2 + 2*32}
+	if size != expected {
+		t.Errorf("wrong size %v", size)
+	}
+}
@@ -0,0 +1,66 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package delta
+
+import (
+	"fmt"
+
+	"github.com/piot/scrawl-go/src/definition"
+	"github.com/piot/scrawl-go/src/serialize"
+)
+
+const maxDepth = 32
+
+// FieldsSize returns the number of bits a delta of the fields uses. Min is a delta where nothing
+// has changed, Max and Typical are deltas where every field has changed.
+func (c *Codec) FieldsSize(fields []*definition.Field) (serialize.Size, error) {
+	return c.fieldsSize(fields, 0)
+}
+
+func (c *Codec) fieldsSize(fields []*definition.Field, depth int) (serialize.Size, error) {
+	if depth > maxDepth {
+		return serialize.Size{}, fmt.Errorf("types are nested too deep")
+	}
+	mask := len(fields)
+	total := serialize.Size{Min: mask, Max: mask, Typical: mask}
+	for _, field := range fields {
+		var size serialize.Size
+		var sizeErr error
+		nested := c.nestedFields(field)
+		if nested != nil {
+			size, sizeErr = c.fieldsSize(nested, depth+1)
+		} else {
+			size, sizeErr = c.bits.FieldSize(field)
+		}
+		if sizeErr != nil {
+			return serialize.Size{}, fmt.Errorf("%v: %v", field.Name(), sizeErr)
+		}
+		total.Max += size.Max
+		total.Typical += size.Typical
+	}
+	return total, nil
+}
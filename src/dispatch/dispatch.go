@@ -0,0 +1,196 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package dispatch
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/piot/scrawl-go/src/definition"
+)
+
+// Decoder is implemented by serialize.Codec and serialize.BitCodec.
+type Decoder interface {
+	Decode(fields []*definition.Field, octets []byte) (map[string]interface{}, error)
+}
+
+// EventHandler receives decoded events, in the representation used by serialize.
+type EventHandler func(event *definition.Event, value map[string]interface{}) error
+
+// CommandHandler receives decoded commands, in the representation used by serialize.
+type CommandHandler func(command *definition.Command, value map[string]interface{}) error
+
+// Registry decodes incoming events and commands by their type index and calls the registered
+// handler. Register every handler at startup and call CheckHandlers to find the events and
+// commands in the protocol that have no handler.
+type Registry struct {
+	root            *definition.Root
+	decoder         Decoder
+	events          map[definition.EventTypeIndex]*definition.Event
+	commands        map[definition.CommandTypeIndex]*definition.Command
+	eventHandlers   map[definition.EventTypeIndex]EventHandler
+	commandHandlers map[definition.CommandTypeIndex]CommandHandler
+}
+
+func NewRegistry(root *definition.Root, decoder Decoder) *Registry {
+	r := &Registry{root: root, decoder: decoder,
+		events:          make(map[definition.EventTypeIndex]*definition.Event),
+		commands:        make(map[definition.CommandTypeIndex]*definition.Command),
+		eventHandlers:   make(map[definition.EventTypeIndex]EventHandler),
+		commandHandlers: make(map[definition.CommandTypeIndex]CommandHandler),
+	}
+	for _, event := range root.Events() {
+		r.events[event.TypeIndex()] = event
+	}
+	for _, command := range root.Commands() {
+		r.commands[command.TypeIndex()] = command
+	}
+	return r
+}
+
+func (r *Registry) Root() *definition.Root {
+	return r.root
+}
+
+func (r *Registry) HandleEvent(index definition.EventTypeIndex, handler EventHandler) error {
+	event, found := r.events[index]
+	if !found {
+		return fmt.Errorf("unknown event type index %d", index)
+	}
+	if handler == nil {
+		return fmt.Errorf("event '%v' (%d) can not have a nil handler", event.Name(), index)
+	}
+	if _, registered := r.eventHandlers[index]; registered {
+		return fmt.Errorf("event '%v' (%d) already has a handler", event.Name(), index)
+	}
+	r.eventHandlers[index] = handler
+	return nil
+}
+
+func (r *Registry) HandleCommand(index definition.CommandTypeIndex, handler CommandHandler) error {
+	command, found := r.commands[index]
+	if !found {
+		return fmt.Errorf("unknown command type index %d", index)
+	}
+	if handler == nil {
+		return fmt.Errorf("command '%v' (%d) can not have a nil handler", command.Name(), index)
+	}
+	if _, registered := r.commandHandlers[index]; registered {
+		return fmt.Errorf("command '%v' (%d) already has a handler", command.Name(), index)
+	}
+	r.commandHandlers[index] = handler
+	return nil
+}
+
+func (r *Registry) HandleEventName(name string, handler EventHandler) error {
+	for _, event := range r.root.Events() {
+		if event.Name() == name {
+			return r.HandleEvent(event.TypeIndex(), handler)
+		}
+	}
+	return fmt.Errorf("unknown event '%v'", name)
+}
+
+func (r *Registry) HandleCommandName(name string, handler CommandHandler) error {
+	for _, command := range r.root.Commands() {
+		if command.Name() == name {
+			return r.HandleCommand(command.TypeIndex(), handler)
+		}
+	}
+	return fmt.Errorf("unknown command '%v'", name)
+}
+
+// DispatchEvent decodes the payload of the event and calls its handler.
+func (r *Registry) DispatchEvent(index definition.EventTypeIndex, octets []byte) error {
+	event, found := r.events[index]
+	if !found {
+		return fmt.Errorf("unknown event type index %d", index)
+	}
+	handler, registered := r.eventHandlers[index]
+	if !registered {
+		return fmt.Errorf("event '%v' (%d) has no handler", event.Name(), index)
+	}
+	value, decodeErr := r.decoder.Decode(event.Fields(), octets)
+	if decodeErr != nil {
+		return fmt.Errorf("event '%v' (%d): %v", event.Name(), index, decodeErr)
+	}
+	return handler(event, value)
+}
+
+// DispatchCommand decodes the payload of the command and calls its handler.
+func (r *Registry) DispatchCommand(index definition.CommandTypeIndex, octets []byte) error {
+	command, found := r.commands[index]
+	if !found {
+		return fmt.Errorf("unknown command type index %d", index)
+	}
+	handler, registered := r.commandHandlers[index]
+	if !registered {
+		return fmt.Errorf("command '%v' (%d) has no handler", command.Name(), index)
+	}
+	value, decodeErr := r.decoder.Decode(command.Fields(), octets)
+	if decodeErr != nil {
+		return fmt.Errorf("command '%v' (%d): %v", command.Name(), index, decodeErr)
+	}
+	return handler(command, value)
+}
+
+// MissingEventHandlers returns the events in the protocol that have no handler.
+func (r *Registry) MissingEventHandlers() []*definition.Event {
+	var missing []*definition.Event
+	for _, event := range r.root.Events() {
+		if _, registered := r.eventHandlers[event.TypeIndex()]; !registered {
+			missing = append(missing, event)
+		}
+	}
+	return missing
+}
+
+// MissingCommandHandlers returns the commands in the protocol that have no handler.
+func (r *Registry) MissingCommandHandlers() []*definition.Command {
+	var missing []*definition.Command
+	for _, command := range r.root.Commands() {
+		if _, registered := r.commandHandlers[command.TypeIndex()]; !registered {
+			missing = append(missing, command)
+		}
+	}
+	return missing
+}
+
+// CheckHandlers returns an error that lists every event and command without a handler.
+func (r *Registry) CheckHandlers() error {
+	var names []string
+	for _, event := range r.MissingEventHandlers() {
+		names = append(names, fmt.Sprintf("event '%v' (%d)", event.Name(), event.TypeIndex()))
+	}
+	for _, command := range r.MissingCommandHandlers() {
+		names = append(names, fmt.Sprintf("command '%v' (%d)", command.Name(), command.TypeIndex()))
+	}
+	if len(names) > 0 {
+		return fmt.Errorf("missing handlers for %v", strings.Join(names, ", "))
+	}
+	return nil
+}
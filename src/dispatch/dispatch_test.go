@@ -0,0 +1,159 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package dispatch
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/piot/scrawl-go/src/definition"
+	"github.com/piot/scrawl-go/src/parser"
+	"github.com/piot/scrawl-go/src/scrawl"
+	"github.com/piot/scrawl-go/src/serialize"
+)
+
+const testProtocol = `
+event Jump
+  height int16
+
+event Land
+  speed uint8
+
+command Fire
+  target uint32
+`
+
+func setupRegistry(t *testing.T) (*Registry, *serialize.Codec) {
+	root, err := scrawl.ParseStringWithOptions(testProtocol, parser.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	codec := serialize.NewCodec(root)
+	return NewRegistry(root, codec), codec
+}
+
+func TestDispatch(t *testing.T) {
+	registry, codec := setupRegistry(t)
+	root := registry.Root()
+	var received []string
+	if err := registry.HandleEventName("Land", func(event *definition.Event, value map[string]interface{}) error {
+		received = append(received, fmt.Sprintf("%v %v", event.Name(), value["speed"]))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.HandleCommand(0, func(command *definition.Command, value map[string]interface{}) error {
+		received = append(received, fmt.Sprintf("%v %v", command.Name(), value["target"]))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	land, _ := codec.EncodeEvent(root.Events()[1], map[string]interface{}{"speed": 7})
+	if err := registry.DispatchEvent(1, land); err != nil {
+		t.Fatal(err)
+	}
+	fire, _ := codec.EncodeCommand(root.Commands()[0], map[string]interface{}{"target": 99})
+	if err := registry.DispatchCommand(0, fire); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(received, ",") != "Land 7,Fire 99" {
+		t.Errorf("wrong dispatch %v", received)
+	}
+}
+
+func TestDispatchErrors(t *testing.T) {
+	registry, _ := setupRegistry(t)
+	handler := func(event *definition.Event, value map[string]interface{}) error {
+		return fmt.Errorf("handler failed")
+	}
+	if err := registry.HandleEvent(5, handler); err == nil {
+		t.Errorf("should fail for unknown index")
+	}
+	if err := registry.HandleEventName("Swim", handler); err == nil {
+		t.Errorf("should fail for unknown name")
+	}
+	if err := registry.HandleEvent(0, nil); err == nil {
+		t.Errorf("should fail for nil event handler")
+	}
+	if err := registry.HandleCommandName("Fire", nil); err == nil {
+		t.Errorf("should fail for nil command handler")
+	}
+	if err := registry.HandleEvent(0, handler); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.HandleEvent(0, handler); err == nil {
+		t.Errorf("should fail for second handler")
+	}
+
+	if err := registry.DispatchEvent(9, []byte{0}); err == nil {
+		t.Errorf("should fail for unknown event index")
+	}
+	if err := registry.DispatchCommand(3, []byte{0}); err == nil {
+		t.Errorf("should fail for unknown command index")
+	}
+	if err := registry.DispatchEvent(1, []byte{0}); err == nil {
+		t.Errorf("should fail without handler")
+	}
+	if err := registry.DispatchEvent(0, []byte{0}); err == nil {
+		t.Errorf("should fail for truncated payload")
+	}
+	if err := registry.DispatchEvent(0, []byte{0, 1}); err == nil || err.Error() != "handler failed" {
+		t.Errorf("handler error should be returned, got %v", err)
+	}
+}
+
+func TestCheckHandlers(t *testing.T) {
+	registry, _ := setupRegistry(t)
+	if err := registry.HandleEventName("Jump", func(event *definition.Event, value map[string]interface{}) error {
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(registry.MissingEventHandlers()) != 1 || len(registry.MissingCommandHandlers()) != 1 {
+		t.Errorf("wrong missing handlers")
+	}
+	err := registry.CheckHandlers()
+	if err == nil || err.Error() != "missing handlers for event 'Land' (1), command 'Fire' (0)" {
+		t.Errorf("wrong diagnostics %v", err)
+	}
+
+	if err := registry.HandleCommandName("Fire", func(command *definition.Command, value map[string]interface{}) error {
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.HandleEvent(1, func(event *definition.Event, value map[string]interface{}) error {
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.CheckHandlers(); err != nil {
+		t.Errorf("all handlers are registered: %v", err)
+	}
+}
@@ -0,0 +1,279 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package estimate
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/piot/scrawl-go/src/definition"
+	"github.com/piot/scrawl-go/src/serialize"
+	"github.com/piot/scrawl-go/src/snapshot"
+)
+
+// Measure selects which size is compared against the budget.
+type Measure uint8
+
+const (
+	MeasureMax Measure = iota
+	MeasureTypical
+	MeasureMin
+)
+
+func (m Measure) String() string {
+	switch m {
+	case MeasureMax:
+		return "max"
+	case MeasureTypical:
+		return "typical"
+	case MeasureMin:
+		return "min"
+	}
+	return fmt.Sprintf("unknown-measure-%d", uint8(m))
+}
+
+func ParseMeasure(name string) (Measure, error) {
+	for _, measure := range []Measure{MeasureMax, MeasureTypical, MeasureMin} {
+		if measure.String() == name {
+			return measure, nil
+		}
+	}
+	return MeasureMax, fmt.Errorf("unknown measure '%v'", name)
+}
+
+func (m Measure) Of(size serialize.Size) int {
+	switch m {
+	case MeasureTypical:
+		return size.Typical
+	case MeasureMin:
+		return size.Min
+	}
+	return size.Max
+}
+
+// Budget is the highest number of bits a definition or snapshot may use. A budget of zero bits is
+// never exceeded.
+type Budget struct {
+	Bits    int
+	Measure Measure
+}
+
+func (b Budget) Exceeded(size serialize.Size) bool {
+	return b.Bits > 0 && b.Measure.Of(size) > b.Bits
+}
+
+// Entry is the size of a component, event, command or buffer when it is written with
+// serialize.BitCodec.
+type Entry struct {
+	kind       definition.DefinitionKind
+	name       string
+	index      int
+	size       serialize.Size
+	overBudget bool
+}
+
+func (e *Entry) Kind() definition.DefinitionKind {
+	return e.kind
+}
+
+func (e *Entry) Name() string {
+	return e.name
+}
+
+func (e *Entry) Index() int {
+	return e.index
+}
+
+func (e *Entry) Size() serialize.Size {
+	return e.size
+}
+
+func (e *Entry) OverBudget() bool {
+	return e.overBudget
+}
+
+func (e *Entry) String() string {
+	return fmt.Sprintf("[entry %v %v %v]", e.kind, e.name, e.size)
+}
+
+// LodEntry is the size of an entity snapshot for one level of detail, as written by snapshot.Codec.
+type LodEntry struct {
+	level      int
+	size       serialize.Size
+	overBudget bool
+}
+
+func (e *LodEntry) Level() int {
+	return e.level
+}
+
+func (e *LodEntry) Size() serialize.Size {
+	return e.size
+}
+
+func (e *LodEntry) OverBudget() bool {
+	return e.overBudget
+}
+
+type ArchetypeEntry struct {
+	name string
+	lods []*LodEntry
+}
+
+func (e *ArchetypeEntry) Name() string {
+	return e.name
+}
+
+func (e *ArchetypeEntry) Lods() []*LodEntry {
+	return e.lods
+}
+
+type Report struct {
+	budget     Budget
+	entries    []*Entry
+	archetypes []*ArchetypeEntry
+}
+
+func (r *Report) Budget() Budget {
+	return r.budget
+}
+
+func (r *Report) Entries() []*Entry {
+	return r.entries
+}
+
+func (r *Report) Archetypes() []*ArchetypeEntry {
+	return r.archetypes
+}
+
+// OverBudgetCount returns the number of definitions and levels of detail that exceed the budget.
+func (r *Report) OverBudgetCount() int {
+	count := 0
+	for _, entry := range r.entries {
+		if entry.overBudget {
+			count++
+		}
+	}
+	for _, archetype := range r.archetypes {
+		for _, lod := range archetype.lods {
+			if lod.overBudget {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func (r *Report) addEntry(codec *serialize.BitCodec, kind definition.DefinitionKind, name string, index int,
+	fields []*definition.Field) error {
+	size, sizeErr := codec.FieldsSize(fields)
+	if sizeErr != nil {
+		return fmt.Errorf("%v '%v': %v", kind, name, sizeErr)
+	}
+	r.entries = append(r.entries, &Entry{kind: kind, name: name, index: index, size: size,
+		overBudget: r.budget.Exceeded(size)})
+	return nil
+}
+
+// NewReport estimates the size of every component, event, command and buffer, and of every level
+// of detail of every archetype.
+func NewReport(root *definition.Root, budget Budget) (*Report, error) {
+	r := &Report{budget: budget}
+	codec := serialize.NewBitCodec(root)
+	for _, component := range root.ComponentDataTypes() {
+		if err := r.addEntry(codec, definition.DefinitionKindComponent, component.Name(), int(component.Index()),
+			component.Fields()); err != nil {
+			return nil, err
+		}
+	}
+	for _, event := range root.Events() {
+		if err := r.addEntry(codec, definition.DefinitionKindEvent, event.Name(), int(event.TypeIndex()),
+			event.Fields()); err != nil {
+			return nil, err
+		}
+	}
+	for _, command := range root.Commands() {
+		if err := r.addEntry(codec, definition.DefinitionKindCommand, command.Name(), int(command.TypeIndex()),
+			command.Fields()); err != nil {
+			return nil, err
+		}
+	}
+	for _, buffer := range root.Buffers() {
+		if err := r.addEntry(codec, definition.DefinitionKindBuffer, buffer.Name(), int(buffer.TypeIndex()),
+			buffer.Fields()); err != nil {
+			return nil, err
+		}
+	}
+
+	snapshots := snapshot.NewCodec(root)
+	for _, archetype := range root.Archetypes() {
+		entry := &ArchetypeEntry{name: archetype.Name()}
+		for level := range archetype.Lods() {
+			size, sizeErr := snapshots.Size(archetype, level)
+			if sizeErr != nil {
+				return nil, fmt.Errorf("archetype '%v': %v", archetype.Name(), sizeErr)
+			}
+			entry.lods = append(entry.lods, &LodEntry{level: level, size: size, overBudget: budget.Exceeded(size)})
+		}
+		r.archetypes = append(r.archetypes, entry)
+	}
+
+	return r, nil
+}
+
+func overBudgetText(overBudget bool) string {
+	if overBudget {
+		return "over budget"
+	}
+	return ""
+}
+
+// Write writes the report as a table. Sizes are in bits.
+func (r *Report) Write(writer io.Writer) error {
+	table := tabwriter.NewWriter(writer, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(table, "kind\tname\tindex\tmin\tmax\ttypical\n")
+	for _, entry := range r.entries {
+		fmt.Fprintf(table, "%v\t%v\t%d\t%d\t%d\t%d\t%v\n", entry.kind, entry.name, entry.index,
+			entry.size.Min, entry.size.Max, entry.size.Typical, overBudgetText(entry.overBudget))
+	}
+	for _, archetype := range r.archetypes {
+		for _, lod := range archetype.lods {
+			fmt.Fprintf(table, "%v\t%v\tlod%d\t%d\t%d\t%d\t%v\n", definition.DefinitionKindArchetype, archetype.name,
+				lod.level, lod.size.Min, lod.size.Max, lod.size.Typical, overBudgetText(lod.overBudget))
+		}
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+	if r.budget.Bits > 0 {
+		_, err := fmt.Fprintf(writer, "\nbudget: %d bits (%v), %d over budget\n", r.budget.Bits, r.budget.Measure,
+			r.OverBudgetCount())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package estimate
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/piot/scrawl-go/src/definition"
+	"github.com/piot/scrawl-go/src/parser"
+	"github.com/piot/scrawl-go/src/scrawl"
+	"github.com/piot/scrawl-go/src/serialize"
+)
+
+const testProtocol = `
+component Health
+  value uint8 [min "0" max "100"]
+
+component Inventory
+  items uint16 [capacity "4"]
+
+event Chat
+  text string
+
+command Jump
+  force float32 [min "0" max "10" precision "0.5"]
+
+archetype Player
+  lod 0
+    WorldPosition
+    Health
+    Inventory
+  lod 1 from 0 without Inventory
+`
+
+func setupReport(t *testing.T, budget Budget) *Report {
+	root, err := scrawl.ParseStringWithOptions(testProtocol, parser.Options{AllowedComponentFields: []string{"WorldPosition"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, reportErr := NewReport(root, budget)
+	if reportErr != nil {
+		t.Fatal(reportErr)
+	}
+	return report
+}
+
+func TestSizes(t *testing.T) {
+	report := setupReport(t, Budget{})
+	expected := []serialize.Size{
+		{Min: 7, Max: 7, Typical: 7},
+		{Min: 3, Max: 3 + 4*16, Typical: 3 + 2*16},
+		{Min: 16, Max: 16 + 65535*8, Typical: 16 + 8*8},
+		{Min: 5, Max: 5, Typical: 5},
+	}
+	if len(report.Entries()) != len(expected) {
+		t.Fatalf("wrong entry count %d", len(report.Entries()))
+	}
+	for index, entry := range report.Entries() {
+		if entry.Size() != expected[index] {
+			t.Errorf("%v: wrong size %v", entry.Name(), entry.Size())
+		}
+	}
+	if report.Entries()[2].Kind() != definition.DefinitionKindEvent {
+		t.Errorf("wrong kind %v", report.Entries()[2].Kind())
+	}
+
+	lods := report.Archetypes()[0].Lods()
+	if len(lods) != 2 {
+		t.Fatalf("wrong lod count %d", len(lods))
+	}
+	if lods[0].Size().Max != 1+1+7+1+3+4*16 || lods[1].Size().Max != 1+1+7 {
+		t.Errorf("wrong lod sizes %v %v", lods[0].Size(), lods[1].Size())
+	}
+}
+
+func TestBudget(t *testing.T) {
+	report := setupReport(t, Budget{Bits: 40, Measure: MeasureTypical})
+	if report.OverBudgetCount() != 2 {
+		t.Errorf("wrong over budget count %d", report.OverBudgetCount())
+	}
+	if !report.Entries()[2].OverBudget() || report.Entries()[1].OverBudget() {
+		t.Errorf("wrong entries over budget")
+	}
+
+	var output bytes.Buffer
+	if err := report.Write(&output); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(output.String(), "over budget") != 3 {
+		t.Errorf("wrong report:\n%v", output.String())
+	}
+}
+
+func TestParseMeasure(t *testing.T) {
+	measure, err := ParseMeasure("typical")
+	if err != nil || measure != MeasureTypical {
+		t.Errorf("wrong measure %v %v", measure, err)
+	}
+	if _, err := ParseMeasure("average"); err == nil {
+		t.Errorf("should fail for unknown measure")
+	}
+}
@@ -0,0 +1,178 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package handshake
+
+import (
+	"fmt"
+
+	"github.com/piot/scrawl-go/src/definition"
+)
+
+type Status uint8
+
+const (
+	// Identical peers have the same schema hash.
+	Identical Status = iota
+	// Compatible peers only differ in definitions that one of them does not have.
+	Compatible
+	// Incompatible peers use different hash algorithms, namespaces or names, or have
+	// definitions that differ.
+	Incompatible
+)
+
+func (s Status) String() string {
+	switch s {
+	case Identical:
+		return "identical"
+	case Compatible:
+		return "compatible"
+	case Incompatible:
+		return "incompatible"
+	}
+	return fmt.Sprintf("unknown-status-%d", uint8(s))
+}
+
+type Change uint8
+
+const (
+	OnlyLocal Change = iota
+	OnlyRemote
+	Changed
+)
+
+func (c Change) String() string {
+	switch c {
+	case OnlyLocal:
+		return "only local"
+	case OnlyRemote:
+		return "only remote"
+	case Changed:
+		return "changed"
+	}
+	return fmt.Sprintf("unknown-change-%d", uint8(c))
+}
+
+// Difference is a definition that is not the same for both peers.
+type Difference struct {
+	kind   definition.DefinitionKind
+	name   string
+	change Change
+}
+
+func (d *Difference) Kind() definition.DefinitionKind {
+	return d.kind
+}
+
+func (d *Difference) Name() string {
+	return d.name
+}
+
+func (d *Difference) Change() Change {
+	return d.change
+}
+
+func (d *Difference) String() string {
+	return fmt.Sprintf("%v '%v' %v", d.kind, d.name, d.change)
+}
+
+type Result struct {
+	status      Status
+	reasons     []string
+	differences []*Difference
+}
+
+func (r *Result) Status() Status {
+	return r.status
+}
+
+// Reasons describes why the peers are not identical.
+func (r *Result) Reasons() []string {
+	return r.reasons
+}
+
+func (r *Result) Differences() []*Difference {
+	return r.differences
+}
+
+func (r *Result) String() string {
+	return fmt.Sprintf("[handshake %v %v]", r.status, r.reasons)
+}
+
+func (r *Result) incompatible(format string, args ...interface{}) {
+	r.status = Incompatible
+	r.reasons = append(r.reasons, fmt.Sprintf(format, args...))
+}
+
+func (r *Result) difference(kind definition.DefinitionKind, name string, change Change) {
+	if change == Changed {
+		r.status = Incompatible
+	} else if r.status == Identical {
+		r.status = Compatible
+	}
+	difference := &Difference{kind: kind, name: name, change: change}
+	r.differences = append(r.differences, difference)
+	r.reasons = append(r.reasons, difference.String())
+}
+
+// Compare checks the fingerprint of the remote peer against the local one.
+func Compare(local *Fingerprint, remote *Fingerprint) *Result {
+	result := &Result{status: Identical}
+
+	if local.hash.Algorithm() != remote.hash.Algorithm() {
+		result.incompatible("hash algorithm %v differs from remote %v", local.hash.Algorithm(), remote.hash.Algorithm())
+		return result
+	}
+	if local.hash == remote.hash {
+		return result
+	}
+	if local.namespace != remote.namespace {
+		result.incompatible("namespace '%v' differs from remote '%v'", local.namespace, remote.namespace)
+	}
+	if local.name != remote.name {
+		result.incompatible("name '%v' differs from remote '%v'", local.name, remote.name)
+	}
+
+	for _, localHash := range local.definitions {
+		remoteHash := remote.find(localHash.Kind(), localHash.Name())
+		if remoteHash == nil {
+			result.difference(localHash.Kind(), localHash.Name(), OnlyLocal)
+		} else if remoteHash.Hash() != localHash.Hash() {
+			result.difference(localHash.Kind(), localHash.Name(), Changed)
+		}
+	}
+	for _, remoteHash := range remote.definitions {
+		if local.find(remoteHash.Kind(), remoteHash.Name()) == nil {
+			result.difference(remoteHash.Kind(), remoteHash.Name(), OnlyRemote)
+		}
+	}
+
+	if result.status == Identical {
+		result.incompatible("schema hash %v differs from remote %v", local.hash, remote.hash)
+	}
+
+	return result
+}
@@ -0,0 +1,94 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package handshake
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/piot/scrawl-go/src/definition"
+)
+
+// MaxFingerprintOctetCount limits the size of a fingerprint read from a peer.
+const MaxFingerprintOctetCount = 1024 * 1024
+
+// WriteFingerprint writes a fingerprint prefixed with its length as four octets, big-endian.
+func WriteFingerprint(writer io.Writer, fingerprint *Fingerprint) error {
+	octets, marshalErr := fingerprint.MarshalBinary()
+	if marshalErr != nil {
+		return marshalErr
+	}
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(octets)))
+	if _, err := writer.Write(length[:]); err != nil {
+		return err
+	}
+	_, writeErr := writer.Write(octets)
+	return writeErr
+}
+
+func ReadFingerprint(reader io.Reader) (*Fingerprint, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(reader, length[:]); err != nil {
+		return nil, err
+	}
+	octetCount := binary.BigEndian.Uint32(length[:])
+	if octetCount > MaxFingerprintOctetCount {
+		return nil, fmt.Errorf("fingerprint of %d octets is too large", octetCount)
+	}
+	octets := make([]byte, octetCount)
+	if _, err := io.ReadFull(reader, octets); err != nil {
+		return nil, err
+	}
+	fingerprint := &Fingerprint{}
+	if err := fingerprint.UnmarshalBinary(octets); err != nil {
+		return nil, err
+	}
+	return fingerprint, nil
+}
+
+// Exchange sends the fingerprint of root to the peer, reads the fingerprint of the peer and compares them.
+// Both peers can call it at the same time.
+func Exchange(connection io.ReadWriter, root *definition.Root) (*Result, error) {
+	local := NewFingerprint(root)
+	written := make(chan error, 1)
+	go func() {
+		written <- WriteFingerprint(connection, local)
+	}()
+
+	remote, readErr := ReadFingerprint(connection)
+	writeErr := <-written
+	if writeErr != nil {
+		return nil, writeErr
+	}
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	return Compare(local, remote), nil
+}
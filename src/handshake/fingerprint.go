@@ -0,0 +1,182 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package handshake
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/piot/scrawl-go/src/definition"
+	"github.com/piot/scrawl-go/src/scrawlhash"
+)
+
+// FingerprintVersion is the first octet of a serialized fingerprint.
+const FingerprintVersion = 1
+
+// Fingerprint is what a peer knows about its protocol: the hash of the whole schema, the namespace,
+// the name and the hash of every definition.
+type Fingerprint struct {
+	hash        definition.Hash
+	namespace   string
+	name        string
+	definitions []*definition.DefinitionHash
+}
+
+func NewFingerprint(root *definition.Root) *Fingerprint {
+	return &Fingerprint{hash: root.Hash(), namespace: root.Namespace(), name: root.Name(),
+		definitions: root.DefinitionHashes()}
+}
+
+func (f *Fingerprint) Hash() definition.Hash {
+	return f.hash
+}
+
+func (f *Fingerprint) Namespace() string {
+	return f.namespace
+}
+
+func (f *Fingerprint) Name() string {
+	return f.name
+}
+
+func (f *Fingerprint) DefinitionHashes() []*definition.DefinitionHash {
+	return f.definitions
+}
+
+func (f *Fingerprint) find(kind definition.DefinitionKind, name string) *definition.DefinitionHash {
+	for _, definitionHash := range f.definitions {
+		if definitionHash.Kind() == kind && definitionHash.Name() == name {
+			return definitionHash
+		}
+	}
+	return nil
+}
+
+func writeString(buffer *bytes.Buffer, s string) {
+	var octets [binary.MaxVarintLen64]byte
+	count := binary.PutUvarint(octets[:], uint64(len(s)))
+	buffer.Write(octets[:count])
+	buffer.WriteString(s)
+}
+
+func readString(reader *bytes.Reader) (string, error) {
+	length, lengthErr := binary.ReadUvarint(reader)
+	if lengthErr != nil {
+		return "", lengthErr
+	}
+	if length > uint64(reader.Len()) {
+		return "", io.ErrUnexpectedEOF
+	}
+	octets := make([]byte, length)
+	reader.Read(octets)
+	return string(octets), nil
+}
+
+// MarshalBinary writes the version, the hash algorithm, the schema hash, namespace, name and the
+// kind, name and hash of every definition. Strings and hashes are prefixed with their length as an
+// unsigned varint.
+func (f *Fingerprint) MarshalBinary() ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteByte(FingerprintVersion)
+	buffer.WriteByte(byte(f.hash.Algorithm()))
+	writeString(&buffer, string(f.hash.Octets()))
+	writeString(&buffer, f.namespace)
+	writeString(&buffer, f.name)
+
+	var octets [binary.MaxVarintLen64]byte
+	count := binary.PutUvarint(octets[:], uint64(len(f.definitions)))
+	buffer.Write(octets[:count])
+	for _, definitionHash := range f.definitions {
+		if definitionHash.Hash().Algorithm() != f.hash.Algorithm() {
+			return nil, fmt.Errorf("%v '%v' uses a different hash algorithm", definitionHash.Kind(), definitionHash.Name())
+		}
+		buffer.WriteByte(byte(definitionHash.Kind()))
+		writeString(&buffer, definitionHash.Name())
+		writeString(&buffer, string(definitionHash.Hash().Octets()))
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func (f *Fingerprint) UnmarshalBinary(octets []byte) error {
+	reader := bytes.NewReader(octets)
+	version, versionErr := reader.ReadByte()
+	if versionErr != nil {
+		return io.ErrUnexpectedEOF
+	}
+	if version != FingerprintVersion {
+		return fmt.Errorf("unsupported fingerprint version %d", version)
+	}
+	algorithmOctet, algorithmErr := reader.ReadByte()
+	if algorithmErr != nil {
+		return io.ErrUnexpectedEOF
+	}
+	algorithm := scrawlhash.Algorithm(algorithmOctet)
+
+	var texts [3]string
+	for index := range texts {
+		s, stringErr := readString(reader)
+		if stringErr != nil {
+			return stringErr
+		}
+		texts[index] = s
+	}
+
+	count, countErr := binary.ReadUvarint(reader)
+	if countErr != nil {
+		return countErr
+	}
+	var definitions []*definition.DefinitionHash
+	for index := uint64(0); index < count; index++ {
+		kind, kindErr := reader.ReadByte()
+		if kindErr != nil {
+			return io.ErrUnexpectedEOF
+		}
+		name, nameErr := readString(reader)
+		if nameErr != nil {
+			return nameErr
+		}
+		hash, hashErr := readString(reader)
+		if hashErr != nil {
+			return hashErr
+		}
+		definitions = append(definitions, definition.NewDefinitionHash(definition.DefinitionKind(kind), name,
+			scrawlhash.NewDigest(algorithm, []byte(hash))))
+	}
+	if reader.Len() != 0 {
+		return fmt.Errorf("%d octets left after fingerprint", reader.Len())
+	}
+
+	f.hash = scrawlhash.NewDigest(algorithm, []byte(texts[0]))
+	f.namespace = texts[1]
+	f.name = texts[2]
+	f.definitions = definitions
+
+	return nil
+}
@@ -0,0 +1,138 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package handshake
+
+import (
+	"net"
+	"testing"
+
+	"github.com/piot/scrawl-go/src/definition"
+	"github.com/piot/scrawl-go/src/parser"
+	"github.com/piot/scrawl-go/src/scrawl"
+	"github.com/piot/scrawl-go/src/scrawlhash"
+)
+
+const baseProtocol = `
+name Arena
+namespace Game
+
+component Health
+  value uint8
+
+event Jump
+  height int16
+`
+
+func setupRoot(t *testing.T, text string, algorithm scrawlhash.Algorithm) *definition.Root {
+	root, err := scrawl.ParseStringWithOptions(text, parser.Options{HashAlgorithm: algorithm})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestFingerprintRoundTrip(t *testing.T) {
+	root := setupRoot(t, baseProtocol, scrawlhash.XXHash64)
+	fingerprint := NewFingerprint(root)
+	octets, err := fingerprint.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := &Fingerprint{}
+	if err := loaded.UnmarshalBinary(octets); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Hash() != root.Hash() || loaded.Name() != "Arena" || loaded.Namespace() != "Game" ||
+		len(loaded.DefinitionHashes()) != 2 {
+		t.Errorf("wrong fingerprint %v", loaded)
+	}
+
+	if err := loaded.UnmarshalBinary(octets[:len(octets)-1]); err == nil {
+		t.Errorf("truncated fingerprint should be reported")
+	}
+}
+
+func TestCompare(t *testing.T) {
+	local := NewFingerprint(setupRoot(t, baseProtocol, scrawlhash.FNV32a))
+
+	identical := Compare(local, NewFingerprint(setupRoot(t, baseProtocol, scrawlhash.FNV32a)))
+	if identical.Status() != Identical || len(identical.Reasons()) != 0 {
+		t.Errorf("should be identical %v", identical)
+	}
+
+	added := Compare(local, NewFingerprint(setupRoot(t, baseProtocol+"\nevent Dance\n  style uint8\n", scrawlhash.FNV32a)))
+	if added.Status() != Compatible || len(added.Differences()) != 1 || added.Differences()[0].Change() != OnlyRemote ||
+		added.Differences()[0].Name() != "Dance" {
+		t.Errorf("should be compatible %v", added)
+	}
+
+	changedProtocol := "name Arena\nnamespace Game\n\ncomponent Health\n  value uint16\n\nevent Jump\n  height int16\n"
+	changed := Compare(local, NewFingerprint(setupRoot(t, changedProtocol, scrawlhash.FNV32a)))
+	if changed.Status() != Incompatible || changed.Differences()[0].Kind() != definition.DefinitionKindComponent {
+		t.Errorf("should be incompatible %v", changed)
+	}
+
+	renamed := Compare(local, NewFingerprint(setupRoot(t, "name Other\n"+baseProtocol[len("\nname Arena\n"):], scrawlhash.FNV32a)))
+	if renamed.Status() != Incompatible {
+		t.Errorf("different name should be incompatible %v", renamed)
+	}
+
+	algorithm := Compare(local, NewFingerprint(setupRoot(t, baseProtocol, scrawlhash.SHA256)))
+	if algorithm.Status() != Incompatible {
+		t.Errorf("different algorithm should be incompatible %v", algorithm)
+	}
+}
+
+func TestLoopback(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	serverRoot := setupRoot(t, baseProtocol+"\nevent Dance\n  style uint8\n", scrawlhash.FNV32a)
+	serverResult := make(chan *Result, 1)
+	go func() {
+		result, err := Exchange(server, serverRoot)
+		if err != nil {
+			t.Error(err)
+		}
+		serverResult <- result
+	}()
+
+	result, err := Exchange(client, setupRoot(t, baseProtocol, scrawlhash.FNV32a))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status() != Compatible || result.Differences()[0].Change() != OnlyRemote {
+		t.Errorf("wrong client result %v", result)
+	}
+
+	fromServer := <-serverResult
+	if fromServer == nil || fromServer.Status() != Compatible || fromServer.Differences()[0].Change() != OnlyLocal {
+		t.Errorf("wrong server result %v", fromServer)
+	}
+}
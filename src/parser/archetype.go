@@ -3,7 +3,7 @@ package parser
 import "github.com/piot/scrawl-go/src/definition"
 
 func (p *Parser) parseGenericArchetype() (*definition.EntityArchetype, error) {
-	name, meta, nameErr := p.parseArchetypeNameAndStartScope()
+	name, _, meta, _, nameErr := p.parseArchetypeNameAndStartScope()
 	if nameErr != nil {
 		return nil, nameErr
 	}
@@ -26,15 +26,82 @@ SOFTWARE.
 
 package parser
 
-import "github.com/piot/scrawl-go/src/definition"
+import (
+	"fmt"
+
+	"github.com/piot/scrawl-go/src/definition"
+)
 
 func (p *Parser) parseComponentDataType(index uint8) (*definition.ComponentDataType, error) {
-	name, meta, fields, err := p.parseNameOptionalMetaAndFields()
+	name, baseName, afterName, err := p.parseNameAndOptionalBase()
 	if err != nil {
 		return nil, err
 	}
 
+	meta, wasStartScope, metaErr := p.parseOptionalMetaAndStartScope(afterName)
+	if metaErr != nil {
+		return nil, metaErr
+	}
+
+	var fields []*definition.Field
+	if wasStartScope {
+		var fieldsErr error
+		fields, fieldsErr = p.parseFieldsUntilEndScope()
+		if fieldsErr != nil {
+			return nil, fieldsErr
+		}
+	}
+
 	component := definition.NewComponentDataType(name, index, fields, meta)
+	if baseName != "" {
+		component.SetBaseName(baseName)
+	}
 
 	return component, nil
 }
+
+func (p *Parser) extendComponent(component *definition.ComponentDataType, extended map[*definition.ComponentDataType]bool,
+	visiting map[*definition.ComponentDataType]bool) error {
+	if extended[component] || component.BaseName() == "" {
+		return nil
+	}
+
+	if visiting[component] {
+		return ParserError{err: fmt.Errorf("component '%v' extends itself", component.Name()),
+			position: component.Position()}
+	}
+	visiting[component] = true
+
+	base := p.root.FindComponentDataType(component.BaseName())
+	if base == nil {
+		return ParserError{err: fmt.Errorf("unknown base component '%v' for '%v'", component.BaseName(), component.Name()),
+			position: component.Position()}
+	}
+
+	baseErr := p.extendComponent(base, extended, visiting)
+	if baseErr != nil {
+		return baseErr
+	}
+
+	extendErr := component.Extend(base)
+	if extendErr != nil {
+		return ParserError{err: extendErr, position: component.Position()}
+	}
+
+	extended[component] = true
+
+	return nil
+}
+
+func (p *Parser) extendComponents() error {
+	extended := make(map[*definition.ComponentDataType]bool)
+	for _, component := range p.root.ComponentDataTypes() {
+		visiting := make(map[*definition.ComponentDataType]bool)
+		err := p.extendComponent(component, extended, visiting)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
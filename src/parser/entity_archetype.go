@@ -33,27 +33,77 @@ import (
 	"github.com/piot/scrawl-go/src/token"
 )
 
-func (p *Parser) parseEntityArchetype(entityIndex definition.EntityIndex) (*definition.EntityArchetype, error) {
+// inheritLod creates a copy of a level of detail in the base archetype. A level that was derived in the
+// base is derived again from the same level in lods, so items added there are also inherited.
+func inheritLod(base *definition.EntityArchetype, baseLod *definition.EntityArchetypeLOD,
+	lods []*definition.EntityArchetypeLOD) (*definition.EntityArchetypeLOD, error) {
+	var lod *definition.EntityArchetypeLOD
+	if baseLod.DerivedFrom() == -1 {
+		lod = definition.NewEntityArchetypeLOD(baseLod.Level(), baseLod.Items())
+	} else {
+		baseSource := base.Lod(baseLod.DerivedFrom())
+		source := lods[baseLod.DerivedFrom()]
+		var without []string
+		for _, item := range baseSource.Items() {
+			if baseLod.FindItem(item.Name()) == nil && source.FindItem(item.Name()) != nil {
+				without = append(without, item.Name())
+			}
+		}
+		var added []*definition.EntityArchetypeItem
+		for _, item := range baseLod.Items() {
+			if baseSource.FindItem(item.Name()) == nil && source.FindItem(item.Name()) == nil {
+				added = append(added, item)
+			}
+		}
+		var err error
+		lod, err = definition.NewEntityArchetypeLODDerived(baseLod.Level(), source, without, added)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := lod.SetMeta(baseLod.Meta()); err != nil {
+		return nil, err
+	}
+	return lod, nil
+}
 
-	name, meta, nameErr := p.parseArchetypeNameAndStartScope()
+func (p *Parser) parseEntityArchetype(entityIndex definition.EntityIndex) (*definition.EntityArchetype, error) {
+	name, baseName, meta, wasStartScope, nameErr := p.parseArchetypeNameAndStartScope()
 	if nameErr != nil {
 		return nil, nameErr
 	}
 
+	var base *definition.EntityArchetype
+	if baseName != "" {
+		base = p.root.FindEntity(baseName)
+		if base == nil {
+			return nil, fmt.Errorf("unknown base archetype '%v'. It must be declared before '%v'", baseName, name)
+		}
+	}
+
 	var lods []*definition.EntityArchetypeLOD
 	expectedLevel := 0
 
-	for {
+	for wasStartScope {
 		t, tErr := p.readNext()
 		if tErr != nil {
 			return nil, tErr
 		}
 
+		if t == nil {
+			break
+		}
+
 		_, isEndOfScope := t.(token.EndScopeToken)
 		if isEndOfScope {
 			break
 		}
 
+		_, isLineDelimiter := t.(token.LineDelimiterToken)
+		if isLineDelimiter {
+			continue
+		}
+
 		symbol, isSymbol := t.(token.SymbolToken)
 		if !isSymbol {
 			return nil, fmt.Errorf("expected end of scope or 'lod' %v", t)
@@ -63,7 +113,7 @@ func (p *Parser) parseEntityArchetype(entityIndex definition.EntityIndex) (*defi
 			return nil, fmt.Errorf("expected 'lod' %v", symbol)
 		}
 
-		lod, err := p.parseLod(p.validComponentFields, p.validComponentTypes)
+		lod, err := p.parseLod(base, lods)
 		if err != nil {
 			return nil, err
 		}
@@ -76,7 +126,18 @@ func (p *Parser) parseEntityArchetype(entityIndex definition.EntityIndex) (*defi
 		expectedLevel++
 	}
 
+	if base != nil {
+		for level := len(lods); level < len(base.Lods()); level++ {
+			lod, err := inheritLod(base, base.Lod(level), lods)
+			if err != nil {
+				return nil, err
+			}
+			lods = append(lods, lod)
+		}
+	}
+
 	entity := definition.NewEntityArchetype(name, entityIndex, lods, meta)
+	entity.SetBase(base)
 
 	return entity, nil
 }
@@ -33,6 +33,7 @@ import (
 )
 
 func (p *Parser) parseField(index int, name string) (*definition.Field, error) {
+	namePosition := p.lastToken.Position()
 	fieldType, fieldTypeErr := p.parseSymbol()
 	if fieldTypeErr != nil {
 		return &definition.Field{}, fmt.Errorf("Expected a field symbol (%v)", fieldTypeErr)
@@ -43,6 +44,14 @@ func (p *Parser) parseField(index int, name string) (*definition.Field, error) {
 		return nil, metaErr
 	}
 
+	if metaData.Field("capacity") != "" {
+		capacity, capacityErr := metaData.Int("capacity")
+		if capacityErr != nil || capacity <= 0 {
+			return nil, fmt.Errorf("field '%v' has illegal capacity '%v'", name, metaData.Field("capacity"))
+		}
+	}
+
 	field := definition.NewField(index, name, fieldType, metaData)
+	field.SetPosition(namePosition)
 	return field, nil
 }
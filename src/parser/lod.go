@@ -27,19 +27,108 @@ SOFTWARE.
 package parser
 
 import (
+	"fmt"
+
 	"github.com/piot/scrawl-go/src/definition"
+	"github.com/piot/scrawl-go/src/token"
 )
 
-func (p *Parser) parseLod(validComponentFields []string, validComponentTypes []string) (*definition.EntityArchetypeLOD, error) {
-	lodLevel, err := p.parseIntegerAndStartScope()
+// parseLodHeader parses `lod <level> [from <level>] [without <item>...] [meta]`. Source level is -1 when
+// there is no 'from'. Returns true if the header is followed by a scope with items.
+func (p *Parser) parseLodHeader() (int, int, []string, definition.MetaData, bool, error) {
+	var meta definition.MetaData
+	lodLevel, err := p.parseInteger()
+	if err != nil {
+		return 0, 0, nil, meta, false, err
+	}
+
+	sourceLevel := -1
+	var without []string
+	removing := false
+
+	for {
+		t, tErr := p.readNext()
+		if tErr != nil {
+			return 0, 0, nil, meta, false, tErr
+		}
+
+		switch ct := t.(type) {
+		case nil, token.LineDelimiterToken:
+			return lodLevel, sourceLevel, without, meta, false, nil
+		case token.StartScopeToken:
+			return lodLevel, sourceLevel, without, meta, true, nil
+		case token.StartMetaDataToken:
+			meta, err = p.parseMetaData()
+			if err != nil {
+				return 0, 0, nil, meta, false, err
+			}
+			removing = false
+		case token.SymbolToken:
+			if removing {
+				without = append(without, ct.Symbol)
+				continue
+			}
+			switch ct.Symbol {
+			case "from":
+				if sourceLevel != -1 {
+					return 0, 0, nil, meta, false, fmt.Errorf("lod%d can only be derived from one lod", lodLevel)
+				}
+				sourceLevel, err = p.parseInteger()
+				if err != nil {
+					return 0, 0, nil, meta, false, err
+				}
+			case "without":
+				if sourceLevel == -1 {
+					return 0, 0, nil, meta, false, fmt.Errorf("'without' in lod%d must follow 'from'", lodLevel)
+				}
+				removing = true
+			default:
+				return 0, 0, nil, meta, false, fmt.Errorf("expected 'from', 'without' or start of scope %v", ct)
+			}
+		default:
+			return 0, 0, nil, meta, false, fmt.Errorf("unexpected token in lod%d %v", lodLevel, t)
+		}
+	}
+}
+
+func (p *Parser) parseLod(base *definition.EntityArchetype, previousLods []*definition.EntityArchetypeLOD) (*definition.EntityArchetypeLOD, error) {
+	lodLevel, sourceLevel, without, meta, wasStartScope, err := p.parseLodHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	var entityArchetypeItems []*definition.EntityArchetypeItem
+	if wasStartScope {
+		var entityArchetypeItemsErr error
+		entityArchetypeItems, entityArchetypeItemsErr = p.parseEntityArchetypeItemsUntilEndScope()
+		if entityArchetypeItemsErr != nil {
+			return nil, entityArchetypeItemsErr
+		}
+	}
+
+	var lod *definition.EntityArchetypeLOD
+	if sourceLevel != -1 {
+		if sourceLevel >= len(previousLods) {
+			return nil, fmt.Errorf("lod%d can only be derived from a previous lod, not lod%d", lodLevel, sourceLevel)
+		}
+		lod, err = definition.NewEntityArchetypeLODDerived(lodLevel, previousLods[sourceLevel], without, entityArchetypeItems)
+	} else if base != nil && lodLevel < len(base.Lods()) {
+		baseLod := base.Lod(lodLevel)
+		if meta.IsNil() {
+			meta = baseLod.Meta()
+		}
+		lod, err = definition.NewEntityArchetypeLODExtending(lodLevel, baseLod.Items(), nil, entityArchetypeItems)
+	} else {
+		lod = definition.NewEntityArchetypeLOD(lodLevel, entityArchetypeItems)
+	}
 	if err != nil {
 		return nil, err
 	}
-	entityArchetypeItems, entityArchetypeItemsErr := p.parseEntityArchetypeItemsUntilEndScope()
 
-	if entityArchetypeItemsErr != nil {
-		return nil, entityArchetypeItemsErr
+	metaErr := lod.SetMeta(meta)
+	if metaErr != nil {
+		return nil, metaErr
 	}
 
-	return definition.NewEntityArchetypeLOD(lodLevel, entityArchetypeItems), nil
+	return lod, nil
 }
@@ -29,8 +29,6 @@ package parser
 import (
 	"fmt"
 
-	"github.com/piot/scrawl-go/src/beautify"
-
 	"github.com/piot/scrawl-go/src/scrawlhash"
 	"github.com/piot/scrawl-go/src/token"
 
@@ -47,8 +45,17 @@ func (f ParserError) Error() string {
 	return fmt.Sprintf("%v at %v", f.err, f.position)
 }
 
+type Options struct {
+	AllowedComponentFields []string
+	AllowedComponentTypes  []string
+	HashAlgorithm          scrawlhash.Algorithm
+	ValidateLodSubsets     bool
+}
+
 type Parser struct {
 	tokenizer            *tokenize.Tokenizer
+	hashAlgorithm        scrawlhash.Algorithm
+	validateLodSubsets   bool
 	root                 *definition.Root
 	lastToken            token.Token
 	lastEntity           *definition.EntityArchetype
@@ -88,6 +95,7 @@ func (p *Parser) next() (bool, error) {
 	}
 	symbolToken, wasSymbol := t.(token.SymbolToken)
 	if wasSymbol {
+		position := symbolToken.Position()
 		switch symbolToken.Symbol {
 		case "namespace":
 			namespace, namespaceErr := p.parseNamespace()
@@ -109,12 +117,14 @@ func (p *Parser) next() (bool, error) {
 			if err != nil {
 				return false, err
 			}
+			component.SetPosition(position)
 			p.root.AddComponentDataType(component)
 		case "type":
 			userType, err := p.parseUserType()
 			if err != nil {
 				return false, err
 			}
+			userType.SetPosition(position)
 			p.root.AddUserType(userType)
 
 		case "archetype":
@@ -124,6 +134,13 @@ func (p *Parser) next() (bool, error) {
 			if err != nil {
 				return false, err
 			}
+			if p.validateLodSubsets {
+				validateErr := entity.ValidateLods()
+				if validateErr != nil {
+					return false, validateErr
+				}
+			}
+			entity.SetPosition(position)
 			p.root.AddArchetype(entity)
 			p.lastEntity = entity
 		case "event":
@@ -133,6 +150,7 @@ func (p *Parser) next() (bool, error) {
 				if err != nil {
 					return false, err
 				}
+				event.SetPosition(position)
 				p.root.AddEvent(event)
 			}
 		case "command":
@@ -142,6 +160,7 @@ func (p *Parser) next() (bool, error) {
 				if err != nil {
 					return false, err
 				}
+				method.SetPosition(position)
 				p.root.AddMethod(method)
 			}
 
@@ -152,6 +171,7 @@ func (p *Parser) next() (bool, error) {
 				if err != nil {
 					return false, err
 				}
+				method.SetPosition(position)
 				p.root.AddBuffer(method)
 			}
 		case "enum":
@@ -159,6 +179,7 @@ func (p *Parser) next() (bool, error) {
 			if err != nil {
 				return false, err
 			}
+			enum.SetPosition(position)
 			p.root.AddEnum(enum)
 
 		default:
@@ -178,31 +199,30 @@ func (p *Parser) Root() *definition.Root {
 	return p.root
 }
 
-func calculateHash(text string) (uint32, error) {
-	hashTokens, fetchErr := tokenize.FetchAllTokens(text)
-	if fetchErr != nil {
-		return 0, fetchErr
+func setHash(root *definition.Root, algorithm scrawlhash.Algorithm) {
+	var definitionHashes []*definition.DefinitionHash
+	for _, canonical := range root.CanonicalDefinitions() {
+		hashValue := scrawlhash.Calculate(algorithm, canonical.Octets())
+		definitionHashes = append(definitionHashes, definition.NewDefinitionHash(canonical.Kind(), canonical.Name(), hashValue))
 	}
-	beautified := beautify.Process(hashTokens, beautify.DiscardComments)
-	//fmt.Printf("beautified:\n%s\n", beautified)
-	hashValue := scrawlhash.CalculateHash([]byte(beautified))
-	return hashValue, nil
-}
+	root.SetDefinitionHashes(definitionHashes)
 
-func setHash(root *definition.Root, text string) error {
-	hashValue, calculateErr := calculateHash(text)
-	if calculateErr != nil {
-		return calculateErr
-	}
-	definitionHash := definition.Hash(hashValue)
-	root.SetHash(definitionHash)
-	return nil
+	root.SetHash(scrawlhash.Calculate(algorithm, root.CanonicalOctets()))
 }
 
 func ParseToRoot(root *definition.Root, text string, allowedComponentFields []string, allowedComponentTypes []string) (*Parser, error) {
+	return ParseToRootWithOptions(root, text, Options{AllowedComponentFields: allowedComponentFields,
+		AllowedComponentTypes: allowedComponentTypes})
+}
+
+func ParseToRootWithOptions(root *definition.Root, text string, options Options) (*Parser, error) {
+	if !options.HashAlgorithm.IsValid() {
+		return nil, fmt.Errorf("unknown hash algorithm %v", options.HashAlgorithm)
+	}
 	tokenizer := tokenize.SetupTokenizer(text)
-	parser := &Parser{tokenizer: tokenizer, root: root, validComponentTypes: allowedComponentTypes,
-		validComponentFields: allowedComponentFields}
+	parser := &Parser{tokenizer: tokenizer, root: root, validComponentTypes: options.AllowedComponentTypes,
+		validComponentFields: options.AllowedComponentFields, hashAlgorithm: options.HashAlgorithm,
+		validateLodSubsets: options.ValidateLodSubsets}
 	done := false
 	var err error
 	err = nil
@@ -226,14 +246,20 @@ func ParseToRoot(root *definition.Root, text string, allowedComponentFields []st
 		return nil, parserErr
 	}
 
-	hashErr := setHash(parser.root, text)
-	if hashErr != nil {
-		return nil, hashErr
+	extendErr := parser.extendComponents()
+	if extendErr != nil {
+		return nil, extendErr
 	}
 
+	setHash(parser.root, parser.hashAlgorithm)
+
 	return parser, nil
 }
 
 func NewParser(text string, allowedComponentFields []string, allowedComponentTypes []string) (*Parser, error) {
 	return ParseToRoot(&definition.Root{}, text, allowedComponentFields, allowedComponentTypes)
 }
+
+func NewParserWithOptions(text string, options Options) (*Parser, error) {
+	return ParseToRootWithOptions(&definition.Root{}, text, options)
+}
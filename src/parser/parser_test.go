@@ -27,9 +27,13 @@ SOFTWARE.
 package parser
 
 import (
+	"bytes"
+	"encoding/json"
+	"strings"
 	"testing"
 
 	"github.com/piot/scrawl-go/src/definition"
+	"github.com/piot/scrawl-go/src/scrawlhash"
 )
 
 func setup(x string) (*Parser, error) {
@@ -491,3 +495,691 @@ component YetAnotherComponent
 	checkEmptyComponent(t, root, "EmptyComponent")
 	checkEmptyComponent(t, root, "AnotherSignalComponent")
 }
+
+func TestSemanticHash(t *testing.T) {
+	first, err := setup(
+		`
+type Position
+  x int32
+  y int32
+
+component Tough [priority "high"]
+  strength int32 [min "0", max "100"]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := setup(
+		`
+# Only formatting, tooling meta data and type order differs
+component   Tough [priority "low"]
+  strength int32 [max "100", min "0", debug "shown in editor"]
+
+type Position
+  x   int32
+  y int32
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.Root().Hash() != second.Root().Hash() {
+		t.Errorf("hash should not depend on formatting %v vs %v", first.Root().Hash(), second.Root().Hash())
+	}
+
+	third, err := setup(
+		`
+type Position
+  x int32
+  y int32
+
+component Tough [priority "high"]
+  strength int32 [min "0", max "200"]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.Root().Hash() == third.Root().Hash() {
+		t.Errorf("hash should depend on wire meta data")
+	}
+
+	firstPosition := first.Root().FindDefinitionHash(definition.DefinitionKindUserType, "Position")
+	thirdPosition := third.Root().FindDefinitionHash(definition.DefinitionKindUserType, "Position")
+	if firstPosition.Hash() != thirdPosition.Hash() {
+		t.Errorf("unchanged type should keep its hash %v vs %v", firstPosition, thirdPosition)
+	}
+
+	firstTough := first.Root().FindDefinitionHash(definition.DefinitionKindComponent, "Tough")
+	thirdTough := third.Root().FindDefinitionHash(definition.DefinitionKindComponent, "Tough")
+	if firstTough.Hash() == thirdTough.Hash() {
+		t.Errorf("changed component should have a different hash %v", firstTough)
+	}
+}
+
+func TestHashAlgorithmOption(t *testing.T) {
+	parser, err := NewParserWithOptions(
+		`
+component Tough
+  strength int32
+`, Options{HashAlgorithm: scrawlhash.SHA256})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hash := parser.Root().Hash()
+	if hash.Algorithm() != scrawlhash.SHA256 || len(hash.Octets()) != 32 {
+		t.Errorf("wrong hash %v", hash)
+	}
+
+	componentHash := parser.Root().FindDefinitionHash(definition.DefinitionKindComponent, "Tough")
+	if componentHash.Hash().Algorithm() != scrawlhash.SHA256 {
+		t.Errorf("definition hashes should use the same algorithm %v", componentHash)
+	}
+
+	if _, err := NewParserWithOptions("component Tough\n  strength int32\n", Options{HashAlgorithm: 99}); err == nil {
+		t.Errorf("unknown hash algorithm should be reported")
+	}
+}
+
+func TestComponentExtends(t *testing.T) {
+	parser, err := setup(
+		`
+component Turret extends Building [priority "high"]
+  angle int32
+
+component Building
+  owner int32
+  team int32
+
+component Wall extends Building
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := parser.Root()
+	turret := root.FindComponentDataType("Turret")
+	if turret.Base() != root.FindComponentDataType("Building") {
+		t.Fatalf("wrong base %v", turret.Base())
+	}
+
+	fields := turret.Fields()
+	if len(fields) != 3 {
+		t.Fatalf("wrong number of flattened fields %v", fields)
+	}
+
+	if fields[0].Name() != "owner" || fields[2].Name() != "angle" || fields[2].Index() != 2 {
+		t.Errorf("wrong flattened fields %v", fields)
+	}
+
+	if len(turret.OwnFields()) != 1 {
+		t.Errorf("wrong own fields %v", turret.OwnFields())
+	}
+
+	turretMeta := turret.Meta()
+	if turretMeta.Field("priority") != "high" {
+		t.Errorf("wrong meta %v", turretMeta)
+	}
+
+	wall := root.FindComponentDataType("Wall")
+	if len(wall.Fields()) != 2 || wall.BaseName() != "Building" {
+		t.Errorf("wrong wall %v", wall)
+	}
+}
+
+func TestComponentExtendsErrors(t *testing.T) {
+	_, cycleErr := setup(
+		`
+component First extends Second
+  a int32
+
+component Second extends First
+  b int32
+`)
+	if cycleErr == nil {
+		t.Errorf("cycle should be reported")
+	}
+
+	_, shadowErr := setup(
+		`
+component Building
+  owner int32
+
+component Turret extends Building
+  owner int32
+`)
+	if shadowErr == nil {
+		t.Errorf("shadowing should be reported")
+	}
+
+	_, unknownErr := setup(
+		`
+component Turret extends Building
+  angle int32
+`)
+	if unknownErr == nil {
+		t.Errorf("unknown base should be reported")
+	}
+}
+
+func TestLodFrom(t *testing.T) {
+	parser, err := setup(
+		`
+component Animation
+  state int32
+
+component Health
+  value int32
+
+archetype Creature
+  lod 0
+    WorldPosition
+    Animation
+    Health
+  lod 1 from 0 without Animation
+  lod 2 from 1 without Health
+
+component Last
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	creature := parser.Root().FindEntity("Creature")
+	if len(creature.Lods()) != 3 {
+		t.Fatalf("wrong number of lods %v", creature)
+	}
+
+	secondLod := creature.Lod(1)
+	if len(secondLod.Items()) != 2 || secondLod.FindItem("Animation") != nil || secondLod.DerivedFrom() != 0 {
+		t.Errorf("wrong lod1 %v", secondLod)
+	}
+
+	if secondLod.Items()[1].Name() != "Health" || secondLod.Items()[1].Index() != 1 {
+		t.Errorf("wrong expanded item %v", secondLod.Items()[1])
+	}
+
+	thirdLod := creature.Lod(2)
+	if len(thirdLod.Items()) != 1 || thirdLod.Items()[0].Name() != "WorldPosition" {
+		t.Errorf("wrong lod2 %v", thirdLod)
+	}
+}
+
+func TestArchetypeExtends(t *testing.T) {
+	parser, err := setup(
+		`
+component Turret
+  angle int32
+
+component Health
+  value int32
+
+archetype Vehicle
+  lod 0 [distance "20"]
+    WorldPosition
+    Health
+  lod 1 from 0 without Health [distance "50"]
+
+archetype Tank extends Vehicle
+  lod 0
+    Turret
+
+archetype Truck extends Vehicle
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := parser.Root()
+	tank := root.FindEntity("Tank")
+	if tank.Base() != root.FindEntity("Vehicle") {
+		t.Errorf("wrong base %v", tank.Base())
+	}
+
+	if len(tank.Lods()) != 2 {
+		t.Fatalf("should inherit all lods %v", tank)
+	}
+
+	firstLod := tank.Lod(0)
+	if len(firstLod.Items()) != 3 || firstLod.Items()[2].Name() != "Turret" {
+		t.Errorf("wrong lod0 %v", firstLod)
+	}
+
+	secondLod := tank.Lod(1)
+	if len(secondLod.Items()) != 2 || secondLod.FindItem("Turret") == nil || secondLod.DerivedFrom() != 0 ||
+		secondLod.Distance() != 50 {
+		t.Errorf("lod1 should be derived from the lod0 of Tank %v", secondLod)
+	}
+
+	vehicle := root.FindEntity("Vehicle")
+	if secondLod == vehicle.Lod(1) || len(vehicle.Lod(1).Items()) != 1 || len(vehicle.Lod(0).Items()) != 2 {
+		t.Errorf("base should not change %v", vehicle)
+	}
+
+	truck := root.FindEntity("Truck")
+	if len(truck.Lods()) != 2 || len(truck.Lod(0).Items()) != 2 || truck.Lod(0) == vehicle.Lod(0) {
+		t.Errorf("wrong truck %v", truck)
+	}
+	if err := truck.Lod(0).SetMeta(definition.MetaData{}); err != nil {
+		t.Fatal(err)
+	}
+	if truck.Lod(0).Distance() != 0 || vehicle.Lod(0).Distance() != 20 {
+		t.Errorf("meta data of the base should not change %v", vehicle.Lod(0))
+	}
+}
+
+func TestLodFromErrors(t *testing.T) {
+	_, unknownErr := setup(
+		`
+archetype Creature
+  lod 0
+    WorldPosition
+  lod 1 from 0 without Animation
+`)
+	if unknownErr == nil {
+		t.Errorf("removing an item that isn't there should be reported")
+	}
+
+	_, forwardErr := setup(
+		`
+archetype Creature
+  lod 0 from 1
+  lod 1
+    WorldPosition
+`)
+	if forwardErr == nil {
+		t.Errorf("deriving from a later lod should be reported")
+	}
+
+	_, baseErr := setup(
+		`
+archetype Tank extends Vehicle
+  lod 0
+    WorldPosition
+`)
+	if baseErr == nil {
+		t.Errorf("unknown base archetype should be reported")
+	}
+}
+
+func TestLodDistanceAndRate(t *testing.T) {
+	parser, err := setup(
+		`
+component Animation
+  state int32
+
+archetype Creature
+  lod 0 [distance "50", rate "30"]
+    WorldPosition
+    Animation
+  lod 1 from 0 without Animation [distance "200", rate "5"]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	creature := parser.Root().FindEntity("Creature")
+	if creature.Lod(0).Distance() != 50 || creature.Lod(0).Rate() != 30 {
+		t.Errorf("wrong lod0 distance or rate %v", creature.Lod(0))
+	}
+
+	if creature.Lod(1).Distance() != 200 || creature.Lod(1).Rate() != 5 {
+		t.Errorf("wrong lod1 distance or rate %v", creature.Lod(1))
+	}
+
+	dropped := creature.DroppedItems(1)
+	if len(dropped) != 1 || dropped[0].Name() != "Animation" {
+		t.Errorf("wrong dropped items %v", dropped)
+	}
+
+	if len(creature.AddedItems(1)) != 0 {
+		t.Errorf("nothing should be added %v", creature.AddedItems(1))
+	}
+
+	if creature.ValidateLods() != nil {
+		t.Errorf("should be valid")
+	}
+}
+
+func TestLodSubsetValidation(t *testing.T) {
+	text := `
+component Animation
+  state int32
+
+archetype Creature
+  lod 0
+    WorldPosition
+  lod 1
+    Animation
+`
+	_, err := setup(text)
+	if err != nil {
+		t.Fatalf("validation should be optional %v", err)
+	}
+
+	_, validateErr := NewParserWithOptions(text, Options{AllowedComponentFields: []string{"WorldPosition"},
+		ValidateLodSubsets: true})
+	if validateErr == nil {
+		t.Errorf("lod1 is not a subset of lod0 and should be reported")
+	}
+}
+
+func TestPositions(t *testing.T) {
+	parser, err := setup(
+		`
+enum State
+  Idle 0
+
+component Animation
+  state State
+  speed float32
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enum := parser.Root().FindEnum("State")
+	if enum.Position().Line() != 2 || enum.Position().Column() != 1 {
+		t.Errorf("wrong enum position %v", enum.Position())
+	}
+
+	component := parser.Root().FindComponentDataType("Animation")
+	if component.Position().Line() != 5 {
+		t.Errorf("wrong component position %v", component.Position())
+	}
+
+	speed := component.Fields()[1]
+	if speed.Position().Line() != 7 || speed.Position().Column() != 3 {
+		t.Errorf("wrong field position %v", speed.Position())
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	parser, err := setup(
+		`
+name Arena
+
+component Building
+  owner uint16 [max "100"]
+
+component Turret extends Building
+  angle float32
+
+event Jump
+  height int16
+
+archetype Tower
+  lod 0
+    WorldPosition
+    Turret
+  lod 1 from 0 without Turret [distance "200"]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	octets, marshalErr := json.Marshal(parser.Root())
+	if marshalErr != nil {
+		t.Fatal(marshalErr)
+	}
+
+	var dump struct {
+		Format  string `json:"format"`
+		Version int    `json:"version"`
+		Name    string `json:"name"`
+		Hash    struct {
+			Algorithm string `json:"algorithm"`
+			Value     string `json:"value"`
+		} `json:"hash"`
+		Components []struct {
+			Name   string `json:"name"`
+			Index  int    `json:"index"`
+			Base   string `json:"base"`
+			Fields []struct {
+				Name string            `json:"name"`
+				Meta map[string]string `json:"meta"`
+			} `json:"fields"`
+		} `json:"components"`
+		Events []struct {
+			Name     string `json:"name"`
+			Position struct {
+				Line int `json:"line"`
+			} `json:"position"`
+		} `json:"events"`
+		Archetypes []struct {
+			ID   int `json:"id"`
+			Lods []struct {
+				DerivedFrom *int `json:"derivedFrom"`
+				Distance    int  `json:"distance"`
+				Items       []struct {
+					Name           string `json:"name"`
+					Kind           string `json:"kind"`
+					ComponentIndex *int   `json:"componentIndex"`
+				} `json:"items"`
+			} `json:"lods"`
+		} `json:"archetypes"`
+	}
+	unmarshalErr := json.Unmarshal(octets, &dump)
+	if unmarshalErr != nil {
+		t.Fatal(unmarshalErr)
+	}
+
+	if dump.Format != "scrawl-definition" || dump.Version != definition.JSONFormatVersion || dump.Name != "Arena" {
+		t.Errorf("wrong header %v", string(octets))
+	}
+
+	if dump.Hash.Algorithm != "fnv32a" || dump.Hash.Value != parser.Root().Hash().String() {
+		t.Errorf("wrong hash %v", dump.Hash)
+	}
+
+	turret := dump.Components[1]
+	if turret.Base != "Building" || turret.Index != 1 || len(turret.Fields) != 2 || turret.Fields[0].Meta["max"] != "100" {
+		t.Errorf("wrong component %v", turret)
+	}
+
+	if dump.Events[0].Name != "Jump" || dump.Events[0].Position.Line != 10 {
+		t.Errorf("wrong event %v", dump.Events[0])
+	}
+
+	tower := dump.Archetypes[0]
+	if tower.ID != int(parser.Root().FindEntity("Tower").ID().Value()) || len(tower.Lods) != 2 {
+		t.Fatalf("wrong archetype %v", tower)
+	}
+
+	if tower.Lods[0].DerivedFrom != nil || tower.Lods[0].Items[0].Kind != "field" || tower.Lods[0].Items[1].ComponentIndex == nil {
+		t.Errorf("wrong lod0 %v", tower.Lods[0])
+	}
+
+	if tower.Lods[1].DerivedFrom == nil || *tower.Lods[1].DerivedFrom != 0 || tower.Lods[1].Distance != 200 ||
+		len(tower.Lods[1].Items) != 1 {
+		t.Errorf("wrong lod1 %v", tower.Lods[1])
+	}
+}
+
+const jsonRoundTripProtocol = `
+name Arena
+namespace Game.Arena
+
+enum State
+  Idle 0
+  Running 2
+
+type Strength
+  big int32 [min "0" max "1000"]
+
+component Building
+  owner uint16
+
+component Turret extends Building
+  angle float32
+  strength Strength
+
+event Jump
+  height int16
+
+command Fire
+  state State
+
+buffer Tile
+  walkable bool
+
+archetype Tower
+  lod 0
+    WorldPosition
+    Turret
+  lod 1 from 0 without Turret [distance "200" rate "5"]
+
+archetype BigTower extends Tower
+`
+
+func TestUnmarshalJSON(t *testing.T) {
+	parser, err := setup(jsonRoundTripProtocol)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	octets, marshalErr := json.Marshal(parser.Root())
+	if marshalErr != nil {
+		t.Fatal(marshalErr)
+	}
+
+	loaded := &definition.Root{}
+	unmarshalErr := json.Unmarshal(octets, loaded)
+	if unmarshalErr != nil {
+		t.Fatal(unmarshalErr)
+	}
+
+	if loaded.Hash() != parser.Root().Hash() || len(loaded.DefinitionHashes()) != len(parser.Root().DefinitionHashes()) {
+		t.Errorf("wrong hash %v", loaded.Hash())
+	}
+
+	turret := loaded.FindComponentDataType("Turret")
+	if turret.Base() != loaded.FindComponentDataType("Building") || len(turret.OwnFields()) != 2 || len(turret.Fields()) != 3 {
+		t.Errorf("wrong extended component %v", turret)
+	}
+
+	tower := loaded.FindEntity("Tower")
+	if tower.Lod(0).Items()[1].ComponentDataType() != turret {
+		t.Errorf("item is not linked to the loaded component")
+	}
+
+	if tower.Lod(1).DerivedFrom() != 0 || tower.Lod(1).Rate() != 5 {
+		t.Errorf("wrong lod %v", tower.Lod(1))
+	}
+
+	if loaded.FindEntity("BigTower").Base() != tower {
+		t.Errorf("wrong archetype base")
+	}
+
+	again, againErr := json.Marshal(loaded)
+	if againErr != nil {
+		t.Fatal(againErr)
+	}
+	if !bytes.Equal(octets, again) {
+		t.Errorf("round trip differs\n%v\n%v", string(octets), string(again))
+	}
+}
+
+func TestUnmarshalJSONErrors(t *testing.T) {
+	parser, err := setup(jsonRoundTripProtocol)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	octets, marshalErr := json.Marshal(parser.Root())
+	if marshalErr != nil {
+		t.Fatal(marshalErr)
+	}
+	valid := string(octets)
+
+	hash := parser.Root().Hash().String()
+	broken := map[string]string{
+		"version":         strings.Replace(valid, `"version":1`, `"version":99`, 1),
+		"hash":            strings.Replace(valid, `"value":"`+hash+`"`, `"value":"00000000"`, 1),
+		"changed field":   strings.Replace(valid, `"type":"int16"`, `"type":"int32"`, 1),
+		"component index": strings.Replace(valid, `"componentIndex":1`, `"componentIndex":0`, 1),
+		"unknown base":    strings.Replace(valid, `"base":"Building"`, `"base":"Missing"`, 1),
+		"lod level":       strings.Replace(valid, `"level":1`, `"level":2`, 1),
+	}
+
+	for name, text := range broken {
+		if text == valid {
+			t.Fatalf("%v: replacement did not change the document", name)
+		}
+		loaded := &definition.Root{}
+		if json.Unmarshal([]byte(text), loaded) == nil {
+			t.Errorf("%v: should not load", name)
+		}
+	}
+}
+
+func TestUnmarshalJSONWithoutHashes(t *testing.T) {
+	parser, err := setup(jsonRoundTripProtocol)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	octets, marshalErr := json.Marshal(parser.Root())
+	if marshalErr != nil {
+		t.Fatal(marshalErr)
+	}
+	var document map[string]interface{}
+	if err := json.Unmarshal(octets, &document); err != nil {
+		t.Fatal(err)
+	}
+	delete(document, "definitionHashes")
+	delete(document, "hash")
+	withoutHashes, _ := json.Marshal(document)
+
+	loaded := &definition.Root{}
+	if err := json.Unmarshal(withoutHashes, loaded); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Hash() != parser.Root().Hash() || len(loaded.DefinitionHashes()) != len(parser.Root().DefinitionHashes()) {
+		t.Errorf("wrong calculated hash %v", loaded.Hash())
+	}
+
+	document["hash"] = map[string]interface{}{"algorithm": "sha256"}
+	withAlgorithm, _ := json.Marshal(document)
+	loaded = &definition.Root{}
+	if err := json.Unmarshal(withAlgorithm, loaded); err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Hash().Algorithm() != scrawlhash.SHA256 {
+		t.Errorf("wrong algorithm %v", loaded.Hash().Algorithm())
+	}
+
+	document["hash"] = map[string]interface{}{"algorithm": "md5"}
+	withUnknownAlgorithm, _ := json.Marshal(document)
+	if json.Unmarshal(withUnknownAlgorithm, &definition.Root{}) == nil {
+		t.Errorf("unknown algorithm should not load")
+	}
+}
+
+func TestFieldCapacity(t *testing.T) {
+	parser, err := setup(
+		`
+type Path
+  points int32 [capacity "8"]
+  length int32
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := parser.Root().FindUserType("Path").Fields()
+	if !fields[0].IsArray() || fields[0].Capacity() != 8 || fields[1].IsArray() {
+		t.Errorf("wrong capacity %v", fields)
+	}
+
+	_, illegalErr := setup(
+		`
+type Path
+  points int32 [capacity "many"]
+`)
+	if illegalErr == nil {
+		t.Errorf("illegal capacity should be reported")
+	}
+}
@@ -50,12 +50,49 @@ func (p *Parser) parseNameOptionalMetaAndFields() (string, definition.MetaData,
 	return name, meta, fields, nil
 }
 
-func (p *Parser) parseArchetypeNameAndStartScope() (string, definition.MetaData, error) {
-	name, meta, _, nameErr := p.parseNameOptionalMetaAndStartScope()
+func (p *Parser) parseNameAndOptionalBase() (string, string, token.Token, error) {
+	name, symbolErr := p.parseSymbol()
+	if symbolErr != nil {
+		return "", "", nil, symbolErr
+	}
+
+	t, tokErr := p.readNext()
+	if tokErr != nil {
+		return "", "", nil, tokErr
+	}
+
+	symbolToken, wasSymbol := t.(token.SymbolToken)
+	if !wasSymbol {
+		return name, "", t, nil
+	}
+
+	if symbolToken.Symbol != "extends" {
+		return "", "", nil, fmt.Errorf("expected 'extends', meta data or start of scope %v", symbolToken)
+	}
+
+	baseName, baseErr := p.parseSymbol()
+	if baseErr != nil {
+		return "", "", nil, baseErr
+	}
+
+	t, tokErr = p.readNext()
+	if tokErr != nil {
+		return "", "", nil, tokErr
+	}
+
+	return name, baseName, t, nil
+}
+
+func (p *Parser) parseArchetypeNameAndStartScope() (string, string, definition.MetaData, bool, error) {
+	name, baseName, afterName, nameErr := p.parseNameAndOptionalBase()
 	if nameErr != nil {
-		return "", definition.MetaData{}, nameErr
+		return "", "", definition.MetaData{}, false, nameErr
 	}
-	return name, meta, nil
+	meta, wasStartScope, metaErr := p.parseOptionalMetaAndStartScope(afterName)
+	if metaErr != nil {
+		return "", "", definition.MetaData{}, false, metaErr
+	}
+	return name, baseName, meta, wasStartScope, nil
 }
 
 func (p *Parser) parseIntegerAndFields() (int, []*definition.Field, error) {
@@ -94,6 +131,15 @@ func (p *Parser) parseNameOptionalMetaAndStartScope() (string, definition.MetaDa
 		return "", definition.MetaData{}, false, tokErr
 	}
 
+	metaData, wasStartScope, metaErr := p.parseOptionalMetaAndStartScope(maybeMetaOrStartScope)
+	if metaErr != nil {
+		return "", definition.MetaData{}, false, metaErr
+	}
+
+	return name, metaData, wasStartScope, nil
+}
+
+func (p *Parser) parseOptionalMetaAndStartScope(maybeMetaOrStartScope token.Token) (definition.MetaData, bool, error) {
 	_, isStartMeta := maybeMetaOrStartScope.(token.StartMetaDataToken)
 
 	var metaData definition.MetaData
@@ -103,17 +149,17 @@ func (p *Parser) parseNameOptionalMetaAndStartScope() (string, definition.MetaDa
 
 		metaData, metaDataErr = p.parseMetaData()
 		if metaDataErr != nil {
-			return "", definition.MetaData{}, false, metaDataErr
+			return definition.MetaData{}, false, metaDataErr
 		}
 		maybeMetaOrStartScope, metaDataErr = p.readNext()
 		if metaDataErr != nil {
-			return "", definition.MetaData{}, false, metaDataErr
+			return definition.MetaData{}, false, metaDataErr
 		}
 	}
 
 	_, wasStartScope := maybeMetaOrStartScope.(token.StartScopeToken)
 
-	return name, metaData, wasStartScope, nil
+	return metaData, wasStartScope, nil
 }
 
 func (p *Parser) parseFieldsUntilEndScope() ([]*definition.Field, error) {
@@ -0,0 +1,115 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package random
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/piot/scrawl-go/src/definition"
+)
+
+const corpusFileHeader = "go test fuzz v1\n"
+
+// Encoder is implemented by serialize.Codec and serialize.BitCodec.
+type Encoder interface {
+	Encode(fields []*definition.Field, value map[string]interface{}) ([]byte, error)
+}
+
+// Packets returns count encoded random values for the fields, followed by mutatedCount copies of
+// them with flipped bits, removed or added octets.
+func (g *Generator) Packets(encoder Encoder, fields []*definition.Field, count int, mutatedCount int) ([][]byte, error) {
+	if count <= 0 && mutatedCount > 0 {
+		return nil, fmt.Errorf("mutated packets need at least one valid packet")
+	}
+	var packets [][]byte
+	for index := 0; index < count; index++ {
+		value, valueErr := g.Fields(fields)
+		if valueErr != nil {
+			return nil, valueErr
+		}
+		octets, encodeErr := encoder.Encode(fields, value)
+		if encodeErr != nil {
+			return nil, encodeErr
+		}
+		packets = append(packets, octets)
+	}
+	for index := 0; index < mutatedCount; index++ {
+		packets = append(packets, g.Mutate(packets[g.random.Intn(count)]))
+	}
+	return packets, nil
+}
+
+// Mutate returns a copy of the octets with a random bit flipped, octets removed from the end or
+// random octets added to the end.
+func (g *Generator) Mutate(octets []byte) []byte {
+	mutated := append([]byte{}, octets...)
+	switch g.random.Intn(3) {
+	case 0:
+		if len(mutated) > 0 {
+			mutated[g.random.Intn(len(mutated))] ^= 1 << uint(g.random.Intn(8))
+			return mutated
+		}
+	case 1:
+		if len(mutated) > 0 {
+			return mutated[:g.random.Intn(len(mutated))]
+		}
+	}
+	for count := 1 + g.random.Intn(4); count > 0; count-- {
+		mutated = append(mutated, byte(g.random.Intn(256)))
+	}
+	return mutated
+}
+
+// MarshalCorpusEntry returns the file contents that 'go test -fuzz' uses for a []byte argument.
+func MarshalCorpusEntry(octets []byte) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString(corpusFileHeader)
+	fmt.Fprintf(&buffer, "[]byte(%q)\n", octets)
+	return buffer.Bytes()
+}
+
+// WriteCorpus writes the packets as a seed corpus for a fuzz target that takes a single []byte, for
+// example to 'testdata/fuzz/FuzzDecode'. The files are named after the hash of the contents, like
+// 'go test -fuzz' does.
+func WriteCorpus(directory string, packets [][]byte) error {
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return err
+	}
+	for _, packet := range packets {
+		contents := MarshalCorpusEntry(packet)
+		name := fmt.Sprintf("%x", sha256.Sum256(contents))[:16]
+		if err := ioutil.WriteFile(filepath.Join(directory, name), contents, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package random
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/piot/scrawl-go/src/definition"
+)
+
+// Invalid returns a random value for the fields where exactly one value breaks the definition: an
+// integer or float outside of the 'min', 'max' or 'bits' meta data, an enum value that is not a
+// constant or an array with more items than the capacity. Bools and strings are always valid, so it
+// fails if the fields have nothing else.
+func (g *Generator) Invalid(fields []*definition.Field) (map[string]interface{}, error) {
+	value, valueErr := g.Fields(fields)
+	if valueErr != nil {
+		return nil, valueErr
+	}
+	found, invalidErr := g.invalidate(fields, value, 0)
+	if invalidErr != nil {
+		return nil, invalidErr
+	}
+	if !found {
+		return nil, fmt.Errorf("no field can hold an invalid value")
+	}
+	return value, nil
+}
+
+func (g *Generator) invalidate(fields []*definition.Field, value map[string]interface{}, depth int) (bool, error) {
+	if depth > maxDepth {
+		return false, fmt.Errorf("types are nested too deep")
+	}
+	for _, index := range g.random.Perm(len(fields)) {
+		field := fields[index]
+		invalid, found, invalidErr := g.invalidField(field, depth)
+		if invalidErr != nil {
+			return false, fmt.Errorf("%v: %v", field.Name(), invalidErr)
+		}
+		if found {
+			value[field.Name()] = invalid
+			return true, nil
+		}
+		if field.IsArray() {
+			continue
+		}
+		resolved := g.root.ResolveFieldType(field.FieldType())
+		var nested []*definition.Field
+		switch resolved.Variant() {
+		case definition.FieldTypeUserType:
+			nested = resolved.UserType().Fields()
+		case definition.FieldTypeComponent:
+			nested = resolved.ComponentDataType().Fields()
+		default:
+			continue
+		}
+		found, nestedErr := g.invalidate(nested, value[field.Name()].(map[string]interface{}), depth+1)
+		if nestedErr != nil {
+			return false, fmt.Errorf("%v: %v", field.Name(), nestedErr)
+		}
+		if found {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (g *Generator) invalidField(field *definition.Field, depth int) (interface{}, bool, error) {
+	if field.IsArray() {
+		items, itemsErr := g.items(field, field.Capacity()+1, depth)
+		return items, itemsErr == nil, itemsErr
+	}
+	resolved := g.root.ResolveFieldType(field.FieldType())
+	switch resolved.Variant() {
+	case definition.FieldTypeEnum:
+		v, found := g.invalidEnum(resolved.Enum())
+		return v, found, nil
+	case definition.FieldTypePrimitive:
+		primitive := resolved.Primitive()
+		switch {
+		case primitive.IsFloat():
+			return g.invalidFloat(field, primitive)
+		case primitive.IsInteger() && primitive.IsSigned():
+			return g.invalidSigned(field, primitive)
+		case primitive.IsInteger():
+			return g.invalidUnsigned(field, primitive)
+		}
+	}
+	return nil, false, nil
+}
+
+func (g *Generator) pick(candidates []interface{}) (interface{}, bool, error) {
+	if len(candidates) == 0 {
+		return nil, false, nil
+	}
+	return candidates[g.random.Intn(len(candidates))], true, nil
+}
+
+func (g *Generator) invalidEnum(enum *definition.Enum) (interface{}, bool) {
+	lowest, highest := enum.ValueRange()
+	storage := enum.StorageType()
+	bits := uint(storage.BitSize())
+	if storage.IsSigned() {
+		bits--
+	}
+	if bits > 31 {
+		bits = 31
+	}
+	storageHighest := 1<<bits - 1
+	storageLowest := 0
+	if storage.IsSigned() {
+		storageLowest = -storageHighest - 1
+	}
+
+	var candidates []interface{}
+	if highest < storageHighest {
+		candidates = append(candidates, highest+1)
+	}
+	if lowest > storageLowest {
+		candidates = append(candidates, lowest-1)
+	}
+	for v := lowest; v < highest; v++ {
+		if enum.FindConstantByValue(v) == nil {
+			candidates = append(candidates, v)
+			break
+		}
+	}
+	v, found, _ := g.pick(candidates)
+	return v, found
+}
+
+func (g *Generator) invalidSigned(field *definition.Field, primitive definition.PrimitiveType) (interface{}, bool, error) {
+	lowest, highest, err := signedLimits(field, primitive)
+	if err != nil {
+		return nil, false, err
+	}
+	primitiveHighest := int64(uint64(1)<<uint(primitive.BitSize()-1) - 1)
+	primitiveLowest := -primitiveHighest - 1
+	var candidates []interface{}
+	if highest < primitiveHighest {
+		candidates = append(candidates, typedSigned(primitive, highest+1), typedSigned(primitive, primitiveHighest))
+	}
+	if lowest > primitiveLowest {
+		candidates = append(candidates, typedSigned(primitive, lowest-1), typedSigned(primitive, primitiveLowest))
+	}
+	return g.pick(candidates)
+}
+
+func (g *Generator) invalidUnsigned(field *definition.Field, primitive definition.PrimitiveType) (interface{}, bool, error) {
+	lowest, highest, err := unsignedLimits(field, primitive)
+	if err != nil {
+		return nil, false, err
+	}
+	primitiveHighest := uint64(math.MaxUint64) >> uint(64-primitive.BitSize())
+	var candidates []interface{}
+	if highest < primitiveHighest {
+		candidates = append(candidates, typedUnsigned(primitive, highest+1), typedUnsigned(primitive, primitiveHighest))
+	}
+	if lowest > 0 {
+		candidates = append(candidates, typedUnsigned(primitive, lowest-1), typedUnsigned(primitive, 0))
+	}
+	return g.pick(candidates)
+}
+
+func (g *Generator) invalidFloat(field *definition.Field, primitive definition.PrimitiveType) (interface{}, bool, error) {
+	minimum, hasMinimum, minimumErr := metaFloat(field, "min")
+	if minimumErr != nil {
+		return nil, false, minimumErr
+	}
+	maximum, hasMaximum, maximumErr := metaFloat(field, "max")
+	if maximumErr != nil {
+		return nil, false, maximumErr
+	}
+	var candidates []interface{}
+	if hasMaximum {
+		candidates = append(candidates, typedOutside(primitive, maximum+math.Max(1, math.Abs(maximum)*0.5)))
+	}
+	if hasMinimum {
+		candidates = append(candidates, typedOutside(primitive, minimum-math.Max(1, math.Abs(minimum)*0.5)))
+	}
+	return g.pick(candidates)
+}
+
+func typedOutside(primitive definition.PrimitiveType, v float64) interface{} {
+	if primitive == definition.PrimitiveFloat64 {
+		return v
+	}
+	return float32(v)
+}
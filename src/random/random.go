@@ -0,0 +1,374 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package random
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+
+	"github.com/piot/scrawl-go/src/definition"
+)
+
+const maxDepth = 32
+
+// DefaultMaxStringOctetCount is the default maximum length of generated strings.
+const DefaultMaxStringOctetCount = 16
+
+var stringRunes = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789 _-.åäöé€")
+
+// Generator creates random values for the fields of definitions, in the representation used by
+// serialize: map[string]interface{} for structs, []interface{} for arrays, Go primitive types for
+// primitives and int for enums.
+//
+// Valid values respect 'min', 'max', 'bits' and 'precision' meta data, enum constants and array
+// capacities. Values on the limits are generated more often than other values. The same seed
+// always gives the same values.
+type Generator struct {
+	root                *definition.Root
+	random              *rand.Rand
+	maxStringOctetCount int
+}
+
+func NewGenerator(root *definition.Root, seed int64) *Generator {
+	return &Generator{root: root, random: rand.New(rand.NewSource(seed)),
+		maxStringOctetCount: DefaultMaxStringOctetCount}
+}
+
+func (g *Generator) SetMaxStringOctetCount(count int) {
+	g.maxStringOctetCount = count
+}
+
+func (g *Generator) Root() *definition.Root {
+	return g.root
+}
+
+// Fields returns a random valid value for the fields.
+func (g *Generator) Fields(fields []*definition.Field) (map[string]interface{}, error) {
+	return g.fields(fields, 0)
+}
+
+// Field returns a random valid value for a single field.
+func (g *Generator) Field(field *definition.Field) (interface{}, error) {
+	return g.field(field, 0)
+}
+
+func (g *Generator) fields(fields []*definition.Field, depth int) (map[string]interface{}, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("types are nested too deep")
+	}
+	value := make(map[string]interface{})
+	for _, field := range fields {
+		fieldValue, fieldErr := g.field(field, depth)
+		if fieldErr != nil {
+			return nil, fmt.Errorf("%v: %v", field.Name(), fieldErr)
+		}
+		value[field.Name()] = fieldValue
+	}
+	return value, nil
+}
+
+func (g *Generator) field(field *definition.Field, depth int) (interface{}, error) {
+	if !field.IsArray() {
+		return g.single(field, depth)
+	}
+	count := g.random.Intn(field.Capacity() + 1)
+	if g.random.Intn(4) == 0 {
+		count = field.Capacity()
+	}
+	return g.items(field, count, depth)
+}
+
+func (g *Generator) items(field *definition.Field, count int, depth int) ([]interface{}, error) {
+	items := make([]interface{}, count)
+	for index := range items {
+		item, itemErr := g.single(field, depth)
+		if itemErr != nil {
+			return nil, itemErr
+		}
+		items[index] = item
+	}
+	return items, nil
+}
+
+func (g *Generator) single(field *definition.Field, depth int) (interface{}, error) {
+	resolved := g.root.ResolveFieldType(field.FieldType())
+	switch resolved.Variant() {
+	case definition.FieldTypePrimitive:
+		return g.primitive(field, resolved.Primitive())
+	case definition.FieldTypeEnum:
+		constants := resolved.Enum().Constants()
+		if len(constants) == 0 {
+			return nil, fmt.Errorf("enum '%v' has no constants", resolved.Enum().Name())
+		}
+		return constants[g.random.Intn(len(constants))].Value(), nil
+	case definition.FieldTypeUserType:
+		return g.fields(resolved.UserType().Fields(), depth+1)
+	case definition.FieldTypeComponent:
+		return g.fields(resolved.ComponentDataType().Fields(), depth+1)
+	}
+	return nil, fmt.Errorf("unknown type '%v'", field.FieldType())
+}
+
+func (g *Generator) primitive(field *definition.Field, primitive definition.PrimitiveType) (interface{}, error) {
+	switch {
+	case primitive == definition.PrimitiveBool:
+		return g.random.Intn(2) == 1, nil
+	case primitive == definition.PrimitiveString:
+		return g.text(), nil
+	case primitive.IsFloat():
+		return g.float(field, primitive)
+	case primitive.IsSigned():
+		lowest, highest, err := signedLimits(field, primitive)
+		if err != nil {
+			return nil, err
+		}
+		return typedSigned(primitive, g.signed(lowest, highest)), nil
+	}
+	lowest, highest, err := unsignedLimits(field, primitive)
+	if err != nil {
+		return nil, err
+	}
+	return typedUnsigned(primitive, g.unsigned(lowest, highest)), nil
+}
+
+func (g *Generator) text() string {
+	octetCount := g.random.Intn(g.maxStringOctetCount + 1)
+	var runes []rune
+	for length := 0; ; {
+		r := stringRunes[g.random.Intn(len(stringRunes))]
+		length += len(string(r))
+		if length > octetCount {
+			break
+		}
+		runes = append(runes, r)
+	}
+	return string(runes)
+}
+
+func (g *Generator) signed(lowest int64, highest int64) int64 {
+	switch g.random.Intn(8) {
+	case 0:
+		return lowest
+	case 1:
+		return highest
+	}
+	return lowest + int64(g.unsigned(0, uint64(highest-lowest)))
+}
+
+func (g *Generator) unsigned(lowest uint64, highest uint64) uint64 {
+	switch g.random.Intn(8) {
+	case 0:
+		return lowest
+	case 1:
+		return highest
+	}
+	span := highest - lowest
+	if span == math.MaxUint64 {
+		return g.random.Uint64()
+	}
+	return lowest + g.random.Uint64()%(span+1)
+}
+
+func (g *Generator) float(field *definition.Field, primitive definition.PrimitiveType) (interface{}, error) {
+	minimum, hasMinimum, minimumErr := metaFloat(field, "min")
+	if minimumErr != nil {
+		return nil, minimumErr
+	}
+	maximum, hasMaximum, maximumErr := metaFloat(field, "max")
+	if maximumErr != nil {
+		return nil, maximumErr
+	}
+	precision, hasPrecision, precisionErr := metaFloat(field, "precision")
+	if precisionErr != nil {
+		return nil, precisionErr
+	}
+	if !hasMinimum {
+		minimum = math.Min(-1000, maximum-1000)
+	}
+	if !hasMaximum {
+		maximum = math.Max(1000, minimum+1000)
+	}
+	if minimum > maximum {
+		return nil, fmt.Errorf("min %v is greater than max %v", minimum, maximum)
+	}
+
+	var v float64
+	switch g.random.Intn(8) {
+	case 0:
+		v = minimum
+	case 1:
+		v = maximum
+	default:
+		if hasPrecision && precision > 0 {
+			// Only the first 2^62 steps are used when the precision is finer than that.
+			steps := math.Min(math.Floor((maximum-minimum)/precision), 1<<62)
+			v = minimum + float64(g.random.Int63n(int64(steps)+1))*precision
+		} else {
+			v = minimum + g.random.Float64()*(maximum-minimum)
+		}
+	}
+	return typedFloat(primitive, v, minimum, maximum), nil
+}
+
+// typedFloat converts to the Go type of the primitive and keeps the value inside the limits,
+// since the limits can not always be represented as a float32.
+func typedFloat(primitive definition.PrimitiveType, v float64, minimum float64, maximum float64) interface{} {
+	v = math.Max(minimum, math.Min(maximum, v))
+	if primitive == definition.PrimitiveFloat64 {
+		return v
+	}
+	f := float32(v)
+	if float64(f) > maximum {
+		f = math.Nextafter32(f, float32(math.Inf(-1)))
+	}
+	if float64(f) < minimum {
+		f = math.Nextafter32(f, float32(math.Inf(1)))
+	}
+	return f
+}
+
+func metaFloat(field *definition.Field, name string) (float64, bool, error) {
+	meta := field.MetaData()
+	text := meta.Field(name)
+	if text == "" {
+		return 0, false, nil
+	}
+	v, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("illegal %v '%v'", name, text)
+	}
+	return v, true, nil
+}
+
+func metaInt(field *definition.Field, name string) (int64, bool, error) {
+	meta := field.MetaData()
+	text := meta.Field(name)
+	if text == "" {
+		return 0, false, nil
+	}
+	v, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("illegal %v '%v'", name, text)
+	}
+	return v, true, nil
+}
+
+// signedLimits returns the range from the primitive type, 'bits' and 'min' and 'max' meta data.
+func signedLimits(field *definition.Field, primitive definition.PrimitiveType) (int64, int64, error) {
+	bits := int64(primitive.BitSize())
+	metaBits, hasBits, bitsErr := metaInt(field, "bits")
+	if bitsErr != nil {
+		return 0, 0, bitsErr
+	}
+	if hasBits && metaBits >= 1 && metaBits < bits {
+		bits = metaBits
+	}
+	highest := int64(uint64(1)<<uint(bits-1) - 1)
+	lowest := -highest - 1
+
+	minimum, hasMinimum, minimumErr := metaInt(field, "min")
+	if minimumErr != nil {
+		return 0, 0, minimumErr
+	}
+	maximum, hasMaximum, maximumErr := metaInt(field, "max")
+	if maximumErr != nil {
+		return 0, 0, maximumErr
+	}
+	if hasMinimum && minimum > lowest {
+		lowest = minimum
+	}
+	if hasMaximum && maximum < highest {
+		highest = maximum
+	}
+	if lowest > highest {
+		return 0, 0, fmt.Errorf("no value of %v between %d and %d", primitive, lowest, highest)
+	}
+	return lowest, highest, nil
+}
+
+func unsignedLimits(field *definition.Field, primitive definition.PrimitiveType) (uint64, uint64, error) {
+	bits := int64(primitive.BitSize())
+	metaBits, hasBits, bitsErr := metaInt(field, "bits")
+	if bitsErr != nil {
+		return 0, 0, bitsErr
+	}
+	if hasBits && metaBits >= 1 && metaBits < bits {
+		bits = metaBits
+	}
+	highest := uint64(math.MaxUint64) >> uint(64-bits)
+	lowest := uint64(0)
+
+	minimum, hasMinimum, minimumErr := metaInt(field, "min")
+	if minimumErr != nil {
+		return 0, 0, minimumErr
+	}
+	maximum, hasMaximum, maximumErr := metaInt(field, "max")
+	if maximumErr != nil {
+		return 0, 0, maximumErr
+	}
+	if hasMinimum && minimum > 0 {
+		lowest = uint64(minimum)
+	}
+	if hasMaximum {
+		if maximum < 0 {
+			return 0, 0, fmt.Errorf("max %d is negative for %v", maximum, primitive)
+		}
+		if uint64(maximum) < highest {
+			highest = uint64(maximum)
+		}
+	}
+	if lowest > highest {
+		return 0, 0, fmt.Errorf("no value of %v between %d and %d", primitive, lowest, highest)
+	}
+	return lowest, highest, nil
+}
+
+func typedSigned(primitive definition.PrimitiveType, v int64) interface{} {
+	switch primitive {
+	case definition.PrimitiveInt8:
+		return int8(v)
+	case definition.PrimitiveInt16:
+		return int16(v)
+	case definition.PrimitiveInt32:
+		return int32(v)
+	}
+	return v
+}
+
+func typedUnsigned(primitive definition.PrimitiveType, v uint64) interface{} {
+	switch primitive {
+	case definition.PrimitiveUint8:
+		return uint8(v)
+	case definition.PrimitiveUint16:
+		return uint16(v)
+	case definition.PrimitiveUint32:
+		return uint32(v)
+	}
+	return v
+}
@@ -0,0 +1,232 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package random
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/piot/scrawl-go/src/definition"
+	"github.com/piot/scrawl-go/src/parser"
+	"github.com/piot/scrawl-go/src/scrawl"
+	"github.com/piot/scrawl-go/src/serialize"
+	"github.com/piot/scrawl-go/src/value"
+)
+
+const testProtocol = `
+enum Weapon
+  Sword 2
+  Bow 4
+  Axe 5
+
+type Point
+  x int16 [min "-100" max "100"]
+  y int8 [bits "5"]
+
+type Everything
+  alive bool
+  name string
+  health uint8 [min "10" max "90"]
+  score uint32 [min "100"]
+  offset int64
+  mask uint16 [bits "3"]
+  angle float32 [min "0" max "0.3" precision "0.1"]
+  speed float64 [min "-2.5" max "2.5"]
+  ratio float32
+  weapon Weapon
+  point Point
+  path Point [capacity "4"]
+  weapons Weapon [capacity "2"]
+
+type Plain
+  alive bool
+  name string
+`
+
+func setupRoot(t *testing.T) *definition.Root {
+	root, err := scrawl.ParseStringWithOptions(testProtocol, parser.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestDeterministic(t *testing.T) {
+	root := setupRoot(t)
+	fields := root.FindUserType("Everything").Fields()
+	first, firstErr := NewGenerator(root, 42).Fields(fields)
+	second, secondErr := NewGenerator(root, 42).Fields(fields)
+	if firstErr != nil || secondErr != nil {
+		t.Fatal(firstErr, secondErr)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("same seed gave different values\n%v\n%v", first, second)
+	}
+}
+
+func TestFinePrecision(t *testing.T) {
+	root, err := scrawl.ParseStringWithOptions(`
+type Fine
+  ratio float64 [min "0" max "1" precision "1e-20"]
+`, parser.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	generator := NewGenerator(root, 5)
+	for iteration := 0; iteration < 100; iteration++ {
+		generated, err := generator.Fields(root.FindUserType("Fine").Fields())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ratio := generated["ratio"].(float64); ratio < 0 || ratio > 1 {
+			t.Fatalf("ratio %v is outside the limits", ratio)
+		}
+	}
+}
+
+func TestValid(t *testing.T) {
+	root := setupRoot(t)
+	fields := root.FindUserType("Everything").Fields()
+	generator := NewGenerator(root, 1)
+	codecs := []Encoder{serialize.NewCodec(root), serialize.NewBitCodec(root)}
+	for iteration := 0; iteration < 500; iteration++ {
+		generated, err := generator.Fields(fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, valueErr := value.FromInterface(root, "Everything", generated)
+		if valueErr != nil {
+			t.Fatalf("%v: %v", valueErr, generated)
+		}
+		if err := v.Validate(); err != nil {
+			t.Fatalf("%v: %v", err, generated)
+		}
+		for _, codec := range codecs {
+			if _, err := codec.Encode(fields, generated); err != nil {
+				t.Fatalf("%T: %v: %v", codec, err, generated)
+			}
+		}
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	root := setupRoot(t)
+	fields := root.FindUserType("Everything").Fields()
+	generator := NewGenerator(root, 2)
+	codec := serialize.NewBitCodec(root)
+	for iteration := 0; iteration < 500; iteration++ {
+		generated, err := generator.Invalid(fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Validate does not check 'bits' meta data, but BitCodec does.
+		v, valueErr := value.FromInterface(root, "Everything", generated)
+		_, encodeErr := codec.Encode(fields, generated)
+		if valueErr == nil && v.Validate() == nil && encodeErr == nil {
+			t.Fatalf("value should be invalid: %v", generated)
+		}
+	}
+
+	if _, err := generator.Invalid(root.FindUserType("Plain").Fields()); err == nil {
+		t.Errorf("bool and string can not be invalid")
+	}
+}
+
+func TestCorpus(t *testing.T) {
+	root := setupRoot(t)
+	fields := root.FindUserType("Everything").Fields()
+	codec := serialize.NewBitCodec(root)
+	packets, packetsErr := NewGenerator(root, 3).Packets(codec, fields, 8, 4)
+	if packetsErr != nil {
+		t.Fatal(packetsErr)
+	}
+	if len(packets) != 12 {
+		t.Fatalf("wrong packet count %d", len(packets))
+	}
+	for _, packet := range packets[:8] {
+		if _, err := codec.Decode(fields, packet); err != nil {
+			t.Errorf("valid packet could not be decoded: %v", err)
+		}
+	}
+
+	directory, dirErr := ioutil.TempDir("", "scrawl-corpus")
+	if dirErr != nil {
+		t.Fatal(dirErr)
+	}
+	defer os.RemoveAll(directory)
+	corpusDirectory := filepath.Join(directory, "testdata", "fuzz", "FuzzDecode")
+	if err := WriteCorpus(corpusDirectory, packets); err != nil {
+		t.Fatal(err)
+	}
+	files, readDirErr := ioutil.ReadDir(corpusDirectory)
+	if readDirErr != nil {
+		t.Fatal(readDirErr)
+	}
+	if len(files) == 0 || len(files) > len(packets) {
+		t.Fatalf("wrong file count %d", len(files))
+	}
+	for _, file := range files {
+		contents, readErr := ioutil.ReadFile(filepath.Join(corpusDirectory, file.Name()))
+		if readErr != nil {
+			t.Fatal(readErr)
+		}
+		lines := strings.Split(strings.TrimSuffix(string(contents), "\n"), "\n")
+		if len(lines) != 2 || lines[0] != "go test fuzz v1" || !strings.HasPrefix(lines[1], "[]byte(") {
+			t.Fatalf("wrong corpus file %q", contents)
+		}
+		octets, unquoteErr := strconv.Unquote(strings.TrimSuffix(strings.TrimPrefix(lines[1], "[]byte("), ")"))
+		if unquoteErr != nil {
+			t.Fatal(unquoteErr)
+		}
+		found := false
+		for _, packet := range packets {
+			found = found || bytes.Equal(packet, []byte(octets))
+		}
+		if !found {
+			t.Errorf("corpus file %v is not one of the packets", file.Name())
+		}
+	}
+}
+
+func TestMutate(t *testing.T) {
+	generator := NewGenerator(setupRoot(t), 4)
+	original := []byte{1, 2, 3, 4}
+	for iteration := 0; iteration < 100; iteration++ {
+		if bytes.Equal(generator.Mutate(original), original) {
+			t.Fatalf("packet was not mutated")
+		}
+	}
+	if !bytes.Equal(original, []byte{1, 2, 3, 4}) {
+		t.Errorf("original was modified")
+	}
+}
@@ -0,0 +1,150 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/piot/scrawl-go/src/definition"
+	"github.com/piot/scrawl-go/src/parser"
+	"github.com/piot/scrawl-go/src/scrawl"
+	"github.com/piot/scrawl-go/src/scrawlhash"
+	"github.com/piot/scrawl-go/src/writer"
+)
+
+type options struct {
+	protocolFilename string
+	outputFilename   string
+	language         string
+	packageName      string
+	templateDir      string
+	hashAlgorithm    scrawlhash.Algorithm
+}
+
+func parseOptions() (options, error) {
+	var commandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	protocolDefinitionFilename := commandLine.String("protocol", "protocol.txt", "Protocol definition")
+	var flagForceColor = commandLine.Bool("color", false, "Enable color output")
+	var flagLanguage = commandLine.String("lang", "go", "Language to generate (c, cpp, csharp, go, jsonschema, protobuf, rust, typescript)")
+	var flagPackage = commandLine.String("package", "protocol", "Package name, for languages that need it")
+	var flagTemplate = commandLine.String("template", "", "Directory with .tmpl files to execute instead of a built-in language")
+	var flagHash = commandLine.String("hash", "fnv32a", "Hash algorithm (fnv32a, fnv64a, xxhash64, sha256)")
+	var outputFilename string
+	commandLine.StringVar(&outputFilename, "output", "", "file to output to. Default is stdout, or the current directory for -template")
+
+	commandLine.Parse(os.Args[1:])
+	if *flagForceColor {
+		color.NoColor = false
+	}
+
+	hashAlgorithm, hashErr := scrawlhash.ParseAlgorithm(*flagHash)
+	if hashErr != nil {
+		return options{}, hashErr
+	}
+
+	return options{protocolFilename: *protocolDefinitionFilename, outputFilename: outputFilename,
+		language: *flagLanguage, packageName: *flagPackage, templateDir: *flagTemplate, hashAlgorithm: hashAlgorithm}, nil
+}
+
+func generate(target io.Writer, root *definition.Root, o options) error {
+	switch o.language {
+	case "c":
+		return writer.WriteC(target, root)
+	case "cpp":
+		return writer.WriteCpp(target, root)
+	case "csharp":
+		return writer.WriteCSharp(target, root)
+	case "go":
+		return writer.WriteGo(target, root, o.packageName)
+	case "jsonschema":
+		return writer.WriteJSONSchema(target, root)
+	case "protobuf":
+		warnings, err := writer.WriteProtobuf(target, root)
+		for _, warning := range warnings {
+			color.New(color.FgYellow).Fprintf(os.Stderr, "Warning: %v\n", warning)
+		}
+		return err
+	case "rust":
+		return writer.WriteRust(target, root)
+	case "typescript":
+		return writer.WriteTypeScript(target, root)
+	}
+	return fmt.Errorf("unknown language '%v'", o.language)
+}
+
+func run() error {
+	o, optionsErr := parseOptions()
+	if optionsErr != nil {
+		return optionsErr
+	}
+	if o.protocolFilename == "" {
+		return fmt.Errorf("Must specify a protocol file")
+	}
+
+	parserOptions := parser.Options{AllowedComponentFields: []string{"WorldPosition"},
+		AllowedComponentTypes: []string{"WorldPositionComponent"}, HashAlgorithm: o.hashAlgorithm}
+	root, rootErr := scrawl.ParseFileWithOptions(o.protocolFilename, parserOptions)
+	if rootErr != nil {
+		return rootErr
+	}
+
+	if o.templateDir != "" {
+		outputDirectory := o.outputFilename
+		if outputDirectory == "" {
+			outputDirectory = "."
+		}
+		return writer.WriteTemplateDirectory(root, o.templateDir, outputDirectory)
+	}
+
+	if o.outputFilename == "" {
+		return generate(os.Stdout, root, o)
+	}
+
+	outputFile, createErr := os.Create(o.outputFilename)
+	if createErr != nil {
+		return createErr
+	}
+	generateErr := generate(outputFile, root, o)
+	closeErr := outputFile.Close()
+	if generateErr != nil {
+		return generateErr
+	}
+
+	return closeErr
+}
+
+func main() {
+	err := run()
+	if err != nil {
+		color.New(color.FgRed).Fprintf(os.Stderr, "Generate Error: %v\n", err)
+		os.Exit(1)
+	}
+}
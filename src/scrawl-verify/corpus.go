@@ -0,0 +1,113 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/piot/scrawl-go/src/definition"
+	"github.com/piot/scrawl-go/src/random"
+	"github.com/piot/scrawl-go/src/scrawlhash"
+	"github.com/piot/scrawl-go/src/serialize"
+	"github.com/piot/scrawl-go/src/transcode"
+)
+
+type corpusOptions struct {
+	protocolFilename string
+	directory        string
+	kind             definition.DefinitionKind
+	index            int
+	format           transcode.Format
+	seed             int64
+	count            int
+	mutatedCount     int
+}
+
+func parseCorpusOptions(args []string) (corpusOptions, error) {
+	var commandLine = flag.NewFlagSet("corpus", flag.ExitOnError)
+	protocolDefinitionFilename := commandLine.String("protocol", "protocol.txt", "Protocol definition")
+	var flagForceColor = commandLine.Bool("color", false, "Enable color output")
+	var flagKind = commandLine.String("kind", "event", "What is generated (event, command, component)")
+	var flagIndex = commandLine.Int("index", 0, "Event type index, command type index or component index")
+	var flagFormat = commandLine.String("format", "octet", "Binary format (octet, bit)")
+	var flagSeed = commandLine.Int64("seed", 1, "Seed for the random values")
+	var flagCount = commandLine.Int("count", 32, "Number of valid packets")
+	var flagMutated = commandLine.Int("mutated", 0, "Number of mutated packets")
+	var flagOutput = commandLine.String("output", "testdata/fuzz/FuzzDecode", "Directory for the corpus files")
+
+	commandLine.Parse(args)
+	if *flagForceColor {
+		color.NoColor = false
+	}
+
+	kind, kindErr := definition.ParseDefinitionKind(*flagKind)
+	if kindErr != nil {
+		return corpusOptions{}, kindErr
+	}
+	format, formatErr := transcode.ParseFormat(*flagFormat)
+	if formatErr != nil {
+		return corpusOptions{}, formatErr
+	}
+	if *flagCount < 0 || *flagMutated < 0 {
+		return corpusOptions{}, fmt.Errorf("packet counts can not be negative")
+	}
+
+	return corpusOptions{protocolFilename: *protocolDefinitionFilename, directory: *flagOutput, kind: kind,
+		index: *flagIndex, format: format, seed: *flagSeed, count: *flagCount, mutatedCount: *flagMutated}, nil
+}
+
+func runCorpus(args []string) error {
+	o, optionsErr := parseCorpusOptions(args)
+	if optionsErr != nil {
+		return optionsErr
+	}
+	root, rootErr := parseProtocol(o.protocolFilename, scrawlhash.FNV32a)
+	if rootErr != nil {
+		return rootErr
+	}
+	fields, fieldsErr := transcode.NewTranscoder(root, o.format).Fields(o.kind, o.index)
+	if fieldsErr != nil {
+		return fieldsErr
+	}
+	var encoder random.Encoder = serialize.NewCodec(root)
+	if o.format == transcode.Bit {
+		encoder = serialize.NewBitCodec(root)
+	}
+
+	packets, packetsErr := random.NewGenerator(root, o.seed).Packets(encoder, fields, o.count, o.mutatedCount)
+	if packetsErr != nil {
+		return packetsErr
+	}
+	if err := random.WriteCorpus(o.directory, packets); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "wrote %d packets to %v\n", len(packets), o.directory)
+	return nil
+}
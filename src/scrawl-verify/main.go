@@ -27,6 +27,7 @@ SOFTWARE.
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
@@ -35,16 +36,29 @@ import (
 	"github.com/fatih/color"
 	"github.com/piot/scrawl-go/src/beautify"
 	"github.com/piot/scrawl-go/src/definition"
+	"github.com/piot/scrawl-go/src/parser"
 	"github.com/piot/scrawl-go/src/scrawl"
+	"github.com/piot/scrawl-go/src/scrawlhash"
 	"github.com/piot/scrawl-go/src/tokenize"
 )
 
-func parseOptions() (string, bool, bool, string) {
+type options struct {
+	protocolFilename string
+	outputFilename   string
+	dumpJSONFilename string
+	verbose          bool
+	beautify         bool
+	hashAlgorithm    scrawlhash.Algorithm
+}
+
+func parseOptions() (options, error) {
 	var commandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
 	protocolDefinitionFilename := commandLine.String("protocol", "protocol.txt", "Protocol definition")
 	var flagForceColor = commandLine.Bool("color", false, "Enable color output")
 	var flagVerbose = commandLine.Bool("verbose", false, "Verbose")
 	var flagBeautify = commandLine.Bool("beautify", false, "Beautify, overwrites output file!")
+	var flagHash = commandLine.String("hash", "fnv32a", "Hash algorithm (fnv32a, fnv64a, xxhash64, sha256)")
+	var flagDumpJSON = commandLine.String("dump-json", "", "Write the definition as JSON to this file. Use - for stdout")
 	var outputFilename string
 	commandLine.StringVar(&outputFilename, "output", "", "file to output to. Default same as protocol")
 
@@ -55,17 +69,38 @@ func parseOptions() (string, bool, bool, string) {
 	if outputFilename == "" {
 		outputFilename = *protocolDefinitionFilename
 	}
-	return *protocolDefinitionFilename, *flagVerbose, *flagBeautify, outputFilename
+	hashAlgorithm, hashErr := scrawlhash.ParseAlgorithm(*flagHash)
+	if hashErr != nil {
+		return options{}, hashErr
+	}
+
+	return options{protocolFilename: *protocolDefinitionFilename, outputFilename: outputFilename,
+		dumpJSONFilename: *flagDumpJSON, verbose: *flagVerbose, beautify: *flagBeautify, hashAlgorithm: hashAlgorithm}, nil
 }
 
 func printRoot(root *definition.Root) {
 	fmt.Printf("--- Summary ---\n")
-	fmt.Printf("Hash:%04x\n", root.Hash())
+	fmt.Printf("Hash:%v (%v)\n", root.Hash(), root.Hash().Algorithm())
 	for _, entity := range root.Archetypes() {
 		fmt.Printf("%v\n", entity)
 	}
 }
 
+func dumpJSON(root *definition.Root, filename string) error {
+	octets, marshalErr := json.MarshalIndent(root, "", "  ")
+	if marshalErr != nil {
+		return marshalErr
+	}
+	octets = append(octets, '\n')
+
+	if filename == "-" {
+		_, writeErr := os.Stdout.Write(octets)
+		return writeErr
+	}
+
+	return ioutil.WriteFile(filename, octets, 0644)
+}
+
 func beautifyToFile(filename string, output string) error {
 	octets, octetsErr := ioutil.ReadFile(filename)
 	if octetsErr != nil {
@@ -87,22 +122,38 @@ func beautifyToFile(filename string, output string) error {
 	return nil
 }
 
+func parseProtocol(filename string, hashAlgorithm scrawlhash.Algorithm) (*definition.Root, error) {
+	parserOptions := parser.Options{AllowedComponentFields: []string{"WorldPosition"},
+		AllowedComponentTypes: []string{"WorldPositionComponent"}, HashAlgorithm: hashAlgorithm}
+	return scrawl.ParseFileWithOptions(filename, parserOptions)
+}
+
 func run() error {
-	protocolDefinitionFilename, verbose, shouldBeautify, outputFilename := parseOptions()
-	if protocolDefinitionFilename == "" {
+	o, optionsErr := parseOptions()
+	if optionsErr != nil {
+		return optionsErr
+	}
+	if o.protocolFilename == "" {
 		return fmt.Errorf("Must specify a protocol file")
 	}
-	root, rootErr := scrawl.ParseFile(protocolDefinitionFilename, []string{"WorldPosition"}, []string{"WorldPositionComponent"})
+	root, rootErr := parseProtocol(o.protocolFilename, o.hashAlgorithm)
 	if rootErr != nil {
 		return rootErr
 	}
 
-	if verbose {
+	if o.verbose {
 		printRoot(root)
 	}
 
-	if shouldBeautify {
-		beautifyErr := beautifyToFile(protocolDefinitionFilename, outputFilename)
+	if o.dumpJSONFilename != "" {
+		dumpErr := dumpJSON(root, o.dumpJSONFilename)
+		if dumpErr != nil {
+			return dumpErr
+		}
+	}
+
+	if o.beautify {
+		beautifyErr := beautifyToFile(o.protocolFilename, o.outputFilename)
 		if beautifyErr != nil {
 			return beautifyErr
 		}
@@ -112,10 +163,34 @@ func run() error {
 
 func main() {
 	color.New(color.FgCyan).Fprintf(os.Stderr, "scrawl protocol validator 0.2\n")
+	if len(os.Args) > 1 && os.Args[1] == "transcode" {
+		err := runTranscode(os.Args[2:])
+		if err != nil {
+			color.New(color.FgRed).Fprintf(os.Stderr, "Transcode Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "size" {
+		err := runSize(os.Args[2:])
+		if err != nil {
+			color.New(color.FgRed).Fprintf(os.Stderr, "Size Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "corpus" {
+		err := runCorpus(os.Args[2:])
+		if err != nil {
+			color.New(color.FgRed).Fprintf(os.Stderr, "Corpus Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
 	err := run()
 	if err != nil {
 		color.New(color.FgRed).Fprintf(os.Stderr, "Validation Error: %v\n", err)
 	} else {
-		color.Green("Validation passed")
+		color.New(color.FgGreen).Fprintf(os.Stderr, "Validation passed\n")
 	}
 }
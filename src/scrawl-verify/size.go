@@ -0,0 +1,88 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/piot/scrawl-go/src/estimate"
+	"github.com/piot/scrawl-go/src/scrawlhash"
+)
+
+type sizeOptions struct {
+	protocolFilename string
+	budget           estimate.Budget
+}
+
+func parseSizeOptions(args []string) (sizeOptions, error) {
+	var commandLine = flag.NewFlagSet("size", flag.ExitOnError)
+	protocolDefinitionFilename := commandLine.String("protocol", "protocol.txt", "Protocol definition")
+	var flagForceColor = commandLine.Bool("color", false, "Enable color output")
+	var flagBudget = commandLine.Int("budget", 0, "Highest number of bits allowed. Use 0 for no budget")
+	var flagMeasure = commandLine.String("measure", "max", "Size compared to the budget (max, typical, min)")
+
+	commandLine.Parse(args)
+	if *flagForceColor {
+		color.NoColor = false
+	}
+
+	if *flagBudget < 0 {
+		return sizeOptions{}, fmt.Errorf("budget can not be negative")
+	}
+	measure, measureErr := estimate.ParseMeasure(*flagMeasure)
+	if measureErr != nil {
+		return sizeOptions{}, measureErr
+	}
+
+	return sizeOptions{protocolFilename: *protocolDefinitionFilename,
+		budget: estimate.Budget{Bits: *flagBudget, Measure: measure}}, nil
+}
+
+func runSize(args []string) error {
+	o, optionsErr := parseSizeOptions(args)
+	if optionsErr != nil {
+		return optionsErr
+	}
+	root, rootErr := parseProtocol(o.protocolFilename, scrawlhash.FNV32a)
+	if rootErr != nil {
+		return rootErr
+	}
+	report, reportErr := estimate.NewReport(root, o.budget)
+	if reportErr != nil {
+		return reportErr
+	}
+	if err := report.Write(os.Stdout); err != nil {
+		return err
+	}
+	if count := report.OverBudgetCount(); count > 0 {
+		return fmt.Errorf("%d definitions or levels of detail are over the budget of %d bits", count, o.budget.Bits)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/piot/scrawl-go/src/definition"
+	"github.com/piot/scrawl-go/src/scrawlhash"
+	"github.com/piot/scrawl-go/src/transcode"
+)
+
+type transcodeOptions struct {
+	protocolFilename string
+	inputFilename    string
+	outputFilename   string
+	kind             definition.DefinitionKind
+	index            int
+	toJSON           bool
+	useHex           bool
+	format           transcode.Format
+}
+
+func parseTranscodeOptions(args []string) (transcodeOptions, error) {
+	var commandLine = flag.NewFlagSet("transcode", flag.ExitOnError)
+	protocolDefinitionFilename := commandLine.String("protocol", "protocol.txt", "Protocol definition")
+	var flagForceColor = commandLine.Bool("color", false, "Enable color output")
+	var flagKind = commandLine.String("kind", "event", "What is transcoded (event, command, component)")
+	var flagIndex = commandLine.Int("index", 0, "Event type index, command type index or component index")
+	var flagTo = commandLine.String("to", "json", "Convert to (json, binary)")
+	var flagFormat = commandLine.String("format", "octet", "Binary format (octet, bit)")
+	var flagHex = commandLine.Bool("hex", false, "Binary is read and written as hex text")
+	var flagInput = commandLine.String("input", "-", "file to read from. Use - for stdin")
+	var flagOutput = commandLine.String("output", "-", "file to write to. Use - for stdout")
+
+	commandLine.Parse(args)
+	if *flagForceColor {
+		color.NoColor = false
+	}
+
+	kind, kindErr := definition.ParseDefinitionKind(*flagKind)
+	if kindErr != nil {
+		return transcodeOptions{}, kindErr
+	}
+	format, formatErr := transcode.ParseFormat(*flagFormat)
+	if formatErr != nil {
+		return transcodeOptions{}, formatErr
+	}
+	if *flagTo != "json" && *flagTo != "binary" {
+		return transcodeOptions{}, fmt.Errorf("can not convert to '%v'", *flagTo)
+	}
+
+	return transcodeOptions{protocolFilename: *protocolDefinitionFilename, inputFilename: *flagInput,
+		outputFilename: *flagOutput, kind: kind, index: *flagIndex, toJSON: *flagTo == "json", useHex: *flagHex,
+		format: format}, nil
+}
+
+func readInput(filename string) ([]byte, error) {
+	if filename == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	return ioutil.ReadFile(filename)
+}
+
+func writeOutput(filename string, octets []byte) error {
+	if filename == "-" {
+		_, writeErr := os.Stdout.Write(octets)
+		return writeErr
+	}
+	return ioutil.WriteFile(filename, octets, 0644)
+}
+
+func runTranscode(args []string) error {
+	o, optionsErr := parseTranscodeOptions(args)
+	if optionsErr != nil {
+		return optionsErr
+	}
+	root, rootErr := parseProtocol(o.protocolFilename, scrawlhash.FNV32a)
+	if rootErr != nil {
+		return rootErr
+	}
+	transcoder := transcode.NewTranscoder(root, o.format)
+
+	input, inputErr := readInput(o.inputFilename)
+	if inputErr != nil {
+		return inputErr
+	}
+
+	if o.toJSON {
+		if o.useHex {
+			decoded, hexErr := hex.DecodeString(strings.Join(strings.Fields(string(input)), ""))
+			if hexErr != nil {
+				return hexErr
+			}
+			input = decoded
+		}
+		text, jsonErr := transcoder.ToJSON(o.kind, o.index, input)
+		if jsonErr != nil {
+			return jsonErr
+		}
+		return writeOutput(o.outputFilename, append(text, '\n'))
+	}
+
+	octets, binaryErr := transcoder.FromJSON(o.kind, o.index, input)
+	if binaryErr != nil {
+		return binaryErr
+	}
+	if o.useHex {
+		octets = []byte(hex.EncodeToString(octets) + "\n")
+	}
+	return writeOutput(o.outputFilename, octets)
+}
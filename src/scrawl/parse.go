@@ -27,6 +27,7 @@ SOFTWARE.
 package scrawl
 
 import (
+	"encoding/json"
 	"io/ioutil"
 
 	"github.com/piot/scrawl-go/src/definition"
@@ -42,6 +43,29 @@ func ParseFile(filename string, allowedComponentFields []string, allowedComponen
 	return ParseString(text, allowedComponentFields, allowedComponentTypes)
 }
 
+func ParseFileWithOptions(filename string, options parser.Options) (*definition.Root, error) {
+	octets, octetsErr := ioutil.ReadFile(filename)
+	if octetsErr != nil {
+		return nil, octetsErr
+	}
+	text := string(octets)
+	return ParseStringWithOptions(text, options)
+}
+
+// ReadJSONFile loads a definition that was written with Root.MarshalJSON, without parsing the protocol again.
+func ReadJSONFile(filename string) (*definition.Root, error) {
+	octets, octetsErr := ioutil.ReadFile(filename)
+	if octetsErr != nil {
+		return nil, octetsErr
+	}
+	root := &definition.Root{}
+	unmarshalErr := json.Unmarshal(octets, root)
+	if unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+	return root, nil
+}
+
 func ParseString(text string, allowedComponentFields []string, allowedComponentTypes []string) (*definition.Root, error) {
 	parser, parserErr := parser.NewParser(text, allowedComponentFields, allowedComponentTypes)
 	if parserErr != nil {
@@ -51,6 +75,15 @@ func ParseString(text string, allowedComponentFields []string, allowedComponentT
 	return parser.Root(), nil
 }
 
+func ParseStringWithOptions(text string, options parser.Options) (*definition.Root, error) {
+	parser, parserErr := parser.NewParserWithOptions(text, options)
+	if parserErr != nil {
+		return nil, parserErr
+	}
+
+	return parser.Root(), nil
+}
+
 func ParseToRoot(r *definition.Root, text string, allowedComponentFields []string, allowedComponentTypes []string) (*parser.Parser, error) {
 	parser, parserErr := parser.NewParser(text, allowedComponentFields, allowedComponentTypes)
 	if parserErr != nil {
@@ -0,0 +1,140 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package scrawlhash
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+)
+
+type Algorithm uint8
+
+const (
+	FNV32a Algorithm = iota
+	FNV64a
+	XXHash64
+	SHA256
+)
+
+func (a Algorithm) String() string {
+	switch a {
+	case FNV32a:
+		return "fnv32a"
+	case FNV64a:
+		return "fnv64a"
+	case XXHash64:
+		return "xxhash64"
+	case SHA256:
+		return "sha256"
+	}
+	return fmt.Sprintf("unknown-algorithm-%d", uint8(a))
+}
+
+var algorithms = []Algorithm{FNV32a, FNV64a, XXHash64, SHA256}
+
+func (a Algorithm) IsValid() bool {
+	for _, algorithm := range algorithms {
+		if algorithm == a {
+			return true
+		}
+	}
+	return false
+}
+
+func ParseAlgorithm(name string) (Algorithm, error) {
+	for _, algorithm := range algorithms {
+		if algorithm.String() == name {
+			return algorithm, nil
+		}
+	}
+	return FNV32a, fmt.Errorf("unknown hash algorithm '%v'", name)
+}
+
+// Digest is the result of a hash calculation. The octets are stored as a string so that
+// digests can be compared with ==.
+type Digest struct {
+	algorithm Algorithm
+	octets    string
+}
+
+func NewDigest(algorithm Algorithm, octets []byte) Digest {
+	return Digest{algorithm: algorithm, octets: string(octets)}
+}
+
+func (d Digest) Algorithm() Algorithm {
+	return d.algorithm
+}
+
+func (d Digest) Octets() []byte {
+	return []byte(d.octets)
+}
+
+// Uint32 returns the first four octets, which is the complete value for FNV32a.
+func (d Digest) Uint32() uint32 {
+	var octets [4]byte
+	copy(octets[:], d.octets)
+	return binary.BigEndian.Uint32(octets[:])
+}
+
+// Uint64 returns the first eight octets, which is the complete value for the 64-bit algorithms.
+func (d Digest) Uint64() uint64 {
+	var octets [8]byte
+	copy(octets[:], d.octets)
+	return binary.BigEndian.Uint64(octets[:])
+}
+
+func (d Digest) IsZero() bool {
+	return len(d.octets) == 0
+}
+
+func (d Digest) String() string {
+	return hex.EncodeToString([]byte(d.octets))
+}
+
+func Calculate(algorithm Algorithm, content []byte) Digest {
+	switch algorithm {
+	case FNV32a:
+		octets := make([]byte, 4)
+		binary.BigEndian.PutUint32(octets, CalculateHash(content))
+		return NewDigest(algorithm, octets)
+	case FNV64a:
+		f := fnv.New64a()
+		f.Write(content)
+		return NewDigest(algorithm, f.Sum(nil))
+	case XXHash64:
+		octets := make([]byte, 8)
+		binary.BigEndian.PutUint64(octets, CalculateXXHash64(content))
+		return NewDigest(algorithm, octets)
+	case SHA256:
+		sum := sha256.Sum256(content)
+		return NewDigest(algorithm, sum[:])
+	}
+	panic(fmt.Sprintf("unknown hash algorithm %v", algorithm))
+}
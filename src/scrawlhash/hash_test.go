@@ -0,0 +1,65 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package scrawlhash
+
+import "testing"
+
+func TestXXHash64(t *testing.T) {
+	vectors := []struct {
+		content  string
+		expected uint64
+	}{
+		{"", 0xef46db3751d8e999},
+		{"abc", 0x44bc2cf5ad770999},
+		{"Nobody inspects the spammish repetition", 0xfbcea83c8a378bf1},
+	}
+
+	for _, vector := range vectors {
+		calculated := CalculateXXHash64([]byte(vector.content))
+		if calculated != vector.expected {
+			t.Errorf("wrong xxhash64 for %q %016x (expected %016x)", vector.content, calculated, vector.expected)
+		}
+	}
+}
+
+func TestDigestLengths(t *testing.T) {
+	content := []byte("component Tough")
+	if Calculate(FNV32a, content).Uint32() != CalculateHash(content) {
+		t.Errorf("fnv32a digest must match the old hash")
+	}
+	expectedLengths := map[Algorithm]int{FNV32a: 4, FNV64a: 8, XXHash64: 8, SHA256: 32}
+	for algorithm, expectedLength := range expectedLengths {
+		digest := Calculate(algorithm, content)
+		if len(digest.Octets()) != expectedLength {
+			t.Errorf("wrong digest length for %v %d", algorithm, len(digest.Octets()))
+		}
+		parsedAlgorithm, parseErr := ParseAlgorithm(algorithm.String())
+		if parseErr != nil || parsedAlgorithm != algorithm {
+			t.Errorf("could not parse algorithm %v", algorithm)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package scrawlhash
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+const (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+func xxRound(acc uint64, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxPrime1
+}
+
+func xxMergeRound(acc uint64, value uint64) uint64 {
+	acc ^= xxRound(0, value)
+	return acc*xxPrime1 + xxPrime4
+}
+
+// CalculateXXHash64 calculates XXH64 with a zero seed.
+func CalculateXXHash64(content []byte) uint64 {
+	length := len(content)
+	var h uint64
+
+	if length >= 32 {
+		prime1 := xxPrime1
+		v1 := prime1 + xxPrime2
+		v2 := xxPrime2
+		v3 := uint64(0)
+		v4 := -prime1
+		for len(content) >= 32 {
+			v1 = xxRound(v1, binary.LittleEndian.Uint64(content[0:8]))
+			v2 = xxRound(v2, binary.LittleEndian.Uint64(content[8:16]))
+			v3 = xxRound(v3, binary.LittleEndian.Uint64(content[16:24]))
+			v4 = xxRound(v4, binary.LittleEndian.Uint64(content[24:32]))
+			content = content[32:]
+		}
+		h = bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) + bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+		h = xxMergeRound(h, v1)
+		h = xxMergeRound(h, v2)
+		h = xxMergeRound(h, v3)
+		h = xxMergeRound(h, v4)
+	} else {
+		h = xxPrime5
+	}
+
+	h += uint64(length)
+
+	for len(content) >= 8 {
+		h ^= xxRound(0, binary.LittleEndian.Uint64(content[0:8]))
+		h = bits.RotateLeft64(h, 27)*xxPrime1 + xxPrime4
+		content = content[8:]
+	}
+
+	if len(content) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(content[0:4])) * xxPrime1
+		h = bits.RotateLeft64(h, 23)*xxPrime2 + xxPrime3
+		content = content[4:]
+	}
+
+	for _, octet := range content {
+		h ^= uint64(octet) * xxPrime5
+		h = bits.RotateLeft64(h, 11) * xxPrime1
+	}
+
+	h ^= h >> 33
+	h *= xxPrime2
+	h ^= h >> 29
+	h *= xxPrime3
+	h ^= h >> 32
+
+	return h
+}
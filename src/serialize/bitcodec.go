@@ -0,0 +1,577 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package serialize
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/piot/scrawl-go/src/bitstream"
+	"github.com/piot/scrawl-go/src/definition"
+)
+
+// StringLengthBits is the number of bits used for the octet count of strings in BitCodec.
+const StringLengthBits = 16
+
+// packing describes how one value of a field is written by BitCodec.
+type packing struct {
+	primitive definition.PrimitiveType
+	enum      *definition.Enum
+	fields    []*definition.Field
+	bits      int
+	ranged    bool
+	minimum   int64
+	maximum   int64
+	uminimum  uint64
+	umaximum  uint64
+	quantized bool
+	floatMin  float64
+	floatMax  float64
+	precision float64
+	steps     uint64
+}
+
+// bitsFor returns the number of bits needed for all values from zero to highest.
+func bitsFor(highest uint64) int {
+	bits := 0
+	for highest != 0 {
+		bits++
+		highest >>= 1
+	}
+	return bits
+}
+
+func metaInt(field *definition.Field, name string) (int64, bool, error) {
+	meta := field.MetaData()
+	text := meta.Field(name)
+	if text == "" {
+		return 0, false, nil
+	}
+	v, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("illegal %v '%v'", name, text)
+	}
+	return v, true, nil
+}
+
+func metaUint(field *definition.Field, name string) (uint64, bool, error) {
+	meta := field.MetaData()
+	text := meta.Field(name)
+	if text == "" {
+		return 0, false, nil
+	}
+	v, err := strconv.ParseUint(text, 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("illegal %v '%v'", name, text)
+	}
+	return v, true, nil
+}
+
+func metaFloat(field *definition.Field, name string) (float64, bool, error) {
+	meta := field.MetaData()
+	text := meta.Field(name)
+	if text == "" {
+		return 0, false, nil
+	}
+	v, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("illegal %v '%v'", name, text)
+	}
+	return v, true, nil
+}
+
+func signedPacking(p *packing, field *definition.Field) (bool, error) {
+	minimum, hasMinimum, minimumErr := metaInt(field, "min")
+	if minimumErr != nil {
+		return false, minimumErr
+	}
+	maximum, hasMaximum, maximumErr := metaInt(field, "max")
+	if maximumErr != nil {
+		return false, maximumErr
+	}
+	if !hasMinimum || !hasMaximum {
+		return false, nil
+	}
+	if minimum > maximum {
+		return false, fmt.Errorf("min %d is greater than max %d", minimum, maximum)
+	}
+	if err := checkSignedRange(p.primitive, minimum); err != nil {
+		return false, err
+	}
+	if err := checkSignedRange(p.primitive, maximum); err != nil {
+		return false, err
+	}
+	p.minimum = minimum
+	p.maximum = maximum
+	p.bits = bitsFor(uint64(maximum - minimum))
+	return true, nil
+}
+
+func unsignedPacking(p *packing, field *definition.Field) (bool, error) {
+	minimum, hasMinimum, minimumErr := metaUint(field, "min")
+	if minimumErr != nil {
+		return false, minimumErr
+	}
+	maximum, hasMaximum, maximumErr := metaUint(field, "max")
+	if maximumErr != nil {
+		return false, maximumErr
+	}
+	if !hasMinimum || !hasMaximum {
+		return false, nil
+	}
+	if minimum > maximum {
+		return false, fmt.Errorf("min %d is greater than max %d", minimum, maximum)
+	}
+	if err := checkUnsignedRange(p.primitive, maximum); err != nil {
+		return false, err
+	}
+	p.uminimum = minimum
+	p.umaximum = maximum
+	p.bits = bitsFor(maximum - minimum)
+	return true, nil
+}
+
+func integerPacking(field *definition.Field, primitive definition.PrimitiveType) (*packing, error) {
+	p := &packing{primitive: primitive, bits: primitive.BitSize()}
+	var ranged bool
+	var rangeErr error
+	if primitive.IsSigned() {
+		ranged, rangeErr = signedPacking(p, field)
+	} else {
+		ranged, rangeErr = unsignedPacking(p, field)
+	}
+	if rangeErr != nil {
+		return nil, rangeErr
+	}
+	if ranged {
+		p.ranged = true
+		return p, nil
+	}
+
+	bits, hasBits, bitsErr := metaInt(field, "bits")
+	if bitsErr != nil {
+		return nil, bitsErr
+	}
+	if hasBits {
+		if bits < 1 || bits > int64(primitive.BitSize()) {
+			return nil, fmt.Errorf("bits %d does not fit in %v", bits, primitive)
+		}
+		p.bits = int(bits)
+	}
+	return p, nil
+}
+
+func floatPacking(field *definition.Field, primitive definition.PrimitiveType) (*packing, error) {
+	p := &packing{primitive: primitive, bits: primitive.BitSize()}
+	minimum, hasMinimum, minimumErr := metaFloat(field, "min")
+	if minimumErr != nil {
+		return nil, minimumErr
+	}
+	maximum, hasMaximum, maximumErr := metaFloat(field, "max")
+	if maximumErr != nil {
+		return nil, maximumErr
+	}
+	precision, hasPrecision, precisionErr := metaFloat(field, "precision")
+	if precisionErr != nil {
+		return nil, precisionErr
+	}
+	if !hasMinimum || !hasMaximum || !hasPrecision {
+		return p, nil
+	}
+	if minimum > maximum || precision <= 0 {
+		return nil, fmt.Errorf("illegal quantization min %v max %v precision %v", minimum, maximum, precision)
+	}
+	steps := math.Round((maximum - minimum) / precision)
+	if steps >= 1<<32 {
+		return nil, fmt.Errorf("precision %v is too fine for min %v max %v", precision, minimum, maximum)
+	}
+	p.quantized = true
+	p.floatMin = minimum
+	p.floatMax = maximum
+	p.precision = precision
+	p.steps = uint64(steps)
+	p.bits = bitsFor(p.steps)
+	return p, nil
+}
+
+func newPacking(root *definition.Root, field *definition.Field) (*packing, error) {
+	resolved := root.ResolveFieldType(field.FieldType())
+	switch resolved.Variant() {
+	case definition.FieldTypePrimitive:
+		primitive := resolved.Primitive()
+		switch {
+		case primitive == definition.PrimitiveBool:
+			return &packing{primitive: primitive, bits: 1}, nil
+		case primitive == definition.PrimitiveString:
+			return &packing{primitive: primitive}, nil
+		case primitive.IsFloat():
+			return floatPacking(field, primitive)
+		}
+		return integerPacking(field, primitive)
+	case definition.FieldTypeEnum:
+		lowest, highest := resolved.Enum().ValueRange()
+		return &packing{primitive: resolved.Enum().StorageType(), enum: resolved.Enum(), ranged: true,
+			minimum: int64(lowest), maximum: int64(highest), bits: bitsFor(uint64(highest - lowest))}, nil
+	case definition.FieldTypeUserType, definition.FieldTypeComponent:
+		return &packing{fields: structFields(resolved)}, nil
+	}
+	return nil, fmt.Errorf("unknown type '%v'", field.FieldType())
+}
+
+// BitCodec encodes values using only the bits that the definition allows:
+//
+//	bool: one bit
+//	integers: the bits of the type, the 'bits' meta data, or enough for 'min' to 'max'
+//	floats: the bits of the type, or quantized with 'min', 'max' and 'precision'
+//	enums: enough bits for the lowest to the highest constant
+//	strings: StringLengthBits for the octet count, followed by the octets
+//	arrays: enough bits for the count up to the capacity, followed by the elements
+//
+// Values are represented the same way as for Codec.
+type BitCodec struct {
+	root *definition.Root
+}
+
+func NewBitCodec(root *definition.Root) *BitCodec {
+	return &BitCodec{root: root}
+}
+
+func (c *BitCodec) Root() *definition.Root {
+	return c.root
+}
+
+// Encode writes the value of every field and pads the last octet with zero bits.
+func (c *BitCodec) Encode(fields []*definition.Field, value map[string]interface{}) ([]byte, error) {
+	writer := bitstream.NewWriter()
+	if err := c.WriteFields(writer, fields, value); err != nil {
+		return nil, err
+	}
+	return writer.Octets(), nil
+}
+
+// Decode reads the value of every field. Only padding may be left after the last field.
+func (c *BitCodec) Decode(fields []*definition.Field, octets []byte) (map[string]interface{}, error) {
+	reader := bitstream.NewReader(octets)
+	value, err := c.ReadFields(reader, fields)
+	if err != nil {
+		return nil, err
+	}
+	if !reader.IsPadding() {
+		return nil, fmt.Errorf("%d bits left after decoding", reader.BitsLeft())
+	}
+	return value, nil
+}
+
+func (c *BitCodec) WriteFields(writer *bitstream.Writer, fields []*definition.Field, value map[string]interface{}) error {
+	return c.writeFields(writer, fields, value, 0)
+}
+
+func (c *BitCodec) ReadFields(reader *bitstream.Reader, fields []*definition.Field) (map[string]interface{}, error) {
+	return c.readFields(reader, fields, 0)
+}
+
+// WriteField writes the value of a single field.
+func (c *BitCodec) WriteField(writer *bitstream.Writer, field *definition.Field, value interface{}) error {
+	if err := c.writeField(writer, field, value, 0); err != nil {
+		return fmt.Errorf("%v: %v", field.Name(), err)
+	}
+	return nil
+}
+
+// ReadField reads the value of a single field.
+func (c *BitCodec) ReadField(reader *bitstream.Reader, field *definition.Field) (interface{}, error) {
+	value, err := c.readField(reader, field, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", field.Name(), err)
+	}
+	return value, nil
+}
+
+func (c *BitCodec) writeFields(writer *bitstream.Writer, fields []*definition.Field, value map[string]interface{}, depth int) error {
+	if depth > maxDepth {
+		return fmt.Errorf("types are nested too deep")
+	}
+	if err := checkFields(fields, value); err != nil {
+		return err
+	}
+	for _, field := range fields {
+		fieldValue, found := value[field.Name()]
+		if !found {
+			return fmt.Errorf("missing field '%v'", field.Name())
+		}
+		if err := c.writeField(writer, field, fieldValue, depth); err != nil {
+			return fmt.Errorf("%v: %v", field.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (c *BitCodec) writeField(writer *bitstream.Writer, field *definition.Field, value interface{}, depth int) error {
+	p, packingErr := newPacking(c.root, field)
+	if packingErr != nil {
+		return packingErr
+	}
+	if !field.IsArray() {
+		return c.writeSingle(writer, p, value, depth)
+	}
+	items, itemsErr := arrayValue(field, value)
+	if itemsErr != nil {
+		return itemsErr
+	}
+	writer.WriteBits(uint64(len(items)), bitsFor(uint64(field.Capacity())))
+	for index, item := range items {
+		if err := c.writeSingle(writer, p, item, depth); err != nil {
+			return fmt.Errorf("[%d]: %v", index, err)
+		}
+	}
+	return nil
+}
+
+func (c *BitCodec) writeSingle(writer *bitstream.Writer, p *packing, value interface{}, depth int) error {
+	switch {
+	case p.fields != nil:
+		fields, fieldsErr := structValue(value)
+		if fieldsErr != nil {
+			return fieldsErr
+		}
+		return c.writeFields(writer, p.fields, fields, depth+1)
+	case p.enum != nil:
+		enumConstant, enumErr := enumValue(p.enum, value)
+		if enumErr != nil {
+			return enumErr
+		}
+		return writer.WriteBits(uint64(int64(enumConstant)-p.minimum), p.bits)
+	case p.primitive == definition.PrimitiveBool:
+		b, isBool := value.(bool)
+		if !isBool {
+			return fmt.Errorf("expected bool, got %T", value)
+		}
+		writer.WriteBool(b)
+		return nil
+	case p.primitive == definition.PrimitiveString:
+		s, isString := value.(string)
+		if !isString {
+			return fmt.Errorf("expected string, got %T", value)
+		}
+		if len(s) >= 1<<StringLengthBits {
+			return fmt.Errorf("string is longer than %d octets", 1<<StringLengthBits-1)
+		}
+		writer.WriteBits(uint64(len(s)), StringLengthBits)
+		for _, octet := range []byte(s) {
+			writer.WriteBits(uint64(octet), 8)
+		}
+		return nil
+	case p.primitive.IsFloat():
+		return writeFloat(writer, p, value)
+	}
+	return writeInteger(writer, p, value)
+}
+
+// quantizedFloat returns the value of a step. The value is kept between min and max, even if the
+// step times the precision is rounded past them.
+func quantizedFloat(p *packing, step uint64) interface{} {
+	f := math.Max(p.floatMin, math.Min(p.floatMax, p.floatMin+float64(step)*p.precision))
+	if p.primitive == definition.PrimitiveFloat64 {
+		return f
+	}
+	f32 := float32(f)
+	if float64(f32) > p.floatMax {
+		f32 = math.Nextafter32(f32, float32(math.Inf(-1)))
+	}
+	if float64(f32) < p.floatMin {
+		f32 = math.Nextafter32(f32, float32(math.Inf(1)))
+	}
+	return f32
+}
+
+func writeFloat(writer *bitstream.Writer, p *packing, value interface{}) error {
+	f, floatErr := floatValue(value)
+	if floatErr != nil {
+		return floatErr
+	}
+	if p.quantized {
+		if f < p.floatMin || f > p.floatMax || math.IsNaN(f) {
+			return fmt.Errorf("%v is not between %v and %v", f, p.floatMin, p.floatMax)
+		}
+		step := uint64(math.Round((f - p.floatMin) / p.precision))
+		if step > p.steps {
+			step = p.steps
+		}
+		return writer.WriteBits(step, p.bits)
+	}
+	if p.primitive == definition.PrimitiveFloat32 {
+		return writer.WriteBits(uint64(math.Float32bits(float32(f))), 32)
+	}
+	return writer.WriteBits(math.Float64bits(f), 64)
+}
+
+func writeInteger(writer *bitstream.Writer, p *packing, value interface{}) error {
+	if p.ranged && p.primitive.IsSigned() {
+		v, signedErr := signedValue(value)
+		if signedErr != nil {
+			return signedErr
+		}
+		if v < p.minimum || v > p.maximum {
+			return fmt.Errorf("%d is not between %d and %d", v, p.minimum, p.maximum)
+		}
+		return writer.WriteBits(uint64(v-p.minimum), p.bits)
+	}
+
+	if p.ranged {
+		v, unsignedErr := unsignedValue(value)
+		if unsignedErr != nil {
+			return unsignedErr
+		}
+		if v < p.uminimum || v > p.umaximum {
+			return fmt.Errorf("%d is not between %d and %d", v, p.uminimum, p.umaximum)
+		}
+		return writer.WriteBits(v-p.uminimum, p.bits)
+	}
+
+	if p.primitive.IsSigned() {
+		v, signedErr := signedValue(value)
+		if signedErr != nil {
+			return signedErr
+		}
+		if p.bits < 64 && (v < -(int64(1)<<uint(p.bits-1)) || v >= int64(1)<<uint(p.bits-1)) {
+			return fmt.Errorf("%d does not fit in %d bits", v, p.bits)
+		}
+		mask := ^uint64(0)
+		if p.bits < 64 {
+			mask = uint64(1)<<uint(p.bits) - 1
+		}
+		return writer.WriteBits(uint64(v)&mask, p.bits)
+	}
+
+	v, unsignedErr := unsignedValue(value)
+	if unsignedErr != nil {
+		return unsignedErr
+	}
+	if p.bits < 64 && v >= uint64(1)<<uint(p.bits) {
+		return fmt.Errorf("%d does not fit in %d bits", v, p.bits)
+	}
+	return writer.WriteBits(v, p.bits)
+}
+
+func (c *BitCodec) readFields(reader *bitstream.Reader, fields []*definition.Field, depth int) (map[string]interface{}, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("types are nested too deep")
+	}
+	value := make(map[string]interface{})
+	for _, field := range fields {
+		fieldValue, fieldErr := c.readField(reader, field, depth)
+		if fieldErr != nil {
+			return nil, fmt.Errorf("%v: %v", field.Name(), fieldErr)
+		}
+		value[field.Name()] = fieldValue
+	}
+	return value, nil
+}
+
+func (c *BitCodec) readField(reader *bitstream.Reader, field *definition.Field, depth int) (interface{}, error) {
+	p, packingErr := newPacking(c.root, field)
+	if packingErr != nil {
+		return nil, packingErr
+	}
+	if !field.IsArray() {
+		return c.readSingle(reader, p, depth)
+	}
+	count, countErr := reader.ReadBits(bitsFor(uint64(field.Capacity())))
+	if countErr != nil {
+		return nil, countErr
+	}
+	if count > uint64(field.Capacity()) {
+		return nil, fmt.Errorf("%d items exceeds capacity %d", count, field.Capacity())
+	}
+	items := make([]interface{}, 0, count)
+	for index := 0; index < int(count); index++ {
+		item, itemErr := c.readSingle(reader, p, depth)
+		if itemErr != nil {
+			return nil, fmt.Errorf("[%d]: %v", index, itemErr)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (c *BitCodec) readSingle(reader *bitstream.Reader, p *packing, depth int) (interface{}, error) {
+	if p.fields != nil {
+		return c.readFields(reader, p.fields, depth+1)
+	}
+
+	if p.primitive == definition.PrimitiveString {
+		length, lengthErr := reader.ReadBits(StringLengthBits)
+		if lengthErr != nil {
+			return nil, lengthErr
+		}
+		if int(length)*8 > reader.BitsLeft() {
+			return nil, fmt.Errorf("string of %d octets, but only %d bits left", length, reader.BitsLeft())
+		}
+		octets := make([]byte, length)
+		for index := range octets {
+			octet, _ := reader.ReadBits(8)
+			octets[index] = byte(octet)
+		}
+		return string(octets), nil
+	}
+
+	raw, rawErr := reader.ReadBits(p.bits)
+	if rawErr != nil {
+		return nil, rawErr
+	}
+
+	switch {
+	case p.enum != nil:
+		return decodedEnumValue(p.enum, int64(raw)+p.minimum)
+	case p.primitive == definition.PrimitiveBool:
+		return raw == 1, nil
+	case p.quantized:
+		if raw > p.steps {
+			return nil, fmt.Errorf("quantized value %d is greater than %d", raw, p.steps)
+		}
+		return quantizedFloat(p, raw), nil
+	case p.primitive == definition.PrimitiveFloat32:
+		return math.Float32frombits(uint32(raw)), nil
+	case p.primitive == definition.PrimitiveFloat64:
+		return math.Float64frombits(raw), nil
+	case p.ranged && p.primitive.IsSigned():
+		v := int64(raw) + p.minimum
+		if v > p.maximum {
+			return nil, fmt.Errorf("%d is greater than %d", v, p.maximum)
+		}
+		return typedSigned(p.primitive, v), nil
+	case p.ranged:
+		if raw > p.umaximum-p.uminimum {
+			return nil, fmt.Errorf("%d is greater than %d", raw+p.uminimum, p.umaximum)
+		}
+		return typedUnsigned(p.primitive, raw+p.uminimum), nil
+	case p.primitive.IsSigned():
+		return typedSigned(p.primitive, signExtend(raw, p.bits)), nil
+	}
+	return typedUnsigned(p.primitive, raw), nil
+}
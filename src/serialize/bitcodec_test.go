@@ -0,0 +1,293 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package serialize
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/piot/scrawl-go/src/bitstream"
+	"github.com/piot/scrawl-go/src/definition"
+	"github.com/piot/scrawl-go/src/parser"
+	"github.com/piot/scrawl-go/src/scrawl"
+)
+
+const bitTestProtocol = `
+enum Direction
+  Left -1
+  None 0
+  Right 1
+
+type Vector
+  x float32 [min "-10" max "10" precision "0.01"]
+  y float64 [min "0" max "1" precision "0.125"]
+
+type Everything
+  flag bool
+  tiny int8
+  small uint16
+  medium int32
+  large int64
+  huge uint64
+  health int32 [min "-50" max "200"]
+  packed uint32 [bits "5"]
+  offset int16 [bits "4"]
+  raw float32
+  exact float64
+  direction Direction
+  name string
+  position Vector
+  path Vector [capacity "5"]
+  directions Direction [capacity "3"]
+`
+
+func setupBitRoot(t *testing.T) *definition.Root {
+	root, err := scrawl.ParseStringWithOptions(bitTestProtocol, parser.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func randomSigned(random *rand.Rand, lowest int64, highest int64) int64 {
+	return lowest + random.Int63n(highest-lowest+1)
+}
+
+func randomTestValue(t *testing.T, random *rand.Rand, root *definition.Root, field *definition.Field) interface{} {
+	p, err := newPacking(root, field)
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch {
+	case p.fields != nil:
+		return randomTestFields(t, random, root, p.fields)
+	case p.enum != nil:
+		constants := p.enum.Constants()
+		return constants[random.Intn(len(constants))].Value()
+	case p.primitive == definition.PrimitiveBool:
+		return random.Intn(2) == 1
+	case p.primitive == definition.PrimitiveString:
+		octets := make([]byte, random.Intn(10))
+		random.Read(octets)
+		return string(octets)
+	case p.quantized:
+		f := p.floatMin + float64(random.Int63n(int64(p.steps)+1))*p.precision
+		if p.primitive == definition.PrimitiveFloat32 {
+			return float32(f)
+		}
+		return f
+	case p.primitive == definition.PrimitiveFloat32:
+		return float32(random.NormFloat64() * 1000)
+	case p.primitive == definition.PrimitiveFloat64:
+		return random.NormFloat64() * 1000
+	case p.ranged:
+		return typedSigned(p.primitive, randomSigned(random, p.minimum, p.maximum))
+	case p.primitive.IsSigned():
+		v := int64(random.Uint64())
+		if p.bits < 64 {
+			v = randomSigned(random, -(int64(1) << uint(p.bits-1)), int64(1)<<uint(p.bits-1)-1)
+		}
+		return typedSigned(p.primitive, v)
+	}
+	v := random.Uint64()
+	if p.bits < 64 {
+		v &= uint64(1)<<uint(p.bits) - 1
+	}
+	return typedUnsigned(p.primitive, v)
+}
+
+func randomTestFields(t *testing.T, random *rand.Rand, root *definition.Root, fields []*definition.Field) map[string]interface{} {
+	value := make(map[string]interface{})
+	for _, field := range fields {
+		if !field.IsArray() {
+			value[field.Name()] = randomTestValue(t, random, root, field)
+			continue
+		}
+		items := []interface{}{}
+		for count := random.Intn(field.Capacity() + 1); count > 0; count-- {
+			items = append(items, randomTestValue(t, random, root, field))
+		}
+		value[field.Name()] = items
+	}
+	return value
+}
+
+func TestBitCodecRandomRoundTrip(t *testing.T) {
+	root := setupBitRoot(t)
+	codec := NewBitCodec(root)
+	fields := root.FindUserType("Everything").Fields()
+	random := rand.New(rand.NewSource(1))
+
+	for iteration := 0; iteration < 500; iteration++ {
+		value := randomTestFields(t, random, root, fields)
+		octets, err := codec.Encode(fields, value)
+		if err != nil {
+			t.Fatalf("%v: %v", value, err)
+		}
+		decoded, decodeErr := codec.Decode(fields, octets)
+		if decodeErr != nil {
+			t.Fatalf("%v: %v", value, decodeErr)
+		}
+		if !reflect.DeepEqual(decoded, value) {
+			t.Fatalf("round trip differs\n%v\n%v", decoded, value)
+		}
+	}
+}
+
+func TestBitCodecBitCount(t *testing.T) {
+	root := setupBitRoot(t)
+	codec := NewBitCodec(root)
+	fields := root.FindUserType("Vector").Fields()
+
+	writer := bitstream.NewWriter()
+	err := codec.WriteFields(writer, fields, map[string]interface{}{"x": 0.005, "y": 0.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if writer.BitCount() != 11+4 {
+		t.Errorf("wrong bit count %d", writer.BitCount())
+	}
+
+	value, decodeErr := codec.Decode(fields, writer.Octets())
+	if decodeErr != nil {
+		t.Fatal(decodeErr)
+	}
+	if math.Abs(float64(value["x"].(float32))-0.005) > 0.005 || value["y"] != 0.5 {
+		t.Errorf("wrong value %v", value)
+	}
+}
+
+func TestQuantizedLimits(t *testing.T) {
+	root, err := scrawl.ParseStringWithOptions(`
+type Limits
+  narrow float32 [min "0" max "0.3" precision "0.1"]
+  wide float64 [min "0" max "0.3" precision "0.1"]
+`, parser.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	codec := NewBitCodec(root)
+	fields := root.FindUserType("Limits").Fields()
+
+	octets, encodeErr := codec.Encode(fields, map[string]interface{}{"narrow": 0.3, "wide": 0.3})
+	if encodeErr != nil {
+		t.Fatal(encodeErr)
+	}
+	value, decodeErr := codec.Decode(fields, octets)
+	if decodeErr != nil {
+		t.Fatal(decodeErr)
+	}
+	if float64(value["narrow"].(float32)) > 0.3 || value["wide"].(float64) > 0.3 {
+		t.Errorf("decoded value is above max %v", value)
+	}
+}
+
+func TestUnsignedLimits(t *testing.T) {
+	root, err := scrawl.ParseStringWithOptions(`
+type Limits
+  small uint8 [min "0" max "200"]
+  large uint32 [min "0" max "4000000000"]
+  huge uint64 [min "9000000000000000000" max "18000000000000000000"]
+`, parser.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	codec := NewBitCodec(root)
+	fields := root.FindUserType("Limits").Fields()
+
+	value := map[string]interface{}{"small": uint8(200), "large": uint32(4000000000),
+		"huge": uint64(18000000000000000000)}
+	writer := bitstream.NewWriter()
+	if err := codec.WriteFields(writer, fields, value); err != nil {
+		t.Fatal(err)
+	}
+	if writer.BitCount() != 8+32+63 {
+		t.Errorf("wrong bit count %d", writer.BitCount())
+	}
+	decoded, decodeErr := codec.Decode(fields, writer.Octets())
+	if decodeErr != nil {
+		t.Fatal(decodeErr)
+	}
+	if !reflect.DeepEqual(decoded, value) {
+		t.Errorf("wrong value %v", decoded)
+	}
+
+	if _, err := codec.Encode(fields, map[string]interface{}{"small": 201, "large": 0, "huge": uint64(1)}); err == nil {
+		t.Errorf("value outside the unsigned range should be reported")
+	}
+}
+
+func TestBitCodecErrors(t *testing.T) {
+	root := setupBitRoot(t)
+	codec := NewBitCodec(root)
+	vector := root.FindUserType("Vector").Fields()
+
+	if _, err := codec.Encode(vector, map[string]interface{}{"x": 11, "y": 0}); err == nil {
+		t.Errorf("quantized value out of range should be reported")
+	}
+
+	everything := root.FindUserType("Everything")
+	health := everything.Fields()[6]
+	writer := bitstream.NewWriter()
+	if err := codec.WriteField(writer, health, 201); err == nil {
+		t.Errorf("value above max should be reported")
+	}
+
+	writer.WriteBits(255, 8)
+	reader := bitstream.NewReaderWithBitCount(writer.Octets(), 8)
+	if _, err := codec.ReadField(reader, health); err == nil {
+		t.Errorf("decoded value above max should be reported")
+	}
+
+	if _, err := codec.Decode(vector, []byte{0xff, 0xff}); err == nil {
+		t.Errorf("quantized value above the steps should be reported")
+	}
+}
+
+func TestFieldsSize(t *testing.T) {
+	root := setupBitRoot(t)
+	codec := NewBitCodec(root)
+
+	vector, err := codec.FieldsSize(root.FindUserType("Vector").Fields())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vector.Min != 15 || vector.Max != 15 || vector.Typical != 15 {
+		t.Errorf("wrong vector size %v", vector)
+	}
+
+	path, pathErr := codec.FieldSize(root.FindUserType("Everything").Fields()[14])
+	if pathErr != nil {
+		t.Fatal(pathErr)
+	}
+	if path.Min != 3 || path.Max != 3+5*15 || path.Typical != 3+3*15 {
+		t.Errorf("wrong path size %v", path)
+	}
+}
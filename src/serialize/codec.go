@@ -0,0 +1,364 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package serialize
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/piot/scrawl-go/src/definition"
+)
+
+// Codec encodes values to an octet aligned format. Integers and floats are written big-endian
+// with the size of their type, bools as one octet, enums as their storage type, and strings and
+// arrays are prefixed with their length as an unsigned varint.
+//
+// Values are represented as map[string]interface{} for types with fields, slices for arrays, Go
+// numbers, bools and strings for primitives, and int for enums. When encoding, enums can also
+// be given as the constant name.
+type Codec struct {
+	root *definition.Root
+}
+
+func NewCodec(root *definition.Root) *Codec {
+	return &Codec{root: root}
+}
+
+func (c *Codec) Root() *definition.Root {
+	return c.root
+}
+
+// Encode writes the value of every field, in field order.
+func (c *Codec) Encode(fields []*definition.Field, value map[string]interface{}) ([]byte, error) {
+	encoder := &octetEncoder{root: c.root}
+	if err := encoder.fields(fields, value, 0); err != nil {
+		return nil, err
+	}
+	return encoder.buffer.Bytes(), nil
+}
+
+// Decode reads the value of every field. All octets must be used.
+func (c *Codec) Decode(fields []*definition.Field, octets []byte) (map[string]interface{}, error) {
+	decoder := &octetDecoder{root: c.root, reader: bytes.NewReader(octets)}
+	value, err := decoder.fields(fields, 0)
+	if err != nil {
+		return nil, err
+	}
+	if decoder.reader.Len() != 0 {
+		return nil, fmt.Errorf("%d octets left after decoding", decoder.reader.Len())
+	}
+	return value, nil
+}
+
+func (c *Codec) EncodeComponent(component *definition.ComponentDataType, value map[string]interface{}) ([]byte, error) {
+	return c.Encode(component.Fields(), value)
+}
+
+func (c *Codec) DecodeComponent(component *definition.ComponentDataType, octets []byte) (map[string]interface{}, error) {
+	return c.Decode(component.Fields(), octets)
+}
+
+func (c *Codec) EncodeEvent(event *definition.Event, value map[string]interface{}) ([]byte, error) {
+	return c.Encode(event.Fields(), value)
+}
+
+func (c *Codec) DecodeEvent(event *definition.Event, octets []byte) (map[string]interface{}, error) {
+	return c.Decode(event.Fields(), octets)
+}
+
+func (c *Codec) EncodeCommand(command *definition.Command, value map[string]interface{}) ([]byte, error) {
+	return c.Encode(command.Fields(), value)
+}
+
+func (c *Codec) DecodeCommand(command *definition.Command, octets []byte) (map[string]interface{}, error) {
+	return c.Decode(command.Fields(), octets)
+}
+
+func (c *Codec) EncodeBuffer(buffer *definition.Buffer, value map[string]interface{}) ([]byte, error) {
+	return c.Encode(buffer.Fields(), value)
+}
+
+func (c *Codec) DecodeBuffer(buffer *definition.Buffer, octets []byte) (map[string]interface{}, error) {
+	return c.Decode(buffer.Fields(), octets)
+}
+
+func (c *Codec) EncodeUserType(userType *definition.UserType, value map[string]interface{}) ([]byte, error) {
+	return c.Encode(userType.Fields(), value)
+}
+
+func (c *Codec) DecodeUserType(userType *definition.UserType, octets []byte) (map[string]interface{}, error) {
+	return c.Decode(userType.Fields(), octets)
+}
+
+type octetEncoder struct {
+	root   *definition.Root
+	buffer bytes.Buffer
+}
+
+func (e *octetEncoder) fields(fields []*definition.Field, value map[string]interface{}, depth int) error {
+	if depth > maxDepth {
+		return fmt.Errorf("types are nested too deep")
+	}
+	if err := checkFields(fields, value); err != nil {
+		return err
+	}
+	for _, field := range fields {
+		fieldValue, found := value[field.Name()]
+		if !found {
+			return fmt.Errorf("missing field '%v'", field.Name())
+		}
+		if err := e.field(field, fieldValue, depth); err != nil {
+			return fmt.Errorf("%v: %v", field.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (e *octetEncoder) field(field *definition.Field, value interface{}, depth int) error {
+	if !field.IsArray() {
+		return e.single(field, value, depth)
+	}
+	items, itemsErr := arrayValue(field, value)
+	if itemsErr != nil {
+		return itemsErr
+	}
+	e.uvarint(uint64(len(items)))
+	for index, item := range items {
+		if err := e.single(field, item, depth); err != nil {
+			return fmt.Errorf("[%d]: %v", index, err)
+		}
+	}
+	return nil
+}
+
+func (e *octetEncoder) single(field *definition.Field, value interface{}, depth int) error {
+	resolved := e.root.ResolveFieldType(field.FieldType())
+	switch resolved.Variant() {
+	case definition.FieldTypePrimitive:
+		return e.primitive(resolved.Primitive(), value)
+	case definition.FieldTypeEnum:
+		enumConstant, enumErr := enumValue(resolved.Enum(), value)
+		if enumErr != nil {
+			return enumErr
+		}
+		return e.primitive(resolved.Enum().StorageType(), enumConstant)
+	case definition.FieldTypeUserType, definition.FieldTypeComponent:
+		fields, fieldsErr := structValue(value)
+		if fieldsErr != nil {
+			return fieldsErr
+		}
+		return e.fields(structFields(resolved), fields, depth+1)
+	}
+	return fmt.Errorf("unknown type '%v'", field.FieldType())
+}
+
+func (e *octetEncoder) uvarint(v uint64) {
+	var octets [binary.MaxVarintLen64]byte
+	count := binary.PutUvarint(octets[:], v)
+	e.buffer.Write(octets[:count])
+}
+
+func (e *octetEncoder) fixed(v uint64, octetCount int) {
+	for index := octetCount - 1; index >= 0; index-- {
+		e.buffer.WriteByte(byte(v >> (uint(index) * 8)))
+	}
+}
+
+func (e *octetEncoder) primitive(primitive definition.PrimitiveType, value interface{}) error {
+	switch {
+	case primitive == definition.PrimitiveBool:
+		b, isBool := value.(bool)
+		if !isBool {
+			return fmt.Errorf("expected bool, got %T", value)
+		}
+		if b {
+			e.buffer.WriteByte(1)
+		} else {
+			e.buffer.WriteByte(0)
+		}
+	case primitive == definition.PrimitiveString:
+		s, isString := value.(string)
+		if !isString {
+			return fmt.Errorf("expected string, got %T", value)
+		}
+		e.uvarint(uint64(len(s)))
+		e.buffer.WriteString(s)
+	case primitive == definition.PrimitiveFloat32:
+		f, floatErr := floatValue(value)
+		if floatErr != nil {
+			return floatErr
+		}
+		e.fixed(uint64(math.Float32bits(float32(f))), 4)
+	case primitive == definition.PrimitiveFloat64:
+		f, floatErr := floatValue(value)
+		if floatErr != nil {
+			return floatErr
+		}
+		e.fixed(math.Float64bits(f), 8)
+	case primitive.IsSigned():
+		v, signedErr := signedValue(value)
+		if signedErr != nil {
+			return signedErr
+		}
+		if err := checkSignedRange(primitive, v); err != nil {
+			return err
+		}
+		e.fixed(uint64(v), primitive.BitSize()/8)
+	default:
+		v, unsignedErr := unsignedValue(value)
+		if unsignedErr != nil {
+			return unsignedErr
+		}
+		if err := checkUnsignedRange(primitive, v); err != nil {
+			return err
+		}
+		e.fixed(v, primitive.BitSize()/8)
+	}
+	return nil
+}
+
+type octetDecoder struct {
+	root   *definition.Root
+	reader *bytes.Reader
+}
+
+func (d *octetDecoder) fields(fields []*definition.Field, depth int) (map[string]interface{}, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("types are nested too deep")
+	}
+	value := make(map[string]interface{})
+	for _, field := range fields {
+		fieldValue, fieldErr := d.field(field, depth)
+		if fieldErr != nil {
+			return nil, fmt.Errorf("%v: %v", field.Name(), fieldErr)
+		}
+		value[field.Name()] = fieldValue
+	}
+	return value, nil
+}
+
+func (d *octetDecoder) field(field *definition.Field, depth int) (interface{}, error) {
+	if !field.IsArray() {
+		return d.single(field, depth)
+	}
+	count, countErr := binary.ReadUvarint(d.reader)
+	if countErr != nil {
+		return nil, fmt.Errorf("could not read array length: %v", countErr)
+	}
+	if count > uint64(field.Capacity()) {
+		return nil, fmt.Errorf("%d items exceeds capacity %d", count, field.Capacity())
+	}
+	items := make([]interface{}, 0, count)
+	for index := 0; index < int(count); index++ {
+		item, itemErr := d.single(field, depth)
+		if itemErr != nil {
+			return nil, fmt.Errorf("[%d]: %v", index, itemErr)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (d *octetDecoder) single(field *definition.Field, depth int) (interface{}, error) {
+	resolved := d.root.ResolveFieldType(field.FieldType())
+	switch resolved.Variant() {
+	case definition.FieldTypePrimitive:
+		return d.primitive(resolved.Primitive())
+	case definition.FieldTypeEnum:
+		storage := resolved.Enum().StorageType()
+		raw, rawErr := d.fixed(storage.BitSize() / 8)
+		if rawErr != nil {
+			return nil, rawErr
+		}
+		if storage.IsSigned() {
+			return decodedEnumValue(resolved.Enum(), signExtend(raw, storage.BitSize()))
+		}
+		return decodedEnumValue(resolved.Enum(), int64(raw))
+	case definition.FieldTypeUserType, definition.FieldTypeComponent:
+		return d.fields(structFields(resolved), depth+1)
+	}
+	return nil, fmt.Errorf("unknown type '%v'", field.FieldType())
+}
+
+func signExtend(v uint64, bits int) int64 {
+	shift := uint(64 - bits)
+	return int64(v<<shift) >> shift
+}
+
+func (d *octetDecoder) fixed(octetCount int) (uint64, error) {
+	var v uint64
+	for index := 0; index < octetCount; index++ {
+		octet, readErr := d.reader.ReadByte()
+		if readErr != nil {
+			return 0, io.ErrUnexpectedEOF
+		}
+		v = v<<8 | uint64(octet)
+	}
+	return v, nil
+}
+
+func (d *octetDecoder) primitive(primitive definition.PrimitiveType) (interface{}, error) {
+	switch {
+	case primitive == definition.PrimitiveBool:
+		octet, readErr := d.reader.ReadByte()
+		if readErr != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if octet > 1 {
+			return nil, fmt.Errorf("illegal bool value %d", octet)
+		}
+		return octet == 1, nil
+	case primitive == definition.PrimitiveString:
+		length, lengthErr := binary.ReadUvarint(d.reader)
+		if lengthErr != nil {
+			return nil, fmt.Errorf("could not read string length: %v", lengthErr)
+		}
+		if length > uint64(d.reader.Len()) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		octets := make([]byte, length)
+		d.reader.Read(octets)
+		return string(octets), nil
+	}
+
+	raw, rawErr := d.fixed(primitive.BitSize() / 8)
+	if rawErr != nil {
+		return nil, rawErr
+	}
+	switch {
+	case primitive == definition.PrimitiveFloat32:
+		return math.Float32frombits(uint32(raw)), nil
+	case primitive == definition.PrimitiveFloat64:
+		return math.Float64frombits(raw), nil
+	case primitive.IsSigned():
+		return typedSigned(primitive, signExtend(raw, primitive.BitSize())), nil
+	}
+	return typedUnsigned(primitive, raw), nil
+}
@@ -0,0 +1,187 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package serialize
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/piot/scrawl-go/src/definition"
+	"github.com/piot/scrawl-go/src/parser"
+	"github.com/piot/scrawl-go/src/scrawl"
+)
+
+const testProtocol = `
+enum State
+  Idle 0
+  Walking 1
+  Running 300
+
+type Strength
+  big int32 [min "0" max "1000"]
+  small uint8
+
+component Turret
+  angle float32
+  strength Strength
+  history int16 [capacity "4"]
+  name string
+
+event Jump
+  height int16
+  state State
+
+command Fire
+  target uint64
+  ratio float64
+  loaded bool
+  strengths Strength [capacity "2"]
+`
+
+func setupRoot(t *testing.T) *definition.Root {
+	root, err := scrawl.ParseStringWithOptions(testProtocol, parser.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestEncodeEvent(t *testing.T) {
+	root := setupRoot(t)
+	codec := NewCodec(root)
+	jump := root.Events()[0]
+
+	octets, err := codec.EncodeEvent(jump, map[string]interface{}{"height": -2, "state": "Running"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []byte{0xff, 0xfe, 0x01, 0x2c}
+	if !bytes.Equal(octets, expected) {
+		t.Errorf("wrong octets %x", octets)
+	}
+
+	value, decodeErr := codec.DecodeEvent(jump, octets)
+	if decodeErr != nil {
+		t.Fatal(decodeErr)
+	}
+	if value["height"] != int16(-2) || value["state"] != 300 {
+		t.Errorf("wrong value %v", value)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	root := setupRoot(t)
+	codec := NewCodec(root)
+
+	turret := root.FindComponentDataType("Turret")
+	turretValue := map[string]interface{}{
+		"angle":    float32(1.5),
+		"strength": map[string]interface{}{"big": int32(1000), "small": uint8(7)},
+		"history":  []interface{}{int16(-1), int16(2)},
+		"name":     "north",
+	}
+	octets, err := codec.EncodeComponent(turret, turretValue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, decodeErr := codec.DecodeComponent(turret, octets)
+	if decodeErr != nil {
+		t.Fatal(decodeErr)
+	}
+	if !reflect.DeepEqual(decoded, turretValue) {
+		t.Errorf("round trip differs %v %v", decoded, turretValue)
+	}
+
+	fire := root.Commands()[0]
+	fireValue := map[string]interface{}{
+		"target":    uint64(1) << 63,
+		"ratio":     0.25,
+		"loaded":    true,
+		"strengths": []interface{}{},
+	}
+	fireOctets, fireErr := codec.EncodeCommand(fire, fireValue)
+	if fireErr != nil {
+		t.Fatal(fireErr)
+	}
+	fireDecoded, fireDecodeErr := codec.DecodeCommand(fire, fireOctets)
+	if fireDecodeErr != nil {
+		t.Fatal(fireDecodeErr)
+	}
+	if !reflect.DeepEqual(fireDecoded, fireValue) {
+		t.Errorf("round trip differs %v %v", fireDecoded, fireValue)
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	root := setupRoot(t)
+	codec := NewCodec(root)
+	jump := root.Events()[0]
+	fire := root.Commands()[0]
+
+	invalid := []struct {
+		fields []*definition.Field
+		value  map[string]interface{}
+	}{
+		{jump.Fields(), map[string]interface{}{"height": 1}},
+		{jump.Fields(), map[string]interface{}{"height": 1, "state": 0, "extra": 2}},
+		{jump.Fields(), map[string]interface{}{"height": 40000, "state": 0}},
+		{jump.Fields(), map[string]interface{}{"height": 1.5, "state": 0}},
+		{jump.Fields(), map[string]interface{}{"height": 1, "state": 2}},
+		{jump.Fields(), map[string]interface{}{"height": 1, "state": "Flying"}},
+		{fire.Fields(), map[string]interface{}{"target": -1, "ratio": 0, "loaded": false, "strengths": []interface{}{}}},
+		{fire.Fields(), map[string]interface{}{"target": 1, "ratio": 0, "loaded": 1, "strengths": []interface{}{}}},
+		{fire.Fields(), map[string]interface{}{"target": 1, "ratio": 0, "loaded": false, "strengths": []interface{}{
+			map[string]interface{}{"big": 1, "small": 1}, map[string]interface{}{"big": 1, "small": 1},
+			map[string]interface{}{"big": 1, "small": 1}}}},
+	}
+
+	for index, test := range invalid {
+		if _, err := codec.Encode(test.fields, test.value); err == nil {
+			t.Errorf("%d: %v should not encode", index, test.value)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	root := setupRoot(t)
+	codec := NewCodec(root)
+	jump := root.Events()[0]
+
+	invalid := [][]byte{
+		{0x00, 0x01, 0x00},
+		{0x00, 0x01, 0x00, 0x02},
+		{0x00, 0x01, 0x00, 0x00, 0x00},
+	}
+
+	for _, octets := range invalid {
+		if _, err := codec.DecodeEvent(jump, octets); err == nil {
+			t.Errorf("%x should not decode", octets)
+		}
+	}
+}
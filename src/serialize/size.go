@@ -0,0 +1,107 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package serialize
+
+import (
+	"fmt"
+
+	"github.com/piot/scrawl-go/src/definition"
+)
+
+// TypicalStringOctetCount is the string length used for typical sizes.
+const TypicalStringOctetCount = 8
+
+// Size is the number of bits used by BitCodec. Typical assumes arrays that are half full and
+// strings of TypicalStringOctetCount octets.
+type Size struct {
+	Min     int
+	Max     int
+	Typical int
+}
+
+func (s Size) Add(other Size) Size {
+	return Size{Min: s.Min + other.Min, Max: s.Max + other.Max, Typical: s.Typical + other.Typical}
+}
+
+func (s Size) String() string {
+	return fmt.Sprintf("[size min:%d max:%d typical:%d]", s.Min, s.Max, s.Typical)
+}
+
+// FieldsSize returns the number of bits used for the fields.
+func (c *BitCodec) FieldsSize(fields []*definition.Field) (Size, error) {
+	return c.fieldsSize(fields, 0)
+}
+
+// FieldSize returns the number of bits used for a single field.
+func (c *BitCodec) FieldSize(field *definition.Field) (Size, error) {
+	return c.fieldSize(field, 0)
+}
+
+func (c *BitCodec) fieldsSize(fields []*definition.Field, depth int) (Size, error) {
+	if depth > maxDepth {
+		return Size{}, fmt.Errorf("types are nested too deep")
+	}
+	var total Size
+	for _, field := range fields {
+		size, sizeErr := c.fieldSize(field, depth)
+		if sizeErr != nil {
+			return Size{}, fmt.Errorf("%v: %v", field.Name(), sizeErr)
+		}
+		total = total.Add(size)
+	}
+	return total, nil
+}
+
+func (c *BitCodec) fieldSize(field *definition.Field, depth int) (Size, error) {
+	p, packingErr := newPacking(c.root, field)
+	if packingErr != nil {
+		return Size{}, packingErr
+	}
+	single, singleErr := c.singleSize(p, depth)
+	if singleErr != nil {
+		return Size{}, singleErr
+	}
+	if !field.IsArray() {
+		return single, nil
+	}
+	countBits := bitsFor(uint64(field.Capacity()))
+	typicalCount := (field.Capacity() + 1) / 2
+	return Size{Min: countBits, Max: countBits + field.Capacity()*single.Max,
+		Typical: countBits + typicalCount*single.Typical}, nil
+}
+
+func (c *BitCodec) singleSize(p *packing, depth int) (Size, error) {
+	switch {
+	case p.fields != nil:
+		return c.fieldsSize(p.fields, depth+1)
+	case p.primitive == definition.PrimitiveString:
+		maxOctetCount := 1<<StringLengthBits - 1
+		return Size{Min: StringLengthBits, Max: StringLengthBits + maxOctetCount*8,
+			Typical: StringLengthBits + TypicalStringOctetCount*8}, nil
+	}
+	return Size{Min: p.bits, Max: p.bits, Typical: p.bits}, nil
+}
@@ -0,0 +1,267 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package serialize
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+
+	"github.com/piot/scrawl-go/src/definition"
+)
+
+// maxDepth stops user types that contain themselves from recursing forever.
+const maxDepth = 32
+
+func signedValue(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), nil
+	case int8:
+		return int64(v), nil
+	case int16:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case uint:
+		return signedFromUnsigned(uint64(v))
+	case uint8:
+		return int64(v), nil
+	case uint16:
+		return int64(v), nil
+	case uint32:
+		return int64(v), nil
+	case uint64:
+		return signedFromUnsigned(v)
+	case float32:
+		return signedFromFloat(float64(v))
+	case float64:
+		return signedFromFloat(v)
+	case json.Number:
+		return strconv.ParseInt(string(v), 10, 64)
+	}
+	return 0, fmt.Errorf("expected an integer, got %T", value)
+}
+
+func signedFromUnsigned(v uint64) (int64, error) {
+	if v > math.MaxInt64 {
+		return 0, fmt.Errorf("%d is out of range", v)
+	}
+	return int64(v), nil
+}
+
+func signedFromFloat(v float64) (int64, error) {
+	if v != math.Trunc(v) || v < math.MinInt64 || v >= math.MaxInt64 {
+		return 0, fmt.Errorf("%v is not an integer", v)
+	}
+	return int64(v), nil
+}
+
+func unsignedValue(value interface{}) (uint64, error) {
+	switch v := value.(type) {
+	case uint:
+		return uint64(v), nil
+	case uint8:
+		return uint64(v), nil
+	case uint16:
+		return uint64(v), nil
+	case uint32:
+		return uint64(v), nil
+	case uint64:
+		return v, nil
+	case float32:
+		return unsignedFromFloat(float64(v))
+	case float64:
+		return unsignedFromFloat(v)
+	case json.Number:
+		return strconv.ParseUint(string(v), 10, 64)
+	}
+	signed, signedErr := signedValue(value)
+	if signedErr != nil {
+		return 0, signedErr
+	}
+	if signed < 0 {
+		return 0, fmt.Errorf("%d is negative", signed)
+	}
+	return uint64(signed), nil
+}
+
+func unsignedFromFloat(v float64) (uint64, error) {
+	if v != math.Trunc(v) || v < 0 || v >= math.MaxUint64 {
+		return 0, fmt.Errorf("%v is not an unsigned integer", v)
+	}
+	return uint64(v), nil
+}
+
+func floatValue(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case json.Number:
+		return v.Float64()
+	}
+	signed, signedErr := signedValue(value)
+	if signedErr == nil {
+		return float64(signed), nil
+	}
+	unsigned, unsignedErr := unsignedValue(value)
+	if unsignedErr == nil {
+		return float64(unsigned), nil
+	}
+	return 0, fmt.Errorf("expected a number, got %T", value)
+}
+
+// checkSignedRange checks that an integer fits in the primitive type.
+func checkSignedRange(primitive definition.PrimitiveType, v int64) error {
+	bits := uint(primitive.BitSize())
+	if bits >= 64 {
+		return nil
+	}
+	lowest := -(int64(1) << (bits - 1))
+	highest := int64(1)<<(bits-1) - 1
+	if v < lowest || v > highest {
+		return fmt.Errorf("%d does not fit in %v", v, primitive)
+	}
+	return nil
+}
+
+func checkUnsignedRange(primitive definition.PrimitiveType, v uint64) error {
+	bits := uint(primitive.BitSize())
+	if bits < 64 && v >= uint64(1)<<bits {
+		return fmt.Errorf("%d does not fit in %v", v, primitive)
+	}
+	return nil
+}
+
+// typedSigned converts a decoded value to the Go type of the primitive.
+func typedSigned(primitive definition.PrimitiveType, v int64) interface{} {
+	switch primitive {
+	case definition.PrimitiveInt8:
+		return int8(v)
+	case definition.PrimitiveInt16:
+		return int16(v)
+	case definition.PrimitiveInt32:
+		return int32(v)
+	}
+	return v
+}
+
+func typedUnsigned(primitive definition.PrimitiveType, v uint64) interface{} {
+	switch primitive {
+	case definition.PrimitiveUint8:
+		return uint8(v)
+	case definition.PrimitiveUint16:
+		return uint16(v)
+	case definition.PrimitiveUint32:
+		return uint32(v)
+	}
+	return v
+}
+
+// enumValue accepts either the name of a constant or its value.
+func enumValue(enum *definition.Enum, value interface{}) (int, error) {
+	name, isName := value.(string)
+	if isName {
+		constant := enum.FindConstant(name)
+		if constant == nil {
+			return 0, fmt.Errorf("'%v' is not a constant in enum '%v'", name, enum.Name())
+		}
+		return constant.Value(), nil
+	}
+	signed, signedErr := signedValue(value)
+	if signedErr != nil {
+		return 0, signedErr
+	}
+	if enum.FindConstantByValue(int(signed)) == nil {
+		return 0, fmt.Errorf("%d is not a value in enum '%v'", signed, enum.Name())
+	}
+	return int(signed), nil
+}
+
+func decodedEnumValue(enum *definition.Enum, v int64) (int, error) {
+	if enum.FindConstantByValue(int(v)) == nil {
+		return 0, fmt.Errorf("%d is not a value in enum '%v'", v, enum.Name())
+	}
+	return int(v), nil
+}
+
+func structValue(value interface{}) (map[string]interface{}, error) {
+	fields, isMap := value.(map[string]interface{})
+	if !isMap {
+		return nil, fmt.Errorf("expected map[string]interface{}, got %T", value)
+	}
+	return fields, nil
+}
+
+// arrayValue accepts any slice.
+func arrayValue(field *definition.Field, value interface{}) ([]interface{}, error) {
+	items, isItems := value.([]interface{})
+	if !isItems {
+		reflected := reflect.ValueOf(value)
+		if reflected.Kind() != reflect.Slice {
+			return nil, fmt.Errorf("expected a slice, got %T", value)
+		}
+		for index := 0; index < reflected.Len(); index++ {
+			items = append(items, reflected.Index(index).Interface())
+		}
+	}
+	if len(items) > field.Capacity() {
+		return nil, fmt.Errorf("%d items exceeds capacity %d", len(items), field.Capacity())
+	}
+	return items, nil
+}
+
+// checkFields makes sure that there are no values for fields that do not exist.
+func checkFields(fields []*definition.Field, value map[string]interface{}) error {
+	for name := range value {
+		found := false
+		for _, field := range fields {
+			if field.Name() == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown field '%v'", name)
+		}
+	}
+	return nil
+}
+
+// structFields returns the fields of a user type or component that is used as a field type.
+func structFields(resolved *definition.ResolvedFieldType) []*definition.Field {
+	if resolved.Variant() == definition.FieldTypeUserType {
+		return resolved.UserType().Fields()
+	}
+	return resolved.ComponentDataType().Fields()
+}
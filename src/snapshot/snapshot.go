@@ -0,0 +1,203 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package snapshot
+
+import (
+	"fmt"
+
+	"github.com/piot/scrawl-go/src/bitstream"
+	"github.com/piot/scrawl-go/src/definition"
+	"github.com/piot/scrawl-go/src/delta"
+	"github.com/piot/scrawl-go/src/serialize"
+)
+
+// State is what the receiving side knows about an entity: the level of detail and the last value of
+// every item in it. Lod is -1 before the first snapshot.
+type State struct {
+	Lod    int
+	Values map[string]map[string]interface{}
+}
+
+func NewState() *State {
+	return &State{Lod: -1, Values: make(map[string]map[string]interface{})}
+}
+
+// Codec writes the state of an entity for one level of detail.
+//
+// A snapshot starts with the level of detail, using enough bits for the levels in the archetype.
+// It is followed by the items of that level in order. Items that were also in the level of detail
+// of the baseline are written as a delta.Codec delta, items that are newly visible are written in
+// full. Items that are not components declared in the protocol, like WorldPosition, are handled by
+// the engine and are not written.
+type Codec struct {
+	root  *definition.Root
+	delta *delta.Codec
+}
+
+func NewCodec(root *definition.Root) *Codec {
+	return &Codec{root: root, delta: delta.NewCodec(root)}
+}
+
+func lodBits(archetype *definition.EntityArchetype) int {
+	if len(archetype.Lods()) < 2 {
+		return 0
+	}
+	bits := 0
+	for highest := len(archetype.Lods()) - 1; highest != 0; highest >>= 1 {
+		bits++
+	}
+	return bits
+}
+
+// Components returns the items in a level of detail that are written in snapshots.
+func (c *Codec) Components(lod *definition.EntityArchetypeLOD) []*definition.ComponentDataType {
+	var components []*definition.ComponentDataType
+	for _, item := range lod.Items() {
+		if !item.HasComponentReference() {
+			continue
+		}
+		component := c.root.FindComponentDataType(item.Name())
+		if component != nil {
+			components = append(components, component)
+		}
+	}
+	return components
+}
+
+func checkLod(archetype *definition.EntityArchetype, lod int) error {
+	if lod < 0 || lod >= len(archetype.Lods()) {
+		return fmt.Errorf("archetype '%v' has no lod%d", archetype.Name(), lod)
+	}
+	return nil
+}
+
+func (c *Codec) wasVisible(archetype *definition.EntityArchetype, baseline *State, name string) bool {
+	if baseline == nil || baseline.Lod < 0 || baseline.Lod >= len(archetype.Lods()) {
+		return false
+	}
+	return archetype.Lod(baseline.Lod).FindItem(name) != nil && baseline.Values[name] != nil
+}
+
+// Size returns the number of bits a snapshot for the level of detail uses. Min is a snapshot where
+// nothing has changed, Max and Typical are snapshots where every item is written in full.
+func (c *Codec) Size(archetype *definition.EntityArchetype, lod int) (serialize.Size, error) {
+	if err := checkLod(archetype, lod); err != nil {
+		return serialize.Size{}, err
+	}
+	bits := lodBits(archetype)
+	total := serialize.Size{Min: bits, Max: bits, Typical: bits}
+	for _, component := range c.Components(archetype.Lod(lod)) {
+		size, sizeErr := c.delta.FieldsSize(component.Fields())
+		if sizeErr != nil {
+			return serialize.Size{}, fmt.Errorf("%v: %v", component.Name(), sizeErr)
+		}
+		total = total.Add(size)
+	}
+	return total, nil
+}
+
+// Write writes a snapshot of values for the level of detail, and returns the state the receiver will
+// have after reading it. Values for items that are not in the level of detail are ignored. The state
+// holds copies of the values, so the caller can keep updating the same maps.
+func (c *Codec) Write(writer *bitstream.Writer, archetype *definition.EntityArchetype, baseline *State, lod int,
+	values map[string]map[string]interface{}) (*State, error) {
+	if err := checkLod(archetype, lod); err != nil {
+		return nil, err
+	}
+	writer.WriteBits(uint64(lod), lodBits(archetype))
+
+	next := &State{Lod: lod, Values: make(map[string]map[string]interface{})}
+	for _, component := range c.Components(archetype.Lod(lod)) {
+		value, found := values[component.Name()]
+		if !found {
+			return nil, fmt.Errorf("archetype '%v' lod%d needs a value for '%v'", archetype.Name(), lod, component.Name())
+		}
+		var previous map[string]interface{}
+		if c.wasVisible(archetype, baseline, component.Name()) {
+			previous = baseline.Values[component.Name()]
+		}
+		if err := c.delta.WriteFields(writer, component.Fields(), previous, value); err != nil {
+			return nil, fmt.Errorf("%v: %v", component.Name(), err)
+		}
+		next.Values[component.Name()] = delta.Copy(value).(map[string]interface{})
+	}
+
+	return next, nil
+}
+
+// Read reads a snapshot written by Write and returns the new state. The baseline is not modified.
+func (c *Codec) Read(reader *bitstream.Reader, archetype *definition.EntityArchetype, baseline *State) (*State, error) {
+	if err := checkLod(archetype, 0); err != nil {
+		return nil, err
+	}
+	lodValue, lodErr := reader.ReadBits(lodBits(archetype))
+	if lodErr != nil {
+		return nil, lodErr
+	}
+	lod := int(lodValue)
+	if err := checkLod(archetype, lod); err != nil {
+		return nil, err
+	}
+
+	next := &State{Lod: lod, Values: make(map[string]map[string]interface{})}
+	for _, component := range c.Components(archetype.Lod(lod)) {
+		var previous map[string]interface{}
+		if c.wasVisible(archetype, baseline, component.Name()) {
+			previous = baseline.Values[component.Name()]
+		}
+		value, valueErr := c.delta.ReadFields(reader, component.Fields(), previous)
+		if valueErr != nil {
+			return nil, fmt.Errorf("%v: %v", component.Name(), valueErr)
+		}
+		next.Values[component.Name()] = value
+	}
+
+	return next, nil
+}
+
+// Encode writes a snapshot padded to a whole octet.
+func (c *Codec) Encode(archetype *definition.EntityArchetype, baseline *State, lod int,
+	values map[string]map[string]interface{}) ([]byte, *State, error) {
+	writer := bitstream.NewWriter()
+	next, err := c.Write(writer, archetype, baseline, lod, values)
+	if err != nil {
+		return nil, nil, err
+	}
+	return writer.Octets(), next, nil
+}
+
+func (c *Codec) Decode(archetype *definition.EntityArchetype, baseline *State, octets []byte) (*State, error) {
+	reader := bitstream.NewReader(octets)
+	next, err := c.Read(reader, archetype, baseline)
+	if err != nil {
+		return nil, err
+	}
+	if !reader.IsPadding() {
+		return nil, fmt.Errorf("%d bits left after decoding", reader.BitsLeft())
+	}
+	return next, nil
+}
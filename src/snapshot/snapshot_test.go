@@ -0,0 +1,181 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package snapshot
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/piot/scrawl-go/src/bitstream"
+	"github.com/piot/scrawl-go/src/definition"
+	"github.com/piot/scrawl-go/src/parser"
+	"github.com/piot/scrawl-go/src/scrawl"
+)
+
+const testProtocol = `
+component Health
+  value uint8
+
+component Animation
+  frame uint16
+  speed uint8
+
+archetype Tower
+  lod 0
+    WorldPosition
+    Health
+    Animation
+  lod 1 from 0 without Animation
+
+archetype Empty
+`
+
+func setupArchetype(t *testing.T) (*definition.Root, *definition.EntityArchetype) {
+	root, err := scrawl.ParseStringWithOptions(testProtocol, parser.Options{AllowedComponentFields: []string{"WorldPosition"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root, root.FindEntity("Tower")
+}
+
+func towerValues(health int, frame int) map[string]map[string]interface{} {
+	return map[string]map[string]interface{}{
+		"Health":    {"value": uint8(health)},
+		"Animation": {"frame": uint16(frame), "speed": uint8(1)},
+	}
+}
+
+func send(t *testing.T, codec *Codec, archetype *definition.EntityArchetype, sent *State, received *State, lod int,
+	values map[string]map[string]interface{}) (*State, *State, int) {
+	writer := bitstream.NewWriter()
+	nextSent, writeErr := codec.Write(writer, archetype, sent, lod, values)
+	if writeErr != nil {
+		t.Fatal(writeErr)
+	}
+	nextReceived, readErr := codec.Decode(archetype, received, writer.Octets())
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+	if !reflect.DeepEqual(nextSent, nextReceived) {
+		t.Fatalf("sent %v, received %v", nextSent, nextReceived)
+	}
+	return nextSent, nextReceived, writer.BitCount()
+}
+
+func TestLodTransitions(t *testing.T) {
+	root, archetype := setupArchetype(t)
+	codec := NewCodec(root)
+
+	sent, received, bits := send(t, codec, archetype, NewState(), NewState(), 1, towerValues(80, 5))
+	if bits != 1+1+8 || len(received.Values) != 1 {
+		t.Errorf("lod1 should only send Health in full, got %d bits %v", bits, received)
+	}
+
+	sent, received, bits = send(t, codec, archetype, sent, received, 1, towerValues(80, 6))
+	if bits != 1+1 {
+		t.Errorf("unchanged Health should only send the mask, got %d bits", bits)
+	}
+
+	sent, received, bits = send(t, codec, archetype, sent, received, 0, towerValues(80, 7))
+	if bits != 1+1+2+16+8 || received.Values["Animation"]["frame"] != uint16(7) {
+		t.Errorf("newly visible Animation should be sent in full, got %d bits %v", bits, received)
+	}
+
+	sent, received, bits = send(t, codec, archetype, sent, received, 0, towerValues(80, 8))
+	if bits != 1+1+2+16 {
+		t.Errorf("only the changed frame should be sent, got %d bits", bits)
+	}
+
+	sent, received, _ = send(t, codec, archetype, sent, received, 1, towerValues(70, 8))
+	if received.Values["Animation"] != nil {
+		t.Errorf("Animation should be dropped in lod1 %v", received)
+	}
+
+	_, _, bits = send(t, codec, archetype, sent, received, 0, towerValues(70, 8))
+	if bits != 1+1+2+16+8 {
+		t.Errorf("Animation should be sent in full again, got %d bits", bits)
+	}
+}
+
+func TestValuesChangedInPlace(t *testing.T) {
+	root, archetype := setupArchetype(t)
+	codec := NewCodec(root)
+	values := towerValues(10, 5)
+
+	sent, received, _ := send(t, codec, archetype, NewState(), NewState(), 0, values)
+	values["Health"]["value"] = uint8(50)
+	_, received, bits := send(t, codec, archetype, sent, received, 0, values)
+	if bits != 1+1+8+2 || received.Values["Health"]["value"] != uint8(50) {
+		t.Errorf("change made in place was not sent, got %d bits %v", bits, received)
+	}
+}
+
+func TestSnapshotErrors(t *testing.T) {
+	root, archetype := setupArchetype(t)
+	codec := NewCodec(root)
+
+	if _, _, err := codec.Encode(archetype, NewState(), 2, towerValues(1, 1)); err == nil {
+		t.Errorf("unknown lod should be reported")
+	}
+
+	if _, _, err := codec.Encode(archetype, NewState(), 0, map[string]map[string]interface{}{"Health": {"value": 1}}); err == nil {
+		t.Errorf("missing component value should be reported")
+	}
+
+	empty := root.FindEntity("Empty")
+	if _, err := codec.Decode(empty, NewState(), []byte{0}); err == nil {
+		t.Errorf("archetype without lods should be reported")
+	}
+	if _, err := codec.Size(empty, 0); err == nil {
+		t.Errorf("archetype without lods has no size")
+	}
+}
+
+func TestSize(t *testing.T) {
+	root, archetype := setupArchetype(t)
+	codec := NewCodec(root)
+
+	full, err := codec.Size(archetype, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if full.Min != 1+1+2 || full.Max != 1+1+8+2+16+8 || full.Typical != full.Max {
+		t.Errorf("wrong lod0 size %v", full)
+	}
+
+	reduced, reducedErr := codec.Size(archetype, 1)
+	if reducedErr != nil {
+		t.Fatal(reducedErr)
+	}
+	if reduced.Min != 1+1 || reduced.Max != 1+1+8 {
+		t.Errorf("wrong lod1 size %v", reduced)
+	}
+
+	if _, err := codec.Size(archetype, 2); err == nil {
+		t.Errorf("should fail for unknown lod")
+	}
+}
@@ -33,6 +33,10 @@ type Position struct {
 	column int
 }
 
+func NewPosition(line int, column int) Position {
+	return Position{line: line, column: column}
+}
+
 func NewPositionTopLeft() Position {
 	return Position{line: 1, column: 1}
 }
@@ -49,6 +53,14 @@ func (p Position) NextColumn() Position {
 	return Position{line: p.line, column: p.column + 1}
 }
 
+func (p Position) Line() int {
+	return p.line
+}
+
+func (p Position) Column() int {
+	return p.column
+}
+
 func (p Position) String() string {
 	return fmt.Sprintf("[%d:%d]", p.line, p.column)
 }
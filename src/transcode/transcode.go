@@ -0,0 +1,200 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package transcode
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/piot/scrawl-go/src/definition"
+	"github.com/piot/scrawl-go/src/serialize"
+	"github.com/piot/scrawl-go/src/value"
+)
+
+type Format uint8
+
+const (
+	// Octet is the format of serialize.Codec.
+	Octet Format = iota
+	// Bit is the format of serialize.BitCodec.
+	Bit
+)
+
+func (f Format) String() string {
+	switch f {
+	case Octet:
+		return "octet"
+	case Bit:
+		return "bit"
+	}
+	return fmt.Sprintf("unknown-format-%d", uint8(f))
+}
+
+func ParseFormat(name string) (Format, error) {
+	for _, format := range []Format{Octet, Bit} {
+		if format.String() == name {
+			return format, nil
+		}
+	}
+	return Octet, fmt.Errorf("unknown format '%v'", name)
+}
+
+type codec interface {
+	Encode(fields []*definition.Field, value map[string]interface{}) ([]byte, error)
+	Decode(fields []*definition.Field, octets []byte) (map[string]interface{}, error)
+}
+
+// Transcoder converts encoded events, commands and components to JSON and back. In the JSON,
+// enum values are written as the constant names. When reading JSON, fields that are left out
+// get their zero value and enums can be given either as names or as numbers.
+type Transcoder struct {
+	root  *definition.Root
+	codec codec
+}
+
+func NewTranscoder(root *definition.Root, format Format) *Transcoder {
+	var c codec = serialize.NewCodec(root)
+	if format == Bit {
+		c = serialize.NewBitCodec(root)
+	}
+	return &Transcoder{root: root, codec: c}
+}
+
+// Fields returns the fields of the event, command or component with the index.
+func (t *Transcoder) Fields(kind definition.DefinitionKind, index int) ([]*definition.Field, error) {
+	switch kind {
+	case definition.DefinitionKindEvent:
+		if index >= 0 && index < len(t.root.Events()) {
+			return t.root.Events()[index].Fields(), nil
+		}
+	case definition.DefinitionKindCommand:
+		if index >= 0 && index < len(t.root.Commands()) {
+			return t.root.Commands()[index].Fields(), nil
+		}
+	case definition.DefinitionKindComponent:
+		if index >= 0 && index < len(t.root.ComponentDataTypes()) {
+			return t.root.ComponentDataTypes()[index].Fields(), nil
+		}
+	default:
+		return nil, fmt.Errorf("can not transcode %v", kind)
+	}
+	return nil, fmt.Errorf("unknown %v index %d", kind, index)
+}
+
+// readable replaces enum values with the constant names.
+func readable(v *value.Value) interface{} {
+	switch v.Kind() {
+	case value.Enum:
+		if v.EnumConstant() != nil {
+			return v.EnumConstant().Name()
+		}
+		return v.Int()
+	case value.Struct:
+		fields := make(map[string]interface{})
+		for index, field := range v.Fields() {
+			fields[field.Name()] = readable(v.FieldByIndex(index))
+		}
+		return fields
+	case value.Array:
+		items := []interface{}{}
+		for _, item := range v.Items() {
+			items = append(items, readable(item))
+		}
+		return items
+	}
+	return v.Interface()
+}
+
+// ToJSON decodes octets and returns the value as indented JSON.
+func (t *Transcoder) ToJSON(kind definition.DefinitionKind, index int, octets []byte) ([]byte, error) {
+	fields, fieldsErr := t.Fields(kind, index)
+	if fieldsErr != nil {
+		return nil, fieldsErr
+	}
+	decoded, decodeErr := t.codec.Decode(fields, octets)
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+	v, valueErr := value.NewStruct(t.root, fields)
+	if valueErr != nil {
+		return nil, valueErr
+	}
+	if err := v.SetInterface(decoded); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(readable(v), "", "  ")
+}
+
+// FromJSON reads a JSON object, validates it against the definition and returns it encoded.
+func (t *Transcoder) FromJSON(kind definition.DefinitionKind, index int, text []byte) ([]byte, error) {
+	fields, fieldsErr := t.Fields(kind, index)
+	if fieldsErr != nil {
+		return nil, fieldsErr
+	}
+	decoder := json.NewDecoder(bytes.NewReader(text))
+	decoder.UseNumber()
+	var parsed interface{}
+	if err := decoder.Decode(&parsed); err != nil {
+		return nil, err
+	}
+	v, valueErr := value.NewStruct(t.root, fields)
+	if valueErr != nil {
+		return nil, valueErr
+	}
+	if err := v.SetInterface(parsed); err != nil {
+		return nil, err
+	}
+	if err := v.Validate(); err != nil {
+		return nil, err
+	}
+	return t.codec.Encode(fields, v.Interface().(map[string]interface{}))
+}
+
+func (t *Transcoder) EventToJSON(index definition.EventTypeIndex, octets []byte) ([]byte, error) {
+	return t.ToJSON(definition.DefinitionKindEvent, int(index), octets)
+}
+
+func (t *Transcoder) EventFromJSON(index definition.EventTypeIndex, text []byte) ([]byte, error) {
+	return t.FromJSON(definition.DefinitionKindEvent, int(index), text)
+}
+
+func (t *Transcoder) CommandToJSON(index definition.CommandTypeIndex, octets []byte) ([]byte, error) {
+	return t.ToJSON(definition.DefinitionKindCommand, int(index), octets)
+}
+
+func (t *Transcoder) CommandFromJSON(index definition.CommandTypeIndex, text []byte) ([]byte, error) {
+	return t.FromJSON(definition.DefinitionKindCommand, int(index), text)
+}
+
+func (t *Transcoder) ComponentToJSON(index uint8, octets []byte) ([]byte, error) {
+	return t.ToJSON(definition.DefinitionKindComponent, int(index), octets)
+}
+
+func (t *Transcoder) ComponentFromJSON(index uint8, text []byte) ([]byte, error) {
+	return t.FromJSON(definition.DefinitionKindComponent, int(index), text)
+}
@@ -0,0 +1,140 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package transcode
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/piot/scrawl-go/src/definition"
+	"github.com/piot/scrawl-go/src/parser"
+	"github.com/piot/scrawl-go/src/scrawl"
+)
+
+const testProtocol = `
+enum State
+  Idle 0
+  Running 1
+
+type Vector
+  x int16
+  y int16
+
+component Avatar
+  health uint8 [max "100"]
+  position Vector
+
+event Jump
+  height int16
+  state State
+
+command Move
+  path Vector [capacity "2"]
+`
+
+func setupRoot(t *testing.T) *definition.Root {
+	root, err := scrawl.ParseStringWithOptions(testProtocol, parser.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestEventToJSON(t *testing.T) {
+	transcoder := NewTranscoder(setupRoot(t), Octet)
+
+	text, err := transcoder.EventToJSON(0, []byte{0x00, 0x10, 0x01})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var parsed map[string]interface{}
+	json.Unmarshal(text, &parsed)
+	expected := map[string]interface{}{"height": 16.0, "state": "Running"}
+	if !reflect.DeepEqual(parsed, expected) {
+		t.Errorf("wrong json %v", string(text))
+	}
+
+	octets, fromErr := transcoder.EventFromJSON(0, text)
+	if fromErr != nil {
+		t.Fatal(fromErr)
+	}
+	if !bytes.Equal(octets, []byte{0x00, 0x10, 0x01}) {
+		t.Errorf("wrong octets %x", octets)
+	}
+}
+
+func TestFromJSON(t *testing.T) {
+	for _, format := range []Format{Octet, Bit} {
+		transcoder := NewTranscoder(setupRoot(t), format)
+
+		octets, err := transcoder.CommandFromJSON(0, []byte(`{"path": [{"x": -1}, {"x": 2, "y": 3}]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		text, toErr := transcoder.CommandToJSON(0, octets)
+		if toErr != nil {
+			t.Fatal(toErr)
+		}
+		var parsed map[string]interface{}
+		json.Unmarshal(text, &parsed)
+		path := parsed["path"].([]interface{})
+		if len(path) != 2 || path[0].(map[string]interface{})["y"] != 0.0 || path[1].(map[string]interface{})["y"] != 3.0 {
+			t.Errorf("%v: wrong json %v", format, string(text))
+		}
+
+		componentOctets, componentErr := transcoder.ComponentFromJSON(0, []byte(`{"health": 100}`))
+		if componentErr != nil {
+			t.Fatal(componentErr)
+		}
+		if _, err := transcoder.ComponentToJSON(0, componentOctets); err != nil {
+			t.Errorf("%v: %v", format, err)
+		}
+	}
+}
+
+func TestTranscodeErrors(t *testing.T) {
+	transcoder := NewTranscoder(setupRoot(t), Octet)
+
+	if _, err := transcoder.EventToJSON(1, []byte{0}); err == nil {
+		t.Errorf("unknown index should be reported")
+	}
+	if _, err := transcoder.ComponentFromJSON(0, []byte(`{"health": 101}`)); err == nil {
+		t.Errorf("value above max should be reported")
+	}
+	if _, err := transcoder.EventFromJSON(0, []byte(`{"state": "Flying"}`)); err == nil {
+		t.Errorf("unknown enum constant should be reported")
+	}
+	if _, err := transcoder.EventFromJSON(0, []byte(`{"speed": 1}`)); err == nil {
+		t.Errorf("unknown field should be reported")
+	}
+	if _, err := transcoder.EventToJSON(0, []byte{0x00, 0x10, 0x02}); err == nil {
+		t.Errorf("illegal enum value should be reported")
+	}
+}
@@ -0,0 +1,199 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package value
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+
+	"github.com/piot/scrawl-go/src/definition"
+)
+
+// Interface returns the value in the representation used by the serialize package:
+// map[string]interface{} for structs, []interface{} for arrays, int for enums and the Go type
+// of the primitive for the rest.
+func (v *Value) Interface() interface{} {
+	switch v.kind {
+	case Bool:
+		return v.b
+	case Int:
+		switch v.primitive {
+		case definition.PrimitiveInt8:
+			return int8(v.i)
+		case definition.PrimitiveInt16:
+			return int16(v.i)
+		case definition.PrimitiveInt32:
+			return int32(v.i)
+		}
+		return v.i
+	case Uint:
+		switch v.primitive {
+		case definition.PrimitiveUint8:
+			return uint8(v.u)
+		case definition.PrimitiveUint16:
+			return uint16(v.u)
+		case definition.PrimitiveUint32:
+			return uint32(v.u)
+		}
+		return v.u
+	case Float:
+		if v.primitive == definition.PrimitiveFloat32 {
+			return float32(v.f)
+		}
+		return v.f
+	case String:
+		return v.s
+	case Enum:
+		return int(v.i)
+	case Struct:
+		fields := make(map[string]interface{}, len(v.members))
+		for index, field := range v.fields {
+			fields[field.Name()] = v.members[index].Interface()
+		}
+		return fields
+	case Array:
+		items := make([]interface{}, 0, len(v.items))
+		for _, item := range v.items {
+			items = append(items, item.Interface())
+		}
+		return items
+	}
+	return nil
+}
+
+// SetInterface sets the value from the representation returned by Interface. Any Go number is
+// accepted for numbers, and enums can also be set with the constant name. Struct fields that are
+// not in the map keep their values.
+func (v *Value) SetInterface(x interface{}) error {
+	if number, isNumber := x.(json.Number); isNumber {
+		return v.setNumberText(string(number))
+	}
+
+	switch v.kind {
+	case Bool:
+		b, isBool := x.(bool)
+		if !isBool {
+			return fmt.Errorf("expected bool, got %T", x)
+		}
+		return v.SetBool(b)
+	case String:
+		s, isString := x.(string)
+		if !isString {
+			return fmt.Errorf("expected string, got %T", x)
+		}
+		return v.SetString(s)
+	case Enum:
+		if name, isName := x.(string); isName {
+			return v.SetEnum(name)
+		}
+		return v.setNumber(x)
+	case Int, Uint, Float:
+		return v.setNumber(x)
+	case Struct:
+		fields, isMap := x.(map[string]interface{})
+		if !isMap {
+			return fmt.Errorf("expected map[string]interface{}, got %T", x)
+		}
+		for name, fieldValue := range fields {
+			member := v.FieldByName(name)
+			if member == nil {
+				return fmt.Errorf("unknown field '%v'", name)
+			}
+			if err := member.SetInterface(fieldValue); err != nil {
+				return fmt.Errorf("%v: %v", name, err)
+			}
+		}
+		return nil
+	case Array:
+		reflected := reflect.ValueOf(x)
+		if x == nil || reflected.Kind() != reflect.Slice {
+			return fmt.Errorf("expected a slice, got %T", x)
+		}
+		if reflected.Len() > v.field.Capacity() {
+			return fmt.Errorf("%d items exceeds capacity %d", reflected.Len(), v.field.Capacity())
+		}
+		v.items = nil
+		for index := 0; index < reflected.Len(); index++ {
+			item, _ := v.Append()
+			if err := item.SetInterface(reflected.Index(index).Interface()); err != nil {
+				return fmt.Errorf("[%d]: %v", index, err)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("can not set %v", v.kind)
+}
+
+func (v *Value) setNumber(x interface{}) error {
+	reflected := reflect.ValueOf(x)
+	switch reflected.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.SetInt(reflected.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.SetUint(reflected.Uint())
+	case reflect.Float32, reflect.Float64:
+		f := reflected.Float()
+		if v.kind == Float {
+			return v.SetFloat(f)
+		}
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return fmt.Errorf("%v is not an integer", f)
+		}
+		return v.SetInt(int64(f))
+	}
+	return fmt.Errorf("expected a number, got %T", x)
+}
+
+func (v *Value) setNumberText(text string) error {
+	if v.kind == Float {
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return err
+		}
+		return v.SetFloat(f)
+	}
+	if u, err := strconv.ParseUint(text, 10, 64); err == nil {
+		return v.SetUint(u)
+	}
+	i, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return fmt.Errorf("'%v' is not an integer", text)
+	}
+	return v.SetInt(i)
+}
+
+// FromInterface creates a value of the type from the representation returned by Interface.
+func FromInterface(root *definition.Root, typeName string, x interface{}) (*Value, error) {
+	v, err := Zero(root, typeName)
+	if err != nil {
+		return nil, err
+	}
+	return v, v.SetInterface(x)
+}
@@ -0,0 +1,95 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package value
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Validate checks the value against the definition: 'min' and 'max' meta data of the fields,
+// enum values that are not constants and array capacities.
+func (v *Value) Validate() error {
+	switch v.kind {
+	case Struct:
+		for index, member := range v.members {
+			if err := member.Validate(); err != nil {
+				return fmt.Errorf("%v: %v", v.fields[index].Name(), err)
+			}
+		}
+	case Array:
+		if len(v.items) > v.field.Capacity() {
+			return fmt.Errorf("%d items exceeds capacity %d", len(v.items), v.field.Capacity())
+		}
+		for index, item := range v.items {
+			if err := item.Validate(); err != nil {
+				return fmt.Errorf("[%d]: %v", index, err)
+			}
+		}
+	case Enum:
+		if v.EnumConstant() == nil {
+			return fmt.Errorf("%d is not a value in enum '%v'", v.i, v.enum.Name())
+		}
+	case Int, Uint, Float:
+		return v.validateRange()
+	}
+	return nil
+}
+
+func (v *Value) number() float64 {
+	switch v.kind {
+	case Int:
+		return float64(v.i)
+	case Uint:
+		return float64(v.u)
+	}
+	return v.f
+}
+
+func (v *Value) validateRange() error {
+	if v.field == nil {
+		return nil
+	}
+	meta := v.field.MetaData()
+	for _, name := range []string{"min", "max"} {
+		text := meta.Field(name)
+		if text == "" {
+			continue
+		}
+		limit, limitErr := strconv.ParseFloat(text, 64)
+		if limitErr != nil {
+			return fmt.Errorf("illegal %v '%v'", name, text)
+		}
+		if name == "min" && v.number() < limit {
+			return fmt.Errorf("%v is less than min %v", v, text)
+		}
+		if name == "max" && v.number() > limit {
+			return fmt.Errorf("%v is greater than max %v", v, text)
+		}
+	}
+	return nil
+}
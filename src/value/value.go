@@ -0,0 +1,441 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package value
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/piot/scrawl-go/src/definition"
+)
+
+// maxDepth stops user types that contain themselves from recursing forever.
+const maxDepth = 32
+
+type Kind uint8
+
+const (
+	Invalid Kind = iota
+	Bool
+	Int
+	Uint
+	Float
+	String
+	Enum
+	Struct
+	Array
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Bool:
+		return "bool"
+	case Int:
+		return "int"
+	case Uint:
+		return "uint"
+	case Float:
+		return "float"
+	case String:
+		return "string"
+	case Enum:
+		return "enum"
+	case Struct:
+		return "struct"
+	case Array:
+		return "array"
+	}
+	return "invalid"
+}
+
+// Value holds a value of any type in a definition. Struct values are user types, components,
+// events, commands and buffers. The getters panic if the value is of the wrong kind, the setters
+// return an error.
+type Value struct {
+	root      *definition.Root
+	field     *definition.Field
+	typeName  string
+	kind      Kind
+	primitive definition.PrimitiveType
+	enum      *definition.Enum
+	fields    []*definition.Field
+	b         bool
+	i         int64
+	u         uint64
+	f         float64
+	s         string
+	members   []*Value
+	items     []*Value
+}
+
+// Zero returns the zero value of a primitive, enum, user type or component. The zero value of an enum
+// is the constant with value 0, or the first constant if there is none.
+func Zero(root *definition.Root, typeName string) (*Value, error) {
+	return zeroSingle(root, nil, typeName, 0)
+}
+
+// NewStruct returns a struct value with the zero value for every field. Use it for events, commands
+// and buffers, e.g. NewStruct(root, event.Fields()).
+func NewStruct(root *definition.Root, fields []*definition.Field) (*Value, error) {
+	return zeroStruct(root, nil, "", fields, 0)
+}
+
+// ZeroField returns the zero value for a field. Arrays are empty.
+func ZeroField(root *definition.Root, field *definition.Field) (*Value, error) {
+	return zeroField(root, field, 0)
+}
+
+func zeroField(root *definition.Root, field *definition.Field, depth int) (*Value, error) {
+	if field.IsArray() {
+		return &Value{root: root, field: field, typeName: field.FieldType(), kind: Array}, nil
+	}
+	return zeroSingle(root, field, field.FieldType(), depth)
+}
+
+func zeroStruct(root *definition.Root, field *definition.Field, typeName string, fields []*definition.Field,
+	depth int) (*Value, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("type '%v' is nested too deep", typeName)
+	}
+	v := &Value{root: root, field: field, typeName: typeName, kind: Struct, fields: fields}
+	for _, member := range fields {
+		memberValue, memberErr := zeroField(root, member, depth+1)
+		if memberErr != nil {
+			return nil, memberErr
+		}
+		v.members = append(v.members, memberValue)
+	}
+	return v, nil
+}
+
+func zeroSingle(root *definition.Root, field *definition.Field, typeName string, depth int) (*Value, error) {
+	resolved := root.ResolveFieldType(typeName)
+	switch resolved.Variant() {
+	case definition.FieldTypePrimitive:
+		v := &Value{root: root, field: field, typeName: typeName, primitive: resolved.Primitive()}
+		primitive := resolved.Primitive()
+		switch {
+		case primitive == definition.PrimitiveBool:
+			v.kind = Bool
+		case primitive == definition.PrimitiveString:
+			v.kind = String
+		case primitive.IsFloat():
+			v.kind = Float
+		case primitive.IsSigned():
+			v.kind = Int
+		default:
+			v.kind = Uint
+		}
+		return v, nil
+	case definition.FieldTypeEnum:
+		enum := resolved.Enum()
+		v := &Value{root: root, field: field, typeName: typeName, kind: Enum, enum: enum, primitive: enum.StorageType()}
+		if enum.FindConstantByValue(0) == nil && len(enum.Constants()) > 0 {
+			v.i = int64(enum.Constants()[0].Value())
+		}
+		return v, nil
+	case definition.FieldTypeUserType:
+		return zeroStruct(root, field, typeName, resolved.UserType().Fields(), depth)
+	case definition.FieldTypeComponent:
+		return zeroStruct(root, field, typeName, resolved.ComponentDataType().Fields(), depth)
+	}
+	return nil, fmt.Errorf("unknown type '%v'", typeName)
+}
+
+func (v *Value) Kind() Kind {
+	return v.kind
+}
+
+// TypeName is the name of the type, or the element type for arrays. Empty for NewStruct values.
+func (v *Value) TypeName() string {
+	return v.typeName
+}
+
+// Field is the definition of the field that the value is for, or nil for top level values.
+func (v *Value) Field() *definition.Field {
+	return v.field
+}
+
+// Primitive is the primitive type for Bool, Int, Uint, Float and String values, and the storage type for enums.
+func (v *Value) Primitive() definition.PrimitiveType {
+	return v.primitive
+}
+
+func (v *Value) mustBe(kinds ...Kind) {
+	for _, kind := range kinds {
+		if v.kind == kind {
+			return
+		}
+	}
+	panic(fmt.Sprintf("value is %v, not %v", v.kind, kinds))
+}
+
+func (v *Value) Bool() bool {
+	v.mustBe(Bool)
+	return v.b
+}
+
+// Int returns Int values and the value of enums.
+func (v *Value) Int() int64 {
+	v.mustBe(Int, Enum)
+	return v.i
+}
+
+func (v *Value) Uint() uint64 {
+	v.mustBe(Uint)
+	return v.u
+}
+
+func (v *Value) Float() float64 {
+	v.mustBe(Float)
+	return v.f
+}
+
+// String returns the text of String values, and a description of other values.
+func (v *Value) String() string {
+	if v.kind == String {
+		return v.s
+	}
+	if v.kind == Enum {
+		constant := v.EnumConstant()
+		if constant != nil {
+			return constant.Name()
+		}
+	}
+	return fmt.Sprintf("%v", v.Interface())
+}
+
+func (v *Value) Enum() *definition.Enum {
+	v.mustBe(Enum)
+	return v.enum
+}
+
+// EnumConstant returns the constant of an enum value, or nil if the value is not a constant.
+func (v *Value) EnumConstant() *definition.EnumConstant {
+	v.mustBe(Enum)
+	return v.enum.FindConstantByValue(int(v.i))
+}
+
+func (v *Value) SetBool(b bool) error {
+	if v.kind != Bool {
+		return fmt.Errorf("can not set bool on %v", v.kind)
+	}
+	v.b = b
+	return nil
+}
+
+func fitsSigned(primitive definition.PrimitiveType, i int64) bool {
+	bits := uint(primitive.BitSize())
+	return bits >= 64 || (i >= -(int64(1)<<(bits-1)) && i < int64(1)<<(bits-1))
+}
+
+func fitsUnsigned(primitive definition.PrimitiveType, u uint64) bool {
+	bits := uint(primitive.BitSize())
+	return bits >= 64 || u < uint64(1)<<bits
+}
+
+// SetInt sets Int, Uint, Float and enum values. The value must fit in the type.
+func (v *Value) SetInt(i int64) error {
+	switch v.kind {
+	case Int, Enum:
+		if !fitsSigned(v.primitive, i) && !(v.kind == Enum && i >= 0 && fitsUnsigned(v.primitive, uint64(i))) {
+			return fmt.Errorf("%d does not fit in %v", i, v.primitive)
+		}
+		v.i = i
+		return nil
+	case Uint:
+		if i < 0 {
+			return fmt.Errorf("%d is negative", i)
+		}
+		return v.SetUint(uint64(i))
+	case Float:
+		return v.SetFloat(float64(i))
+	}
+	return fmt.Errorf("can not set int on %v", v.kind)
+}
+
+// SetUint sets Uint, Int, Float and enum values. The value must fit in the type.
+func (v *Value) SetUint(u uint64) error {
+	switch v.kind {
+	case Uint:
+		if !fitsUnsigned(v.primitive, u) {
+			return fmt.Errorf("%d does not fit in %v", u, v.primitive)
+		}
+		v.u = u
+		return nil
+	case Int, Enum, Float:
+		if u > math.MaxInt64 {
+			return fmt.Errorf("%d does not fit in %v", u, v.primitive)
+		}
+		return v.SetInt(int64(u))
+	}
+	return fmt.Errorf("can not set uint on %v", v.kind)
+}
+
+// SetFloat sets Float values. Values for float32 are rounded to float32.
+func (v *Value) SetFloat(f float64) error {
+	if v.kind != Float {
+		return fmt.Errorf("can not set float on %v", v.kind)
+	}
+	if v.primitive == definition.PrimitiveFloat32 {
+		f = float64(float32(f))
+	}
+	v.f = f
+	return nil
+}
+
+func (v *Value) SetString(s string) error {
+	if v.kind != String {
+		return fmt.Errorf("can not set string on %v", v.kind)
+	}
+	v.s = s
+	return nil
+}
+
+// SetEnum sets an enum value to the constant with the name.
+func (v *Value) SetEnum(name string) error {
+	if v.kind != Enum {
+		return fmt.Errorf("can not set enum on %v", v.kind)
+	}
+	constant := v.enum.FindConstant(name)
+	if constant == nil {
+		return fmt.Errorf("'%v' is not a constant in enum '%v'", name, v.enum.Name())
+	}
+	v.i = int64(constant.Value())
+	return nil
+}
+
+// Fields returns the field definitions of a struct value.
+func (v *Value) Fields() []*definition.Field {
+	v.mustBe(Struct)
+	return v.fields
+}
+
+func (v *Value) NumField() int {
+	v.mustBe(Struct)
+	return len(v.members)
+}
+
+// FieldByName returns the value of a field, or nil if there is no field with that name.
+func (v *Value) FieldByName(name string) *Value {
+	v.mustBe(Struct)
+	for index, field := range v.fields {
+		if field.Name() == name {
+			return v.members[index]
+		}
+	}
+	return nil
+}
+
+// FieldByIndex returns the value of the field with the definition.Field Index(), or nil if out of range.
+func (v *Value) FieldByIndex(index int) *Value {
+	v.mustBe(Struct)
+	if index < 0 || index >= len(v.members) {
+		return nil
+	}
+	return v.members[index]
+}
+
+// Len is the number of items in an array.
+func (v *Value) Len() int {
+	v.mustBe(Array)
+	return len(v.items)
+}
+
+// Capacity is the maximum number of items in an array.
+func (v *Value) Capacity() int {
+	v.mustBe(Array)
+	return v.field.Capacity()
+}
+
+func (v *Value) Index(index int) *Value {
+	v.mustBe(Array)
+	return v.items[index]
+}
+
+// Items returns the items of an array, for iteration.
+func (v *Value) Items() []*Value {
+	v.mustBe(Array)
+	return v.items
+}
+
+// Append adds a zero item to an array and returns it.
+func (v *Value) Append() (*Value, error) {
+	if v.kind != Array {
+		return nil, fmt.Errorf("can not append to %v", v.kind)
+	}
+	if len(v.items) >= v.field.Capacity() {
+		return nil, fmt.Errorf("array is full, capacity is %d", v.field.Capacity())
+	}
+	item, itemErr := zeroSingle(v.root, v.field, v.typeName, 0)
+	if itemErr != nil {
+		return nil, itemErr
+	}
+	v.items = append(v.items, item)
+	return item, nil
+}
+
+func (v *Value) Remove(index int) error {
+	if v.kind != Array {
+		return fmt.Errorf("can not remove from %v", v.kind)
+	}
+	if index < 0 || index >= len(v.items) {
+		return fmt.Errorf("index %d is out of range", index)
+	}
+	v.items = append(v.items[:index], v.items[index+1:]...)
+	return nil
+}
+
+// Copy returns a deep copy.
+func (v *Value) Copy() *Value {
+	copied := *v
+	copied.members = nil
+	for _, member := range v.members {
+		copied.members = append(copied.members, member.Copy())
+	}
+	copied.items = nil
+	for _, item := range v.items {
+		copied.items = append(copied.items, item.Copy())
+	}
+	return &copied
+}
+
+// Set copies other into the value. Both must have the same kind and type.
+func (v *Value) Set(other *Value) error {
+	if v.kind != other.kind || v.typeName != other.typeName || v.primitive != other.primitive ||
+		len(v.fields) != len(other.fields) {
+		return fmt.Errorf("can not set %v '%v' to %v '%v'", v.kind, v.typeName, other.kind, other.typeName)
+	}
+	if v.kind == Array && len(other.items) > v.field.Capacity() {
+		return fmt.Errorf("%d items exceeds capacity %d", len(other.items), v.field.Capacity())
+	}
+	copied := other.Copy()
+	v.b, v.i, v.u, v.f, v.s = copied.b, copied.i, copied.u, copied.f, copied.s
+	v.members = copied.members
+	v.items = copied.items
+	return nil
+}
@@ -0,0 +1,204 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package value
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/piot/scrawl-go/src/definition"
+	"github.com/piot/scrawl-go/src/parser"
+	"github.com/piot/scrawl-go/src/scrawl"
+	"github.com/piot/scrawl-go/src/serialize"
+)
+
+const testProtocol = `
+enum Team
+  Red 1
+  Blue 2
+
+type Strength
+  big int32 [min "0" max "1000"]
+  small uint8
+
+component Turret
+  team Team
+  angle float32
+  strength Strength
+  history int16 [capacity "2"]
+  name string
+  active bool
+
+event Jump
+  height int16
+`
+
+func setupRoot(t *testing.T) *definition.Root {
+	root, err := scrawl.ParseStringWithOptions(testProtocol, parser.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestZero(t *testing.T) {
+	root := setupRoot(t)
+	turret, err := Zero(root, "Turret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if turret.Kind() != Struct || turret.NumField() != 6 {
+		t.Fatalf("wrong struct %v", turret)
+	}
+
+	team := turret.FieldByName("team")
+	if team.Kind() != Enum || team.EnumConstant().Name() != "Red" {
+		t.Errorf("enum should default to the first constant %v", team)
+	}
+
+	if turret.FieldByName("history").Len() != 0 || turret.FieldByName("name").String() != "" {
+		t.Errorf("wrong zero values %v", turret)
+	}
+
+	if _, unknownErr := Zero(root, "Missing"); unknownErr == nil {
+		t.Errorf("unknown type should be reported")
+	}
+}
+
+func TestGetAndSet(t *testing.T) {
+	root := setupRoot(t)
+	turret, _ := Zero(root, "Turret")
+
+	strength := turret.FieldByIndex(2)
+	if strength.TypeName() != "Strength" {
+		t.Fatalf("wrong field %v", strength)
+	}
+	if err := strength.FieldByName("big").SetInt(500); err != nil {
+		t.Fatal(err)
+	}
+	if err := strength.FieldByName("small").SetInt(256); err == nil {
+		t.Errorf("value that does not fit should be reported")
+	}
+	if err := turret.FieldByName("team").SetEnum("Blue"); err != nil {
+		t.Fatal(err)
+	}
+	if err := turret.FieldByName("angle").SetFloat(0.1); err != nil {
+		t.Fatal(err)
+	}
+	if turret.FieldByName("angle").Float() != float64(float32(0.1)) {
+		t.Errorf("float32 should be rounded")
+	}
+	if err := turret.FieldByName("active").SetInt(1); err == nil {
+		t.Errorf("int on bool should be reported")
+	}
+
+	history := turret.FieldByName("history")
+	for index := 0; index < 2; index++ {
+		item, appendErr := history.Append()
+		if appendErr != nil {
+			t.Fatal(appendErr)
+		}
+		item.SetInt(int64(-index))
+	}
+	if _, fullErr := history.Append(); fullErr == nil {
+		t.Errorf("appending to a full array should be reported")
+	}
+
+	sum := int64(0)
+	for _, item := range history.Items() {
+		sum += item.Int()
+	}
+	if sum != -1 || history.Capacity() != 2 {
+		t.Errorf("wrong array %v", history)
+	}
+
+	copied := turret.Copy()
+	copied.FieldByName("strength").FieldByName("big").SetInt(1)
+	if strength.FieldByName("big").Int() != 500 {
+		t.Errorf("copy should not share values")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	root := setupRoot(t)
+	turret, _ := Zero(root, "Turret")
+
+	if err := turret.Validate(); err != nil {
+		t.Errorf("zero value should be valid %v", err)
+	}
+
+	turret.FieldByName("strength").FieldByName("big").SetInt(1001)
+	if err := turret.Validate(); err == nil {
+		t.Errorf("value above max should be reported")
+	}
+	turret.FieldByName("strength").FieldByName("big").SetInt(1000)
+
+	turret.FieldByName("team").SetInt(3)
+	if err := turret.Validate(); err == nil {
+		t.Errorf("enum value that is not a constant should be reported")
+	}
+}
+
+func TestInterfaceRoundTrip(t *testing.T) {
+	root := setupRoot(t)
+	turret, _ := Zero(root, "Turret")
+	turret.FieldByName("team").SetEnum("Blue")
+	turret.FieldByName("name").SetString("north")
+	item, _ := turret.FieldByName("history").Append()
+	item.SetInt(-7)
+
+	component := root.FindComponentDataType("Turret")
+	codec := serialize.NewCodec(root)
+	octets, err := codec.EncodeComponent(component, turret.Interface().(map[string]interface{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, decodeErr := codec.DecodeComponent(component, octets)
+	if decodeErr != nil {
+		t.Fatal(decodeErr)
+	}
+
+	loaded, loadErr := FromInterface(root, "Turret", decoded)
+	if loadErr != nil {
+		t.Fatal(loadErr)
+	}
+	if !reflect.DeepEqual(loaded.Interface(), turret.Interface()) {
+		t.Errorf("round trip differs %v %v", loaded, turret)
+	}
+
+	jump, jumpErr := NewStruct(root, root.Events()[0].Fields())
+	if jumpErr != nil {
+		t.Fatal(jumpErr)
+	}
+	if err := jump.SetInterface(map[string]interface{}{"height": 2.0}); err != nil {
+		t.Fatal(err)
+	}
+	if jump.FieldByName("height").Int() != 2 {
+		t.Errorf("wrong event %v", jump)
+	}
+}
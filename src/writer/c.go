@@ -0,0 +1,294 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package writer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/piot/scrawl-go/src/definition"
+)
+
+var cPrimitiveTypes = map[definition.PrimitiveType]string{
+	definition.PrimitiveBool:    "bool",
+	definition.PrimitiveInt8:    "int8_t",
+	definition.PrimitiveUint8:   "uint8_t",
+	definition.PrimitiveInt16:   "int16_t",
+	definition.PrimitiveUint16:  "uint16_t",
+	definition.PrimitiveInt32:   "int32_t",
+	definition.PrimitiveUint32:  "uint32_t",
+	definition.PrimitiveInt64:   "int64_t",
+	definition.PrimitiveUint64:  "uint64_t",
+	definition.PrimitiveFloat32: "float",
+	definition.PrimitiveFloat64: "double",
+	definition.PrimitiveString:  "const char*",
+}
+
+type cWriter struct {
+	root        *definition.Root
+	prefix      string
+	definitions []string
+}
+
+func newCWriter(root *definition.Root) *cWriter {
+	name := root.Name()
+	if name == "" {
+		name = "Protocol"
+	}
+	return &cWriter{root: root, prefix: PascalCase(name)}
+}
+
+func (c *cWriter) typeName(name string) string {
+	return c.prefix + PascalCase(name)
+}
+
+func (c *cWriter) constantName(parts ...string) string {
+	words := []string{ScreamingSnakeCase(c.prefix)}
+	for _, part := range parts {
+		words = append(words, ScreamingSnakeCase(part))
+	}
+	return strings.Join(words, "_")
+}
+
+func (c *cWriter) fieldType(fieldType string) string {
+	resolved := c.root.ResolveFieldType(fieldType)
+	switch resolved.Variant() {
+	case definition.FieldTypePrimitive:
+		return cPrimitiveTypes[resolved.Primitive()]
+	case definition.FieldTypeEnum:
+		return cPrimitiveTypes[resolved.Enum().StorageType()]
+	case definition.FieldTypeUnknown:
+		return fieldType
+	}
+	return c.typeName(fieldType)
+}
+
+// countType is the smallest unsigned type that holds the number of items in an array.
+func countType(capacity int) string {
+	switch {
+	case capacity <= 0xff:
+		return "uint8_t"
+	case capacity <= 0xffff:
+		return "uint16_t"
+	}
+	return "uint32_t"
+}
+
+func (c *cWriter) fields(o *output, fields []*definition.Field) {
+	for _, field := range fields {
+		resolved := c.root.ResolveFieldType(field.FieldType())
+		if field.IsArray() {
+			o.line("%s %s_count;", countType(field.Capacity()), field.Name())
+			if resolved.Variant() == definition.FieldTypeEnum {
+				o.line("%s %s[%d]; /* %s */", c.fieldType(field.FieldType()), field.Name(), field.Capacity(),
+					c.typeName(field.FieldType()))
+				continue
+			}
+			o.line("%s %s[%d];", c.fieldType(field.FieldType()), field.Name(), field.Capacity())
+			continue
+		}
+		if resolved.Variant() == definition.FieldTypeEnum {
+			o.line("%s %s; /* %s */", c.fieldType(field.FieldType()), field.Name(), c.typeName(field.FieldType()))
+			continue
+		}
+		o.line("%s %s;", c.fieldType(field.FieldType()), field.Name())
+	}
+}
+
+func (c *cWriter) structure(o *output, name string, fields []*definition.Field) {
+	o.blank()
+	o.line("typedef struct %s {", c.typeName(name))
+	o.in()
+	if len(fields) == 0 {
+		o.line("uint8_t unused;")
+	}
+	c.fields(o, fields)
+	o.out()
+	o.line("} %s;", c.typeName(name))
+}
+
+func (c *cWriter) schema(o *output) {
+	hash := c.root.Hash()
+	switch len(hash.Octets()) {
+	case 4:
+		o.line("#define %s 0x%08xu", c.constantName("SchemaHash"), hash.Uint32())
+	case 8:
+		o.line("#define %s 0x%016xull", c.constantName("SchemaHash"), hash.Uint64())
+	}
+	o.line("#define %s %q", c.constantName("SchemaHashString"), hash.String())
+	o.line("#define %s %q", c.constantName("SchemaHashAlgorithm"), hash.Algorithm().String())
+	o.line("#define %s %q", c.constantName("SchemaName"), c.root.Name())
+	o.line("#define %s %q", c.constantName("SchemaNamespace"), c.root.Namespace())
+}
+
+func (c *cWriter) enums(o *output) {
+	for _, enum := range c.root.Enums() {
+		o.blank()
+		o.line("typedef enum %s {", c.typeName(enum.Name()))
+		o.in()
+		for _, constant := range enum.Constants() {
+			o.line("%s = %d,", c.constantName(enum.Name(), constant.Name()), constant.Value())
+		}
+		o.out()
+		o.line("} %s;", c.typeName(enum.Name()))
+	}
+}
+
+// nameTable declares a table of names in the header. The definition is written to the
+// implementation section, so that only one translation unit holds the table.
+func (c *cWriter) nameTable(o *output, name string, names []string) {
+	quoted := make([]string, len(names))
+	for i, item := range names {
+		quoted[i] = fmt.Sprintf("%q", item)
+	}
+	o.line("extern const char* const %s[%d];", name, len(names))
+	c.definitions = append(c.definitions, fmt.Sprintf("const char* const %s[%d] = {%s};", name, len(names),
+		strings.Join(quoted, ", ")))
+}
+
+func (c *cWriter) typeIndexTable(o *output, kind string, names []string, indices []int) {
+	o.blank()
+	for i, name := range names {
+		o.line("#define %s %d", c.constantName(kind+"TypeIndex", name), indices[i])
+	}
+	o.line("#define %s %d", c.constantName(kind+"TypeCount"), len(names))
+	if len(names) == 0 {
+		return
+	}
+	c.nameTable(o, fmt.Sprintf("%s_%s_type_names", SnakeCase(c.prefix), SnakeCase(kind)), names)
+}
+
+func (c *cWriter) typeIndices(o *output) {
+	var names []string
+	var indices []int
+	for _, component := range c.root.ComponentDataTypes() {
+		names = append(names, component.Name())
+		indices = append(indices, int(component.Index()))
+	}
+	c.typeIndexTable(o, "Component", names, indices)
+
+	names, indices = nil, nil
+	for _, event := range c.root.Events() {
+		names = append(names, event.Name())
+		indices = append(indices, int(event.TypeIndex()))
+	}
+	c.typeIndexTable(o, "Event", names, indices)
+
+	names, indices = nil, nil
+	for _, command := range c.root.Commands() {
+		names = append(names, command.Name())
+		indices = append(indices, int(command.TypeIndex()))
+	}
+	c.typeIndexTable(o, "Command", names, indices)
+
+	names, indices = nil, nil
+	for _, buffer := range c.root.Buffers() {
+		names = append(names, buffer.Name())
+		indices = append(indices, int(buffer.TypeIndex()))
+	}
+	c.typeIndexTable(o, "Buffer", names, indices)
+
+	names, indices = nil, nil
+	for _, archetype := range c.root.Archetypes() {
+		names = append(names, archetype.Name())
+		indices = append(indices, int(archetype.Index().Value()))
+	}
+	c.typeIndexTable(o, "Archetype", names, indices)
+}
+
+func (c *cWriter) archetypes(o *output) {
+	for _, archetype := range c.root.Archetypes() {
+		o.blank()
+		o.line("#define %s %d", c.constantName(archetype.Name(), "ID"), archetype.ID().Value())
+		o.line("#define %s %d", c.constantName(archetype.Name(), "LodCount"), len(archetype.Lods()))
+		for _, lod := range archetype.Lods() {
+			names := make([]string, len(lod.Items()))
+			for i, item := range lod.Items() {
+				names[i] = item.Name()
+			}
+			c.nameTable(o, fmt.Sprintf("%s_%s_lod%d_items", SnakeCase(c.prefix), SnakeCase(archetype.Name()), lod.Level()), names)
+		}
+	}
+}
+
+// WriteC writes all definitions in root as a C99 header. Extended components are flattened,
+// since C has no inheritance. Enum fields are stored with the smallest fixed-width type that fits.
+// The name tables are only declared. Define <PREFIX>_PROTOCOL_IMPLEMENTATION in one source file
+// before including the header to define them.
+func WriteC(writer io.Writer, root *definition.Root) error {
+	c := newCWriter(root)
+	guard := c.constantName("Protocol", "H")
+	o := newOutput(writer, "    ")
+	o.line("/* Generated by scrawl-gen. Do not edit. */")
+	o.line("#ifndef %s", guard)
+	o.line("#define %s", guard)
+	o.blank()
+	o.line("#include <stdbool.h>")
+	o.line("#include <stdint.h>")
+	o.blank()
+	c.schema(o)
+	c.enums(o)
+	c.typeIndices(o)
+
+	for _, declaration := range structDeclarations(root) {
+		if declaration.userType != nil {
+			c.structure(o, declaration.userType.TypeName(), declaration.userType.Fields())
+		} else {
+			c.structure(o, declaration.component.Name(), declaration.component.Fields())
+		}
+	}
+	for _, event := range root.Events() {
+		c.structure(o, event.Name(), event.Fields())
+	}
+	for _, command := range root.Commands() {
+		c.structure(o, command.Name(), command.Fields())
+	}
+	for _, buffer := range root.Buffers() {
+		c.structure(o, buffer.Name(), buffer.Fields())
+	}
+
+	c.archetypes(o)
+
+	if len(c.definitions) > 0 {
+		o.blank()
+		o.line("#ifdef %s", c.constantName("Protocol", "Implementation"))
+		for _, line := range c.definitions {
+			o.line("%s", line)
+		}
+		o.line("#endif")
+	}
+
+	o.blank()
+	o.line("#endif")
+
+	if o.err != nil {
+		return fmt.Errorf("could not write C header: %v", o.err)
+	}
+
+	return nil
+}
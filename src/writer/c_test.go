@@ -0,0 +1,76 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package writer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestC(t *testing.T) {
+	root := setupRoot(t, "generate")
+	builder := &strings.Builder{}
+	err := WriteC(builder, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkGolden(t, "generate.c", builder.String())
+}
+
+func TestCpp(t *testing.T) {
+	root := setupRoot(t, "generate")
+	builder := &strings.Builder{}
+	err := WriteCpp(builder, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkGolden(t, "generate.cpp", builder.String())
+}
+
+func TestCDeclarationOrder(t *testing.T) {
+	root := setupRoot(t, "order")
+	builder := &strings.Builder{}
+	err := WriteC(builder, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkGolden(t, "order.c", builder.String())
+}
+
+func TestCppDeclarationOrder(t *testing.T) {
+	root := setupRoot(t, "order")
+	builder := &strings.Builder{}
+	err := WriteCpp(builder, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkGolden(t, "order.cpp", builder.String())
+}
@@ -0,0 +1,233 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package writer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/piot/scrawl-go/src/definition"
+)
+
+var cppPrimitiveTypes = map[definition.PrimitiveType]string{
+	definition.PrimitiveBool:    "bool",
+	definition.PrimitiveInt8:    "std::int8_t",
+	definition.PrimitiveUint8:   "std::uint8_t",
+	definition.PrimitiveInt16:   "std::int16_t",
+	definition.PrimitiveUint16:  "std::uint16_t",
+	definition.PrimitiveInt32:   "std::int32_t",
+	definition.PrimitiveUint32:  "std::uint32_t",
+	definition.PrimitiveInt64:   "std::int64_t",
+	definition.PrimitiveUint64:  "std::uint64_t",
+	definition.PrimitiveFloat32: "float",
+	definition.PrimitiveFloat64: "double",
+	definition.PrimitiveString:  "std::string",
+}
+
+func cppType(root *definition.Root, fieldType string) string {
+	resolved := root.ResolveFieldType(fieldType)
+	if resolved.Variant() == definition.FieldTypePrimitive {
+		return cppPrimitiveTypes[resolved.Primitive()]
+	}
+	return fieldType
+}
+
+func cppFields(o *output, root *definition.Root, fields []*definition.Field) {
+	for _, field := range fields {
+		if field.IsArray() {
+			o.line("std::vector<%s> %s{};", cppType(root, field.FieldType()), field.Name())
+			continue
+		}
+		o.line("%s %s{};", cppType(root, field.FieldType()), field.Name())
+	}
+}
+
+func cppStruct(o *output, root *definition.Root, name string, base string, typeIndex int, fields []*definition.Field) {
+	o.blank()
+	if base != "" {
+		o.line("struct %s : %s {", name, base)
+	} else {
+		o.line("struct %s {", name)
+	}
+	o.in()
+	if typeIndex >= 0 {
+		o.line("static constexpr std::uint8_t TypeIndex = %d;", typeIndex)
+	}
+	cppFields(o, root, fields)
+	o.out()
+	o.line("};")
+}
+
+func cppEnumClass(o *output, name string, storage definition.PrimitiveType, names []string, values []int) {
+	o.blank()
+	o.line("enum class %s : %s {", name, cppPrimitiveTypes[storage])
+	o.in()
+	for i, constantName := range names {
+		o.line("%s = %d,", constantName, values[i])
+	}
+	o.out()
+	o.line("};")
+}
+
+func cppSchema(o *output, root *definition.Root) {
+	hash := root.Hash()
+	switch len(hash.Octets()) {
+	case 4:
+		o.line("constexpr std::uint32_t SchemaHash = 0x%08xu;", hash.Uint32())
+	case 8:
+		o.line("constexpr std::uint64_t SchemaHash = 0x%016xull;", hash.Uint64())
+	}
+	o.line("constexpr const char* SchemaHashString = %q;", hash.String())
+	o.line("constexpr const char* SchemaHashAlgorithm = %q;", hash.Algorithm().String())
+	o.line("constexpr const char* SchemaName = %q;", root.Name())
+}
+
+func cppTypeIndices(o *output, root *definition.Root) {
+	var names []string
+	var indices []int
+	for _, component := range root.ComponentDataTypes() {
+		names = append(names, component.Name())
+		indices = append(indices, int(component.Index()))
+	}
+	cppEnumClass(o, "ComponentTypeIndex", definition.PrimitiveUint8, names, indices)
+
+	names, indices = nil, nil
+	for _, event := range root.Events() {
+		names = append(names, event.Name())
+		indices = append(indices, int(event.TypeIndex()))
+	}
+	cppEnumClass(o, "EventTypeIndex", definition.PrimitiveUint8, names, indices)
+
+	names, indices = nil, nil
+	for _, command := range root.Commands() {
+		names = append(names, command.Name())
+		indices = append(indices, int(command.TypeIndex()))
+	}
+	cppEnumClass(o, "CommandTypeIndex", definition.PrimitiveUint8, names, indices)
+
+	names, indices = nil, nil
+	for _, buffer := range root.Buffers() {
+		names = append(names, buffer.Name())
+		indices = append(indices, int(buffer.TypeIndex()))
+	}
+	cppEnumClass(o, "BufferTypeIndex", definition.PrimitiveUint8, names, indices)
+
+	names, indices = nil, nil
+	for _, archetype := range root.Archetypes() {
+		names = append(names, archetype.Name())
+		indices = append(indices, int(archetype.Index().Value()))
+	}
+	cppEnumClass(o, "ArchetypeTypeIndex", definition.PrimitiveUint8, names, indices)
+}
+
+func cppArchetypes(o *output, root *definition.Root) {
+	for _, archetype := range root.Archetypes() {
+		o.blank()
+		o.line("struct %sArchetype {", archetype.Name())
+		o.in()
+		o.line("static constexpr std::uint8_t TypeIndex = %d;", archetype.Index().Value())
+		o.line("static constexpr std::uint16_t ID = %d;", archetype.ID().Value())
+		for _, lod := range archetype.Lods() {
+			quoted := make([]string, len(lod.Items()))
+			for i, item := range lod.Items() {
+				quoted[i] = fmt.Sprintf("%q", item.Name())
+			}
+			o.line("static constexpr std::array<const char*, %d> Lod%dItems{%s};", len(quoted), lod.Level(), strings.Join(quoted, ", "))
+		}
+		o.out()
+		o.line("};")
+	}
+}
+
+// WriteCpp writes all definitions in root as a C++17 header, in a namespace taken from the root namespace.
+func WriteCpp(writer io.Writer, root *definition.Root) error {
+	o := newOutput(writer, "    ")
+	o.line("// Generated by scrawl-gen. Do not edit.")
+	o.line("#pragma once")
+	o.blank()
+	o.line("#include <array>")
+	o.line("#include <cstdint>")
+	o.line("#include <string>")
+	o.line("#include <vector>")
+	o.blank()
+
+	namespace := strings.Replace(root.Namespace(), ".", "::", -1)
+	if namespace != "" {
+		o.line("namespace %s {", namespace)
+		o.blank()
+	}
+
+	cppSchema(o, root)
+
+	for _, enum := range root.Enums() {
+		var names []string
+		var values []int
+		for _, constant := range enum.Constants() {
+			names = append(names, constant.Name())
+			values = append(values, constant.Value())
+		}
+		cppEnumClass(o, enum.Name(), enum.StorageType(), names, values)
+	}
+
+	cppTypeIndices(o, root)
+
+	for _, declaration := range structDeclarations(root) {
+		if declaration.userType != nil {
+			cppStruct(o, root, declaration.userType.TypeName(), "", -1, declaration.userType.Fields())
+			continue
+		}
+		component := declaration.component
+		base := ""
+		if component.Base() != nil {
+			base = component.Base().Name()
+		}
+		cppStruct(o, root, component.Name(), base, int(component.Index()), component.OwnFields())
+	}
+	for _, event := range root.Events() {
+		cppStruct(o, root, event.Name(), "", int(event.TypeIndex()), event.Fields())
+	}
+	for _, command := range root.Commands() {
+		cppStruct(o, root, command.Name(), "", int(command.TypeIndex()), command.Fields())
+	}
+	for _, buffer := range root.Buffers() {
+		cppStruct(o, root, buffer.Name(), "", int(buffer.TypeIndex()), buffer.Fields())
+	}
+
+	cppArchetypes(o, root)
+
+	if namespace != "" {
+		o.blank()
+		o.line("} // namespace %s", namespace)
+	}
+
+	if o.err != nil {
+		return fmt.Errorf("could not write C++ header: %v", o.err)
+	}
+
+	return nil
+}
@@ -28,25 +28,210 @@ package writer
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/piot/scrawl-go/src/definition"
 )
 
-func WriteCSharp(root *definition.Root) {
+var csharpPrimitiveTypes = map[definition.PrimitiveType]string{
+	definition.PrimitiveBool:    "bool",
+	definition.PrimitiveInt8:    "sbyte",
+	definition.PrimitiveUint8:   "byte",
+	definition.PrimitiveInt16:   "short",
+	definition.PrimitiveUint16:  "ushort",
+	definition.PrimitiveInt32:   "int",
+	definition.PrimitiveUint32:  "uint",
+	definition.PrimitiveInt64:   "long",
+	definition.PrimitiveUint64:  "ulong",
+	definition.PrimitiveFloat32: "float",
+	definition.PrimitiveFloat64: "double",
+	definition.PrimitiveString:  "string",
+}
+
+func csharpType(root *definition.Root, fieldType string) string {
+	resolved := root.ResolveFieldType(fieldType)
+	if resolved.Variant() == definition.FieldTypePrimitive {
+		return csharpPrimitiveTypes[resolved.Primitive()]
+	}
+	return fieldType
+}
+
+func schemaName(root *definition.Root) string {
+	if root.Name() == "" {
+		return "ProtocolSchema"
+	}
+	return PascalCase(root.Name()) + "Schema"
+}
+
+func csharpFields(o *output, root *definition.Root, fields []*definition.Field) {
+	for _, field := range fields {
+		if field.IsArray() {
+			o.line("public %s[] %s;", csharpType(root, field.FieldType()), field.Name())
+			continue
+		}
+		o.line("public %s %s;", csharpType(root, field.FieldType()), field.Name())
+	}
+}
+
+func csharpHash(o *output, hash definition.Hash) {
+	switch len(hash.Octets()) {
+	case 4:
+		o.line("public const uint Hash = 0x%08x;", hash.Uint32())
+	case 8:
+		o.line("public const ulong Hash = 0x%016x;", hash.Uint64())
+	}
+	o.line("public const string HashString = \"%v\";", hash)
+	o.line("public const string HashAlgorithm = \"%v\";", hash.Algorithm())
+}
+
+func csharpSchema(o *output, root *definition.Root) {
+	o.open("public static class %s", schemaName(root))
+	if root.Name() != "" {
+		o.line("public const string Name = \"%v\";", root.Name())
+	}
+	csharpHash(o, root.Hash())
+	o.close("")
+}
+
+func csharpEnums(o *output, root *definition.Root) {
+	for _, enum := range root.Enums() {
+		o.blank()
+		o.open("public enum %s", enum.Name())
+		for _, constant := range enum.Constants() {
+			o.line("%s = %d,", constant.Name(), constant.Value())
+		}
+		o.close("")
+	}
+}
+
+func csharpUserTypes(o *output, root *definition.Root) {
+	for _, userType := range root.UserTypes() {
+		o.blank()
+		o.open("public struct %s", userType.TypeName())
+		csharpFields(o, root, userType.Fields())
+		o.close("")
+	}
+}
+
+func csharpComponents(o *output, root *definition.Root) {
 	for _, component := range root.ComponentDataTypes() {
-		fmt.Printf("public class %s \n{\n", component.Name())
-		for _, field := range component.Fields() {
-			fmt.Printf(" public %s %s;\n", field.FieldType(), field.Name())
+		o.blank()
+		if component.Base() != nil {
+			o.open("public class %s : %s", component.Name(), component.Base().Name())
+			o.line("public new const byte TypeIndex = %d;", component.Index())
+		} else {
+			o.open("public class %s", component.Name())
+			o.line("public const byte TypeIndex = %d;", component.Index())
 		}
+		csharpFields(o, root, component.OwnFields())
+		o.close("")
+	}
+}
 
-		fmt.Printf("}\n")
+func csharpIndexedStruct(o *output, root *definition.Root, name string, typeIndex uint8, fields []*definition.Field) {
+	o.blank()
+	o.open("public struct %s", name)
+	o.line("public const byte TypeIndex = %d;", typeIndex)
+	csharpFields(o, root, fields)
+	o.close("")
+}
+
+func csharpItemType(root *definition.Root, item *definition.EntityArchetypeItem) string {
+	if item.HasComponentReference() {
+		return item.ComponentDataType().Name()
 	}
+	return csharpType(root, item.FieldReference())
+}
 
-	for _, entity := range root.Archetypes() {
-		fmt.Printf("public class %s\n{\n", entity.Name())
-		for _, field := range entity.HighestLevelOfDetail().Items() {
-			fmt.Printf(" public %s %s;\n", field.ComponentDataType().Name(), field.Name())
+func csharpArchetypes(o *output, root *definition.Root) {
+	for _, archetype := range root.Archetypes() {
+		o.blank()
+		o.open("public static class %s", archetype.Name())
+		o.line("public const byte TypeIndex = %d;", archetype.Index().Value())
+		o.line("public const ushort ID = %d;", archetype.ID().Value())
+		for _, lod := range archetype.Lods() {
+			o.blank()
+			o.open("public class Lod%d", lod.Level())
+			o.line("public const int Level = %d;", lod.Level())
+			for _, item := range lod.Items() {
+				o.line("public %s %s;", csharpItemType(root, item), CamelCase(item.Name()))
+			}
+			o.close("")
 		}
-		fmt.Printf("}\n")
+		o.close("")
+	}
+}
+
+func csharpTypeIndexConstants(o *output, root *definition.Root) {
+	o.blank()
+	o.open("public static class ComponentTypeIndex")
+	for _, component := range root.ComponentDataTypes() {
+		o.line("public const byte %s = %d;", component.Name(), component.Index())
+	}
+	o.close("")
+
+	o.blank()
+	o.open("public static class EventTypeIndex")
+	for _, event := range root.Events() {
+		o.line("public const byte %s = %d;", event.Name(), event.TypeIndex())
+	}
+	o.close("")
+
+	o.blank()
+	o.open("public static class CommandTypeIndex")
+	for _, command := range root.Commands() {
+		o.line("public const byte %s = %d;", command.Name(), command.TypeIndex())
+	}
+	o.close("")
+
+	o.blank()
+	o.open("public static class BufferTypeIndex")
+	for _, buffer := range root.Buffers() {
+		o.line("public const byte %s = %d;", buffer.Name(), buffer.TypeIndex())
+	}
+	o.close("")
+
+	o.blank()
+	o.open("public static class ArchetypeTypeIndex")
+	for _, archetype := range root.Archetypes() {
+		o.line("public const byte %s = %d;", archetype.Name(), archetype.Index().Value())
 	}
+	o.close("")
+}
+
+// WriteCSharp writes all definitions in root as C# source.
+func WriteCSharp(writer io.Writer, root *definition.Root) error {
+	o := newOutput(writer, "    ")
+	o.line("// Generated by scrawl. Do not edit.")
+	o.blank()
+
+	if root.Namespace() != "" {
+		o.open("namespace %s", root.Namespace())
+	}
+
+	csharpSchema(o, root)
+	csharpEnums(o, root)
+	csharpUserTypes(o, root)
+	csharpComponents(o, root)
+	for _, event := range root.Events() {
+		csharpIndexedStruct(o, root, event.Name(), uint8(event.TypeIndex()), event.Fields())
+	}
+	for _, command := range root.Commands() {
+		csharpIndexedStruct(o, root, command.Name(), uint8(command.TypeIndex()), command.Fields())
+	}
+	for _, buffer := range root.Buffers() {
+		csharpIndexedStruct(o, root, buffer.Name(), uint8(buffer.TypeIndex()), buffer.Fields())
+	}
+	csharpArchetypes(o, root)
+	csharpTypeIndexConstants(o, root)
+
+	if root.Namespace() != "" {
+		o.close("")
+	}
+
+	if o.err != nil {
+		return fmt.Errorf("could not write C#: %v", o.err)
+	}
+
+	return nil
 }
@@ -0,0 +1,287 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package writer
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+
+	"github.com/piot/scrawl-go/src/definition"
+)
+
+var goPrimitiveTypes = map[definition.PrimitiveType]string{
+	definition.PrimitiveBool:    "bool",
+	definition.PrimitiveInt8:    "int8",
+	definition.PrimitiveUint8:   "uint8",
+	definition.PrimitiveInt16:   "int16",
+	definition.PrimitiveUint16:  "uint16",
+	definition.PrimitiveInt32:   "int32",
+	definition.PrimitiveUint32:  "uint32",
+	definition.PrimitiveInt64:   "int64",
+	definition.PrimitiveUint64:  "uint64",
+	definition.PrimitiveFloat32: "float32",
+	definition.PrimitiveFloat64: "float64",
+	definition.PrimitiveString:  "string",
+}
+
+func goType(root *definition.Root, fieldType string) string {
+	resolved := root.ResolveFieldType(fieldType)
+	if resolved.Variant() == definition.FieldTypePrimitive {
+		return goPrimitiveTypes[resolved.Primitive()]
+	}
+	return PascalCase(fieldType)
+}
+
+func goFields(o *output, root *definition.Root, fields []*definition.Field) {
+	for _, field := range fields {
+		if field.IsArray() {
+			o.line("%s []%s", PascalCase(field.Name()), goType(root, field.FieldType()))
+			continue
+		}
+		o.line("%s %s", PascalCase(field.Name()), goType(root, field.FieldType()))
+	}
+}
+
+func goSchema(o *output, root *definition.Root) {
+	hash := root.Hash()
+	o.line("const (")
+	o.in()
+	o.line("SchemaName = %q", root.Name())
+	o.line("SchemaNamespace = %q", root.Namespace())
+	switch len(hash.Octets()) {
+	case 4:
+		o.line("SchemaHash uint32 = 0x%08x", hash.Uint32())
+	case 8:
+		o.line("SchemaHash uint64 = 0x%016x", hash.Uint64())
+	}
+	o.line("SchemaHashString = %q", hash.String())
+	o.line("SchemaHashAlgorithm = %q", hash.Algorithm().String())
+	o.out()
+	o.line(")")
+}
+
+func goIndexType(o *output, typeName string, names []string, indices []int) {
+	o.blank()
+	o.line("type %s uint8", typeName)
+	if len(names) == 0 {
+		return
+	}
+	o.blank()
+	o.line("const (")
+	o.in()
+	for i, name := range names {
+		o.line("%s%s %s = %d", typeName, PascalCase(name), typeName, indices[i])
+	}
+	o.out()
+	o.line(")")
+}
+
+func goTypeIndices(o *output, root *definition.Root) {
+	var names []string
+	var indices []int
+	for _, component := range root.ComponentDataTypes() {
+		names = append(names, component.Name())
+		indices = append(indices, int(component.Index()))
+	}
+	goIndexType(o, "ComponentTypeIndex", names, indices)
+
+	names, indices = nil, nil
+	for _, event := range root.Events() {
+		names = append(names, event.Name())
+		indices = append(indices, int(event.TypeIndex()))
+	}
+	goIndexType(o, "EventTypeIndex", names, indices)
+
+	names, indices = nil, nil
+	for _, command := range root.Commands() {
+		names = append(names, command.Name())
+		indices = append(indices, int(command.TypeIndex()))
+	}
+	goIndexType(o, "CommandTypeIndex", names, indices)
+
+	names, indices = nil, nil
+	for _, buffer := range root.Buffers() {
+		names = append(names, buffer.Name())
+		indices = append(indices, int(buffer.TypeIndex()))
+	}
+	goIndexType(o, "BufferTypeIndex", names, indices)
+
+	names, indices = nil, nil
+	for _, archetype := range root.Archetypes() {
+		names = append(names, archetype.Name())
+		indices = append(indices, int(archetype.Index().Value()))
+	}
+	goIndexType(o, "ArchetypeTypeIndex", names, indices)
+}
+
+func goEnums(o *output, root *definition.Root) {
+	for _, enum := range root.Enums() {
+		name := PascalCase(enum.Name())
+		o.blank()
+		o.line("type %s %s", name, goPrimitiveTypes[enum.StorageType()])
+		if len(enum.Constants()) == 0 {
+			continue
+		}
+		o.blank()
+		o.line("const (")
+		o.in()
+		for _, constant := range enum.Constants() {
+			o.line("%s%s %s = %d", name, PascalCase(constant.Name()), name, constant.Value())
+		}
+		o.out()
+		o.line(")")
+	}
+}
+
+func goStruct(o *output, root *definition.Root, name string, embedded string, fields []*definition.Field) {
+	o.blank()
+	o.line("type %s struct {", name)
+	o.in()
+	if embedded != "" {
+		o.line("%s", embedded)
+	}
+	goFields(o, root, fields)
+	o.out()
+	o.line("}")
+}
+
+func goTypeIndexMethod(o *output, name string, indexType string) {
+	o.blank()
+	o.line("func (%s) TypeIndex() %s {", name, indexType)
+	o.in()
+	o.line("return %s%s", indexType, name)
+	o.out()
+	o.line("}")
+}
+
+func goArchetypeDescriptorTypes(o *output) {
+	o.blank()
+	o.line("type LodDescriptor struct {")
+	o.in()
+	o.line("Level int")
+	o.line("Distance int")
+	o.line("Rate int")
+	o.line("Items []string")
+	o.out()
+	o.line("}")
+	o.blank()
+	o.line("type ArchetypeDescriptor struct {")
+	o.in()
+	o.line("Name string")
+	o.line("TypeIndex ArchetypeTypeIndex")
+	o.line("ID uint16")
+	o.line("Lods []LodDescriptor")
+	o.out()
+	o.line("}")
+}
+
+func goArchetypes(o *output, root *definition.Root) {
+	for _, archetype := range root.Archetypes() {
+		name := PascalCase(archetype.Name())
+		o.blank()
+		o.line("var %sArchetype = ArchetypeDescriptor{", name)
+		o.in()
+		o.line("Name: %q,", archetype.Name())
+		o.line("TypeIndex: ArchetypeTypeIndex%s,", name)
+		o.line("ID: %d,", archetype.ID().Value())
+		o.line("Lods: []LodDescriptor{")
+		o.in()
+		for _, lod := range archetype.Lods() {
+			var items string
+			for i, item := range lod.Items() {
+				if i > 0 {
+					items += ", "
+				}
+				items += fmt.Sprintf("%q", item.Name())
+			}
+			o.line("{Level: %d, Distance: %d, Rate: %d, Items: []string{%s}},", lod.Level(), lod.Distance(), lod.Rate(), items)
+		}
+		o.out()
+		o.line("},")
+		o.out()
+		o.line("}")
+	}
+}
+
+// WriteGo writes all definitions in root as gofmt formatted Go source in package packageName.
+func WriteGo(writer io.Writer, root *definition.Root, packageName string) error {
+	var buffer bytes.Buffer
+	o := newOutput(&buffer, "\t")
+	o.line("// Code generated by scrawl-gen. DO NOT EDIT.")
+	o.blank()
+	o.line("package %s", packageName)
+	o.blank()
+	goSchema(o, root)
+	goTypeIndices(o, root)
+	goEnums(o, root)
+
+	for _, userType := range root.UserTypes() {
+		goStruct(o, root, PascalCase(userType.TypeName()), "", userType.Fields())
+	}
+
+	for _, component := range root.ComponentDataTypes() {
+		name := PascalCase(component.Name())
+		embedded := ""
+		if component.Base() != nil {
+			embedded = PascalCase(component.Base().Name())
+		}
+		goStruct(o, root, name, embedded, component.OwnFields())
+		goTypeIndexMethod(o, name, "ComponentTypeIndex")
+	}
+
+	for _, event := range root.Events() {
+		name := PascalCase(event.Name())
+		goStruct(o, root, name, "", event.Fields())
+		goTypeIndexMethod(o, name, "EventTypeIndex")
+	}
+
+	for _, command := range root.Commands() {
+		name := PascalCase(command.Name())
+		goStruct(o, root, name, "", command.Fields())
+		goTypeIndexMethod(o, name, "CommandTypeIndex")
+	}
+
+	for _, buffer := range root.Buffers() {
+		name := PascalCase(buffer.Name())
+		goStruct(o, root, name, "", buffer.Fields())
+		goTypeIndexMethod(o, name, "BufferTypeIndex")
+	}
+
+	goArchetypeDescriptorTypes(o)
+	goArchetypes(o, root)
+
+	formatted, formatErr := format.Source(buffer.Bytes())
+	if formatErr != nil {
+		return fmt.Errorf("generated Go source could not be formatted: %v", formatErr)
+	}
+
+	_, writeErr := writer.Write(formatted)
+
+	return writeErr
+}
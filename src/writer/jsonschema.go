@@ -0,0 +1,218 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package writer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/piot/scrawl-go/src/definition"
+)
+
+type jsonSchema map[string]interface{}
+
+func jsonSchemaIntegerRange(primitive definition.PrimitiveType) (interface{}, interface{}) {
+	switch primitive {
+	case definition.PrimitiveInt8:
+		return math.MinInt8, math.MaxInt8
+	case definition.PrimitiveUint8:
+		return 0, math.MaxUint8
+	case definition.PrimitiveInt16:
+		return math.MinInt16, math.MaxInt16
+	case definition.PrimitiveUint16:
+		return 0, math.MaxUint16
+	case definition.PrimitiveInt32:
+		return math.MinInt32, math.MaxInt32
+	case definition.PrimitiveUint32:
+		return 0, uint32(math.MaxUint32)
+	case definition.PrimitiveInt64:
+		return int64(math.MinInt64), int64(math.MaxInt64)
+	}
+	return 0, uint64(math.MaxUint64)
+}
+
+// jsonSchemaNumber reads 'min' or 'max' meta data as a JSON number.
+func jsonSchemaNumber(field *definition.Field, name string) (json.Number, bool, error) {
+	meta := field.MetaData()
+	value, found := meta.Values[name]
+	if !found {
+		return "", false, nil
+	}
+	_, parseErr := strconv.ParseFloat(value, 64)
+	if parseErr != nil {
+		return "", false, fmt.Errorf("field '%v' has illegal %v '%v'", field.Name(), name, value)
+	}
+	return json.Number(value), true, nil
+}
+
+func jsonSchemaField(root *definition.Root, field *definition.Field) (jsonSchema, error) {
+	items, itemsErr := jsonSchemaItem(root, field)
+	if itemsErr != nil {
+		return nil, itemsErr
+	}
+	if !field.IsArray() {
+		return items, nil
+	}
+	return jsonSchema{"type": "array", "maxItems": field.Capacity(), "items": items}, nil
+}
+
+func jsonSchemaItem(root *definition.Root, field *definition.Field) (jsonSchema, error) {
+	resolved := root.ResolveFieldType(field.FieldType())
+	switch resolved.Variant() {
+	case definition.FieldTypeEnum, definition.FieldTypeUserType, definition.FieldTypeComponent:
+		return jsonSchema{"$ref": "#/$defs/" + field.FieldType()}, nil
+	case definition.FieldTypeUnknown:
+		return jsonSchema{"description": "unknown type " + field.FieldType()}, nil
+	}
+
+	primitive := resolved.Primitive()
+	switch {
+	case primitive == definition.PrimitiveBool:
+		return jsonSchema{"type": "boolean"}, nil
+	case primitive == definition.PrimitiveString:
+		return jsonSchema{"type": "string"}, nil
+	}
+
+	schema := jsonSchema{"type": "number"}
+	if primitive.IsInteger() {
+		schema["type"] = "integer"
+		schema["minimum"], schema["maximum"] = jsonSchemaIntegerRange(primitive)
+	}
+
+	minimum, hasMinimum, minimumErr := jsonSchemaNumber(field, "min")
+	if minimumErr != nil {
+		return nil, minimumErr
+	}
+	if hasMinimum {
+		schema["minimum"] = minimum
+	}
+	maximum, hasMaximum, maximumErr := jsonSchemaNumber(field, "max")
+	if maximumErr != nil {
+		return nil, maximumErr
+	}
+	if hasMaximum {
+		schema["maximum"] = maximum
+	}
+
+	return schema, nil
+}
+
+func jsonSchemaObject(root *definition.Root, fields []*definition.Field) (jsonSchema, error) {
+	properties := jsonSchema{}
+	required := []string{}
+	for _, field := range fields {
+		fieldSchema, fieldErr := jsonSchemaField(root, field)
+		if fieldErr != nil {
+			return nil, fieldErr
+		}
+		properties[field.Name()] = fieldSchema
+		required = append(required, field.Name())
+	}
+	return jsonSchema{"type": "object", "properties": properties, "required": required, "additionalProperties": false}, nil
+}
+
+func jsonSchemaEnum(enum *definition.Enum) jsonSchema {
+	names := []string{}
+	for _, constant := range enum.Constants() {
+		names = append(names, constant.Name())
+	}
+	return jsonSchema{"type": "string", "enum": names}
+}
+
+// WriteJSONSchema writes a JSON Schema (draft 2020-12) document with one entry in $defs for every
+// enum, user type, component, event, command and buffer. Enum values are the constant names.
+// Extended components are flattened.
+func WriteJSONSchema(writer io.Writer, root *definition.Root) error {
+	defs := jsonSchema{}
+	add := func(name string, comment string, fields []*definition.Field) error {
+		if _, exists := defs[name]; exists {
+			return fmt.Errorf("'%v' is defined more than once", name)
+		}
+		object, objectErr := jsonSchemaObject(root, fields)
+		if objectErr != nil {
+			return fmt.Errorf("'%v': %v", name, objectErr)
+		}
+		object["$comment"] = comment
+		defs[name] = object
+		return nil
+	}
+
+	for _, enum := range root.Enums() {
+		defs[enum.Name()] = jsonSchemaEnum(enum)
+	}
+	for _, userType := range root.UserTypes() {
+		if err := add(userType.TypeName(), "type", userType.Fields()); err != nil {
+			return err
+		}
+	}
+	for _, component := range root.ComponentDataTypes() {
+		comment := fmt.Sprintf("component %d", component.Index())
+		if component.Base() != nil {
+			comment += " extends " + component.Base().Name()
+		}
+		if err := add(component.Name(), comment, component.Fields()); err != nil {
+			return err
+		}
+	}
+	for _, event := range root.Events() {
+		if err := add(event.Name(), fmt.Sprintf("event %d", event.TypeIndex()), event.Fields()); err != nil {
+			return err
+		}
+	}
+	for _, command := range root.Commands() {
+		if err := add(command.Name(), fmt.Sprintf("command %d", command.TypeIndex()), command.Fields()); err != nil {
+			return err
+		}
+	}
+	for _, buffer := range root.Buffers() {
+		if err := add(buffer.Name(), fmt.Sprintf("buffer %d", buffer.TypeIndex()), buffer.Fields()); err != nil {
+			return err
+		}
+	}
+
+	document := jsonSchema{
+		"$schema":  "https://json-schema.org/draft/2020-12/schema",
+		"title":    root.Name(),
+		"$comment": fmt.Sprintf("Generated by scrawl-gen. Hash %v %v", root.Hash().Algorithm(), root.Hash()),
+		"$defs":    defs,
+	}
+	if root.Namespace() != "" {
+		document["$id"] = "urn:scrawl:" + root.Namespace()
+	}
+
+	octets, marshalErr := json.MarshalIndent(document, "", "  ")
+	if marshalErr != nil {
+		return marshalErr
+	}
+	octets = append(octets, '\n')
+
+	_, writeErr := writer.Write(octets)
+	return writeErr
+}
@@ -0,0 +1,88 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package writer
+
+import "github.com/piot/scrawl-go/src/definition"
+
+// structDeclaration is a user type or a component, the definitions that fields can refer to.
+type structDeclaration struct {
+	userType  *definition.UserType
+	component *definition.ComponentDataType
+}
+
+type declarationOrder struct {
+	root    *definition.Root
+	visited map[interface{}]bool
+	order   []structDeclaration
+}
+
+func (d *declarationOrder) visitFields(fields []*definition.Field) {
+	for _, field := range fields {
+		resolved := d.root.ResolveFieldType(field.FieldType())
+		switch resolved.Variant() {
+		case definition.FieldTypeUserType:
+			d.visitUserType(resolved.UserType())
+		case definition.FieldTypeComponent:
+			d.visitComponent(resolved.ComponentDataType())
+		}
+	}
+}
+
+func (d *declarationOrder) visitUserType(userType *definition.UserType) {
+	if d.visited[userType] {
+		return
+	}
+	d.visited[userType] = true
+	d.visitFields(userType.Fields())
+	d.order = append(d.order, structDeclaration{userType: userType})
+}
+
+func (d *declarationOrder) visitComponent(component *definition.ComponentDataType) {
+	if d.visited[component] {
+		return
+	}
+	d.visited[component] = true
+	if component.Base() != nil {
+		d.visitComponent(component.Base())
+	}
+	d.visitFields(component.Fields())
+	d.order = append(d.order, structDeclaration{component: component})
+}
+
+// structDeclarations returns the user types followed by the components, where every definition is
+// moved after the definitions it refers to. C and C++ need a struct to be complete before it is used
+// as a field or a base, but the protocol can declare them in any order.
+func structDeclarations(root *definition.Root) []structDeclaration {
+	d := &declarationOrder{root: root, visited: make(map[interface{}]bool)}
+	for _, userType := range root.UserTypes() {
+		d.visitUserType(userType)
+	}
+	for _, component := range root.ComponentDataTypes() {
+		d.visitComponent(component)
+	}
+	return d.order
+}
@@ -0,0 +1,79 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package writer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// output writes indented lines and keeps the first error, so generators can check it once at the end.
+type output struct {
+	writer      io.Writer
+	indentation string
+	indent      int
+	err         error
+}
+
+func newOutput(writer io.Writer, indentation string) *output {
+	return &output{writer: writer, indentation: indentation}
+}
+
+func (o *output) line(format string, a ...interface{}) {
+	if o.err != nil {
+		return
+	}
+	text := fmt.Sprintf(format, a...)
+	if text != "" {
+		text = strings.Repeat(o.indentation, o.indent) + text
+	}
+	_, o.err = fmt.Fprintln(o.writer, text)
+}
+
+func (o *output) blank() {
+	o.line("")
+}
+
+func (o *output) in() {
+	o.indent++
+}
+
+func (o *output) out() {
+	o.indent--
+}
+
+func (o *output) open(format string, a ...interface{}) {
+	o.line(format, a...)
+	o.line("{")
+	o.in()
+}
+
+func (o *output) close(suffix string) {
+	o.out()
+	o.line("}" + suffix)
+}
@@ -0,0 +1,189 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package writer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/piot/scrawl-go/src/definition"
+)
+
+var protobufPrimitiveTypes = map[definition.PrimitiveType]string{
+	definition.PrimitiveBool:    "bool",
+	definition.PrimitiveInt8:    "sint32",
+	definition.PrimitiveUint8:   "uint32",
+	definition.PrimitiveInt16:   "sint32",
+	definition.PrimitiveUint16:  "uint32",
+	definition.PrimitiveInt32:   "sint32",
+	definition.PrimitiveUint32:  "uint32",
+	definition.PrimitiveInt64:   "sint64",
+	definition.PrimitiveUint64:  "uint64",
+	definition.PrimitiveFloat32: "float",
+	definition.PrimitiveFloat64: "double",
+	definition.PrimitiveString:  "string",
+}
+
+func protobufType(root *definition.Root, fieldType string) string {
+	resolved := root.ResolveFieldType(fieldType)
+	if resolved.Variant() == definition.FieldTypePrimitive {
+		return protobufPrimitiveTypes[resolved.Primitive()]
+	}
+	return fieldType
+}
+
+// protobufFieldNumber uses the 'ordinal' meta data if present, otherwise the field index plus one.
+func protobufFieldNumber(field *definition.Field) (int, error) {
+	meta := field.MetaData()
+	number, err := meta.IntWithDefault("ordinal", field.Index()+1)
+	if err != nil {
+		return 0, fmt.Errorf("field '%v' has illegal ordinal '%v'", field.Name(), meta.Field("ordinal"))
+	}
+	if number < 1 || number > 536870911 || (number >= 19000 && number <= 19999) {
+		return 0, fmt.Errorf("field '%v' has ordinal %d, which can not be used in protobuf", field.Name(), number)
+	}
+	return number, nil
+}
+
+func protobufMessage(o *output, root *definition.Root, name string, fields []*definition.Field) error {
+	o.blank()
+	o.line("message %s {", name)
+	o.in()
+	usedNumbers := make(map[int]string)
+	for _, field := range fields {
+		number, numberErr := protobufFieldNumber(field)
+		if numberErr != nil {
+			return fmt.Errorf("message '%v': %v", name, numberErr)
+		}
+		existing, alreadyUsed := usedNumbers[number]
+		if alreadyUsed {
+			return fmt.Errorf("message '%v': fields '%v' and '%v' both use number %d", name, existing, field.Name(), number)
+		}
+		usedNumbers[number] = field.Name()
+		label := ""
+		if field.IsArray() {
+			label = "repeated "
+		}
+		o.line("%s%s %s = %d;", label, protobufType(root, field.FieldType()), SnakeCase(field.Name()), number)
+	}
+	o.out()
+	o.line("}")
+	return nil
+}
+
+// protobufEnum writes the enum. Proto3 requires the first value to be zero, so if there is no
+// zero constant an UNSPECIFIED value is added and a warning is returned.
+func protobufEnum(o *output, enum *definition.Enum) string {
+	prefix := ScreamingSnakeCase(enum.Name())
+	var zero *definition.EnumConstant
+	for _, constant := range enum.Constants() {
+		if constant.Value() == 0 {
+			zero = constant
+			break
+		}
+	}
+
+	var warning string
+	o.blank()
+	o.line("enum %s {", enum.Name())
+	o.in()
+	if zero != nil {
+		o.line("%s_%s = 0;", prefix, ScreamingSnakeCase(zero.Name()))
+	} else {
+		o.line("%s_UNSPECIFIED = 0;", prefix)
+		warning = fmt.Sprintf("enum '%v' has no zero value, added %v_UNSPECIFIED", enum.Name(), prefix)
+	}
+	for _, constant := range enum.Constants() {
+		if constant == zero {
+			continue
+		}
+		o.line("%s_%s = %d;", prefix, ScreamingSnakeCase(constant.Name()), constant.Value())
+	}
+	o.out()
+	o.line("}")
+
+	return warning
+}
+
+// WriteProtobuf writes user types, components, events, commands and buffers as proto3 messages.
+// Extended components are flattened. Returns warnings for things that had to be adjusted.
+func WriteProtobuf(writer io.Writer, root *definition.Root) ([]string, error) {
+	var warnings []string
+	o := newOutput(writer, "  ")
+	o.line("// Generated by scrawl-gen. Do not edit.")
+	o.line("syntax = \"proto3\";")
+	if root.Namespace() != "" {
+		var parts []string
+		for _, part := range splitNamespace(root.Namespace()) {
+			parts = append(parts, SnakeCase(part))
+		}
+		o.blank()
+		o.line("package %s;", strings.Join(parts, "."))
+	}
+
+	for _, enum := range root.Enums() {
+		warning := protobufEnum(o, enum)
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+
+	type message struct {
+		name   string
+		fields []*definition.Field
+	}
+	var messages []message
+	for _, userType := range root.UserTypes() {
+		messages = append(messages, message{userType.TypeName(), userType.Fields()})
+	}
+	for _, component := range root.ComponentDataTypes() {
+		messages = append(messages, message{component.Name(), component.Fields()})
+	}
+	for _, event := range root.Events() {
+		messages = append(messages, message{event.Name(), event.Fields()})
+	}
+	for _, command := range root.Commands() {
+		messages = append(messages, message{command.Name(), command.Fields()})
+	}
+	for _, buffer := range root.Buffers() {
+		messages = append(messages, message{buffer.Name(), buffer.Fields()})
+	}
+
+	for _, m := range messages {
+		messageErr := protobufMessage(o, root, m.name, m.fields)
+		if messageErr != nil {
+			return warnings, messageErr
+		}
+	}
+
+	if o.err != nil {
+		return warnings, fmt.Errorf("could not write protobuf: %v", o.err)
+	}
+
+	return warnings, nil
+}
@@ -0,0 +1,95 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package writer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/piot/scrawl-go/src/parser"
+)
+
+func TestProtobuf(t *testing.T) {
+	root := setupRoot(t, "generate")
+	builder := &strings.Builder{}
+	warnings, err := WriteProtobuf(builder, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(warnings) != 0 {
+		t.Errorf("unexpected warnings %v", warnings)
+	}
+
+	checkGolden(t, "generate.protobuf", builder.String())
+}
+
+func TestProtobufEnumWithoutZero(t *testing.T) {
+	p, err := parser.NewParser(`
+enum Direction
+  North 1
+  South 2
+
+event Moved
+  direction Direction
+  speed int32 [ordinal "5"]
+`, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	builder := &strings.Builder{}
+	warnings, writeErr := WriteProtobuf(builder, p.Root())
+	if writeErr != nil {
+		t.Fatal(writeErr)
+	}
+
+	if len(warnings) != 1 {
+		t.Errorf("missing zero value should be reported %v", warnings)
+	}
+
+	generated := builder.String()
+	if !strings.Contains(generated, "DIRECTION_UNSPECIFIED = 0;") || !strings.Contains(generated, "sint32 speed = 5;") {
+		t.Errorf("wrong output %v", generated)
+	}
+}
+
+func TestProtobufDuplicateOrdinal(t *testing.T) {
+	p, err := parser.NewParser(`
+event Moved
+  x int32
+  y int32 [ordinal "1"]
+`, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, writeErr := WriteProtobuf(&strings.Builder{}, p.Root())
+	if writeErr == nil {
+		t.Errorf("duplicate field numbers should be reported")
+	}
+}
@@ -0,0 +1,224 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package writer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/piot/scrawl-go/src/definition"
+)
+
+var rustPrimitiveTypes = map[definition.PrimitiveType]string{
+	definition.PrimitiveBool:    "bool",
+	definition.PrimitiveInt8:    "i8",
+	definition.PrimitiveUint8:   "u8",
+	definition.PrimitiveInt16:   "i16",
+	definition.PrimitiveUint16:  "u16",
+	definition.PrimitiveInt32:   "i32",
+	definition.PrimitiveUint32:  "u32",
+	definition.PrimitiveInt64:   "i64",
+	definition.PrimitiveUint64:  "u64",
+	definition.PrimitiveFloat32: "f32",
+	definition.PrimitiveFloat64: "f64",
+	definition.PrimitiveString:  "String",
+}
+
+var rustKeywords = []string{"as", "break", "const", "continue", "else", "enum", "extern", "false", "fn", "for",
+	"if", "impl", "in", "let", "loop", "match", "mod", "move", "mut", "pub", "ref", "return", "static", "struct",
+	"trait", "true", "type", "unsafe", "use", "where", "while", "async", "await", "dyn", "abstract", "become", "box",
+	"do", "final", "macro", "override", "priv", "try", "typeof", "unsized", "virtual", "yield"}
+
+// rustPathKeywords can not be raw identifiers, so they get a trailing underscore instead.
+var rustPathKeywords = []string{"crate", "self", "Self", "super"}
+
+func rustIdentifier(name string) string {
+	for _, keyword := range rustPathKeywords {
+		if keyword == name {
+			return name + "_"
+		}
+	}
+	for _, keyword := range rustKeywords {
+		if keyword == name {
+			return "r#" + name
+		}
+	}
+	return name
+}
+
+func rustType(root *definition.Root, fieldType string) string {
+	resolved := root.ResolveFieldType(fieldType)
+	if resolved.Variant() == definition.FieldTypePrimitive {
+		return rustPrimitiveTypes[resolved.Primitive()]
+	}
+	return PascalCase(fieldType)
+}
+
+func rustSchema(o *output, root *definition.Root) {
+	hash := root.Hash()
+	o.line("pub const SCHEMA_NAME: &str = %q;", root.Name())
+	switch len(hash.Octets()) {
+	case 4:
+		o.line("pub const SCHEMA_HASH: u32 = 0x%08x;", hash.Uint32())
+	case 8:
+		o.line("pub const SCHEMA_HASH: u64 = 0x%016x;", hash.Uint64())
+	}
+	o.line("pub const SCHEMA_HASH_STRING: &str = %q;", hash.String())
+	o.line("pub const SCHEMA_HASH_ALGORITHM: &str = %q;", hash.Algorithm().String())
+	o.blank()
+	o.line("pub trait TypeIndex {")
+	o.in()
+	o.line("const TYPE_INDEX: u8;")
+	o.out()
+	o.line("}")
+}
+
+func rustEnums(o *output, root *definition.Root) {
+	for _, enum := range root.Enums() {
+		name := PascalCase(enum.Name())
+		o.blank()
+		if len(enum.Constants()) > 0 {
+			o.line("#[repr(%s)]", rustPrimitiveTypes[enum.StorageType()])
+		}
+		o.line("#[derive(Debug, Clone, Copy, PartialEq, Eq)]")
+		o.line("pub enum %s {", name)
+		o.in()
+		for _, constant := range enum.Constants() {
+			o.line("%s = %d,", PascalCase(constant.Name()), constant.Value())
+		}
+		o.out()
+		o.line("}")
+		if len(enum.Constants()) == 0 {
+			continue
+		}
+		o.blank()
+		o.line("impl Default for %s {", name)
+		o.in()
+		o.line("fn default() -> Self {")
+		o.in()
+		o.line("%s::%s", name, PascalCase(enum.Constants()[0].Name()))
+		o.out()
+		o.line("}")
+		o.out()
+		o.line("}")
+	}
+}
+
+func rustStruct(o *output, root *definition.Root, name string, fields []*definition.Field) {
+	o.blank()
+	o.line("#[derive(Debug, Clone, Default, PartialEq)]")
+	o.line("pub struct %s {", PascalCase(name))
+	o.in()
+	for _, field := range fields {
+		if field.IsArray() {
+			o.line("pub %s: Vec<%s>,", rustIdentifier(SnakeCase(field.Name())), rustType(root, field.FieldType()))
+			continue
+		}
+		o.line("pub %s: %s,", rustIdentifier(SnakeCase(field.Name())), rustType(root, field.FieldType()))
+	}
+	o.out()
+	o.line("}")
+}
+
+func rustTypeIndex(o *output, name string, typeIndex int) {
+	o.blank()
+	o.line("impl TypeIndex for %s {", PascalCase(name))
+	o.in()
+	o.line("const TYPE_INDEX: u8 = %d;", typeIndex)
+	o.out()
+	o.line("}")
+}
+
+func rustArchetypes(o *output, root *definition.Root) {
+	for _, archetype := range root.Archetypes() {
+		prefix := ScreamingSnakeCase(archetype.Name())
+		o.blank()
+		o.line("pub const %s_TYPE_INDEX: u8 = %d;", prefix, archetype.Index().Value())
+		o.line("pub const %s_ID: u16 = %d;", prefix, archetype.ID().Value())
+		for _, lod := range archetype.Lods() {
+			quoted := make([]string, len(lod.Items()))
+			for i, item := range lod.Items() {
+				quoted[i] = fmt.Sprintf("%q", item.Name())
+			}
+			o.line("pub const %s_LOD%d_ITEMS: &[&str] = &[%s];", prefix, lod.Level(), strings.Join(quoted, ", "))
+		}
+	}
+}
+
+// WriteRust writes all definitions in root as Rust source, nested in one module per part of the
+// root namespace. Extended components are flattened, since Rust has no inheritance.
+func WriteRust(writer io.Writer, root *definition.Root) error {
+	o := newOutput(writer, "    ")
+	o.line("// Generated by scrawl-gen. Do not edit.")
+	o.blank()
+
+	var modules []string
+	if root.Namespace() != "" {
+		modules = strings.Split(root.Namespace(), ".")
+	}
+	for _, module := range modules {
+		o.line("pub mod %s {", rustIdentifier(SnakeCase(module)))
+		o.in()
+	}
+
+	rustSchema(o, root)
+	rustEnums(o, root)
+
+	for _, userType := range root.UserTypes() {
+		rustStruct(o, root, userType.TypeName(), userType.Fields())
+	}
+	for _, component := range root.ComponentDataTypes() {
+		rustStruct(o, root, component.Name(), component.Fields())
+		rustTypeIndex(o, component.Name(), int(component.Index()))
+	}
+	for _, event := range root.Events() {
+		rustStruct(o, root, event.Name(), event.Fields())
+		rustTypeIndex(o, event.Name(), int(event.TypeIndex()))
+	}
+	for _, command := range root.Commands() {
+		rustStruct(o, root, command.Name(), command.Fields())
+		rustTypeIndex(o, command.Name(), int(command.TypeIndex()))
+	}
+	for _, buffer := range root.Buffers() {
+		rustStruct(o, root, buffer.Name(), buffer.Fields())
+		rustTypeIndex(o, buffer.Name(), int(buffer.TypeIndex()))
+	}
+
+	rustArchetypes(o, root)
+
+	for range modules {
+		o.out()
+		o.line("}")
+	}
+
+	if o.err != nil {
+		return fmt.Errorf("could not write Rust: %v", o.err)
+	}
+
+	return nil
+}
@@ -0,0 +1,181 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package writer
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/piot/scrawl-go/src/definition"
+)
+
+var templateTypeMaps = map[string]map[definition.PrimitiveType]string{
+	"c":          cPrimitiveTypes,
+	"cpp":        cppPrimitiveTypes,
+	"csharp":     csharpPrimitiveTypes,
+	"go":         goPrimitiveTypes,
+	"rust":       rustPrimitiveTypes,
+	"typescript": typeScriptPrimitiveTypes,
+}
+
+func splitNamespace(namespace string) []string {
+	return strings.Split(namespace, ".")
+}
+
+// mapType maps a field to a type in one of the built-in languages. Fields that are not
+// primitives keep their type name.
+func mapType(language string, field *TemplateField) (string, error) {
+	typeMap, found := templateTypeMaps[language]
+	if !found {
+		return "", fmt.Errorf("no type map for language '%v'", language)
+	}
+	if field.Kind != "primitive" {
+		return field.Type, nil
+	}
+	primitive, _ := definition.LookupPrimitiveType(field.Primitive)
+	return typeMap[primitive], nil
+}
+
+func metaLookup(meta map[string]string, name string, defaultValue ...string) string {
+	value, found := meta[name]
+	if !found && len(defaultValue) > 0 {
+		return defaultValue[0]
+	}
+	return value
+}
+
+func hasMeta(meta map[string]string, name string) bool {
+	_, found := meta[name]
+	return found
+}
+
+func findLod(archetype *TemplateArchetype, level int) (*TemplateLod, error) {
+	if level < 0 || level >= len(archetype.Lods) {
+		return nil, fmt.Errorf("archetype '%v' has no lod%d", archetype.Name, level)
+	}
+	return archetype.Lods[level], nil
+}
+
+func dict(keysAndValues ...string) (map[string]string, error) {
+	if len(keysAndValues)%2 != 0 {
+		return nil, fmt.Errorf("dict needs pairs of keys and values")
+	}
+	m := make(map[string]string)
+	for i := 0; i < len(keysAndValues); i += 2 {
+		m[keysAndValues[i]] = keysAndValues[i+1]
+	}
+	return m, nil
+}
+
+// TemplateFuncs are the helper functions available in templates:
+//
+//	pascal, camel, snake, screaming, upperFirst, lowerFirst: case conversion
+//	mapType "go" .Field: the type of a field in one of the built-in languages
+//	meta .Meta "name" ["default"], hasMeta .Meta "name": meta data lookup
+//	lod .Archetype 1: a level of detail
+//	join, quote, add, sub, dict: general helpers
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"pascal":     PascalCase,
+		"camel":      CamelCase,
+		"snake":      SnakeCase,
+		"screaming":  ScreamingSnakeCase,
+		"upperFirst": upperFirst,
+		"lowerFirst": lowerFirst,
+		"mapType":    mapType,
+		"meta":       metaLookup,
+		"hasMeta":    hasMeta,
+		"lod":        findLod,
+		"join":       strings.Join,
+		"quote":      func(s string) string { return fmt.Sprintf("%q", s) },
+		"add":        func(a int, b int) int { return a + b },
+		"sub":        func(a int, b int) int { return a - b },
+		"dict":       dict,
+	}
+}
+
+// WriteTemplate executes tmpl against the view model of root.
+func WriteTemplate(writer io.Writer, root *definition.Root, tmpl *template.Template) error {
+	return tmpl.Execute(writer, NewTemplateModel(root))
+}
+
+// WriteTemplateDirectory executes every '.tmpl' file in templateDirectory and writes the result to
+// outputDirectory, with the '.tmpl' extension removed. Files starting with '_' are only parsed, so
+// they can hold shared {{define}} blocks.
+func WriteTemplateDirectory(root *definition.Root, templateDirectory string, outputDirectory string) error {
+	filenames, globErr := filepath.Glob(filepath.Join(templateDirectory, "*.tmpl"))
+	if globErr != nil {
+		return globErr
+	}
+	if len(filenames) == 0 {
+		return fmt.Errorf("no .tmpl files in '%v'", templateDirectory)
+	}
+
+	templates, parseErr := template.New("").Funcs(TemplateFuncs()).ParseFiles(filenames...)
+	if parseErr != nil {
+		return parseErr
+	}
+
+	model := NewTemplateModel(root)
+	for _, filename := range filenames {
+		name := filepath.Base(filename)
+		if strings.HasPrefix(name, "_") {
+			continue
+		}
+
+		outputFilename := filepath.Join(outputDirectory, strings.TrimSuffix(name, ".tmpl"))
+		outputFile, createErr := os.Create(outputFilename)
+		if createErr != nil {
+			return createErr
+		}
+
+		executeErr := templates.ExecuteTemplate(outputFile, name, model)
+		closeErr := outputFile.Close()
+		if executeErr != nil {
+			return executeErr
+		}
+		if closeErr != nil {
+			return closeErr
+		}
+	}
+
+	return nil
+}
+
+// ReadTemplate parses a single template file with the helper functions.
+func ReadTemplate(filename string) (*template.Template, error) {
+	octets, readErr := ioutil.ReadFile(filename)
+	if readErr != nil {
+		return nil, readErr
+	}
+	return template.New(filepath.Base(filename)).Funcs(TemplateFuncs()).Parse(string(octets))
+}
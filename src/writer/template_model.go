@@ -0,0 +1,219 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package writer
+
+import (
+	"github.com/piot/scrawl-go/src/definition"
+)
+
+// TemplateModel is the view of a definition.Root that templates are executed against.
+// Fields are only ever added to the model, never renamed or removed, so templates keep working.
+type TemplateModel struct {
+	Name           string
+	Namespace      string
+	NamespaceParts []string
+	Hash           string
+	HashAlgorithm  string
+	Enums          []*TemplateEnum
+	UserTypes      []*TemplateStruct
+	Components     []*TemplateStruct
+	Events         []*TemplateStruct
+	Commands       []*TemplateStruct
+	Buffers        []*TemplateStruct
+	Archetypes     []*TemplateArchetype
+}
+
+// TemplateField is a field in a user type, component, event, command or buffer.
+// Kind is one of "primitive", "enum", "type", "component" or "unknown". Primitive is the
+// canonical primitive name, for example "int32", and only set if Kind is "primitive".
+// Capacity is the maximum number of items for arrays and zero for other fields.
+type TemplateField struct {
+	Name      string
+	Type      string
+	Index     int
+	Kind      string
+	Primitive string
+	Capacity  int
+	Meta      map[string]string
+}
+
+type TemplateEnumConstant struct {
+	Name  string
+	Value int
+	Index int
+}
+
+// TemplateEnum has the smallest primitive that can hold all values in StorageType.
+type TemplateEnum struct {
+	Name        string
+	StorageType string
+	Constants   []*TemplateEnumConstant
+}
+
+// TemplateStruct is used for user types, components, events, commands and buffers.
+// TypeIndex is -1 for user types. Fields include inherited fields, OwnFields does not.
+// Base is the name of the extended component, or empty.
+type TemplateStruct struct {
+	Name      string
+	TypeIndex int
+	Base      string
+	Fields    []*TemplateField
+	OwnFields []*TemplateField
+	Meta      map[string]string
+}
+
+// TemplateItem is a component or field in a level of detail.
+type TemplateItem struct {
+	Name        string
+	Index       int
+	IsComponent bool
+	Meta        map[string]string
+}
+
+// TemplateLod is a level of detail. DerivedFrom is -1 if it was not derived from another
+// level. Dropped and Added are compared with the level before.
+type TemplateLod struct {
+	Level       int
+	Distance    int
+	Rate        int
+	DerivedFrom int
+	Items       []*TemplateItem
+	Dropped     []*TemplateItem
+	Added       []*TemplateItem
+}
+
+type TemplateArchetype struct {
+	Name      string
+	TypeIndex int
+	ID        int
+	Base      string
+	Lods      []*TemplateLod
+	Meta      map[string]string
+}
+
+func fieldKind(variant definition.FieldTypeVariant) string {
+	switch variant {
+	case definition.FieldTypePrimitive:
+		return "primitive"
+	case definition.FieldTypeEnum:
+		return "enum"
+	case definition.FieldTypeUserType:
+		return "type"
+	case definition.FieldTypeComponent:
+		return "component"
+	}
+	return "unknown"
+}
+
+func newTemplateFields(root *definition.Root, fields []*definition.Field) []*TemplateField {
+	var templateFields []*TemplateField
+	for _, field := range fields {
+		resolved := root.ResolveFieldType(field.FieldType())
+		primitive := ""
+		if resolved.Variant() == definition.FieldTypePrimitive {
+			primitive = resolved.Primitive().String()
+		}
+		templateFields = append(templateFields, &TemplateField{Name: field.Name(), Type: field.FieldType(),
+			Index: field.Index(), Kind: fieldKind(resolved.Variant()), Primitive: primitive, Capacity: field.Capacity(), Meta: field.MetaData().Values})
+	}
+	return templateFields
+}
+
+func newTemplateStruct(root *definition.Root, name string, typeIndex int, fields []*definition.Field, meta definition.MetaData) *TemplateStruct {
+	templateFields := newTemplateFields(root, fields)
+	return &TemplateStruct{Name: name, TypeIndex: typeIndex, Fields: templateFields, OwnFields: templateFields, Meta: meta.Values}
+}
+
+func newTemplateItems(items []*definition.EntityArchetypeItem) []*TemplateItem {
+	var templateItems []*TemplateItem
+	for _, item := range items {
+		templateItems = append(templateItems, &TemplateItem{Name: item.Name(), Index: item.Index(),
+			IsComponent: item.HasComponentReference(), Meta: item.Meta().Values})
+	}
+	return templateItems
+}
+
+func newTemplateArchetype(archetype *definition.EntityArchetype) *TemplateArchetype {
+	templateArchetype := &TemplateArchetype{Name: archetype.Name(), TypeIndex: int(archetype.Index().Value()),
+		ID: int(archetype.ID().Value()), Meta: archetype.Meta().Values}
+	if archetype.Base() != nil {
+		templateArchetype.Base = archetype.Base().Name()
+	}
+	for _, lod := range archetype.Lods() {
+		templateArchetype.Lods = append(templateArchetype.Lods, &TemplateLod{Level: lod.Level(), Distance: lod.Distance(),
+			Rate: lod.Rate(), DerivedFrom: lod.DerivedFrom(), Items: newTemplateItems(lod.Items()),
+			Dropped: newTemplateItems(archetype.DroppedItems(lod.Level())), Added: newTemplateItems(archetype.AddedItems(lod.Level()))})
+	}
+	return templateArchetype
+}
+
+// NewTemplateModel creates the view model for root.
+func NewTemplateModel(root *definition.Root) *TemplateModel {
+	model := &TemplateModel{Name: root.Name(), Namespace: root.Namespace(), Hash: root.Hash().String(),
+		HashAlgorithm: root.Hash().Algorithm().String()}
+	if root.Namespace() != "" {
+		model.NamespaceParts = splitNamespace(root.Namespace())
+	}
+
+	for _, enum := range root.Enums() {
+		templateEnum := &TemplateEnum{Name: enum.Name(), StorageType: enum.StorageType().String()}
+		for _, constant := range enum.Constants() {
+			templateEnum.Constants = append(templateEnum.Constants, &TemplateEnumConstant{Name: constant.Name(),
+				Value: constant.Value(), Index: constant.Index()})
+		}
+		model.Enums = append(model.Enums, templateEnum)
+	}
+
+	for _, userType := range root.UserTypes() {
+		model.UserTypes = append(model.UserTypes, newTemplateStruct(root, userType.TypeName(), -1, userType.Fields(), definition.MetaData{}))
+	}
+
+	for _, component := range root.ComponentDataTypes() {
+		templateComponent := newTemplateStruct(root, component.Name(), int(component.Index()), component.Fields(), component.Meta())
+		templateComponent.OwnFields = newTemplateFields(root, component.OwnFields())
+		templateComponent.Base = component.BaseName()
+		model.Components = append(model.Components, templateComponent)
+	}
+
+	for _, event := range root.Events() {
+		model.Events = append(model.Events, newTemplateStruct(root, event.Name(), int(event.TypeIndex()), event.Fields(), event.Meta()))
+	}
+
+	for _, command := range root.Commands() {
+		model.Commands = append(model.Commands, newTemplateStruct(root, command.Name(), int(command.TypeIndex()), command.Fields(), command.Meta()))
+	}
+
+	for _, buffer := range root.Buffers() {
+		model.Buffers = append(model.Buffers, newTemplateStruct(root, buffer.Name(), int(buffer.TypeIndex()), buffer.Fields(), buffer.Meta()))
+	}
+
+	for _, archetype := range root.Archetypes() {
+		model.Archetypes = append(model.Archetypes, newTemplateArchetype(archetype))
+	}
+
+	return model
+}
@@ -0,0 +1,210 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package writer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/piot/scrawl-go/src/definition"
+)
+
+var typeScriptPrimitiveTypes = map[definition.PrimitiveType]string{
+	definition.PrimitiveBool:    "boolean",
+	definition.PrimitiveInt8:    "number",
+	definition.PrimitiveUint8:   "number",
+	definition.PrimitiveInt16:   "number",
+	definition.PrimitiveUint16:  "number",
+	definition.PrimitiveInt32:   "number",
+	definition.PrimitiveUint32:  "number",
+	definition.PrimitiveInt64:   "bigint",
+	definition.PrimitiveUint64:  "bigint",
+	definition.PrimitiveFloat32: "number",
+	definition.PrimitiveFloat64: "number",
+	definition.PrimitiveString:  "string",
+}
+
+func typeScriptType(root *definition.Root, fieldType string) string {
+	resolved := root.ResolveFieldType(fieldType)
+	if resolved.Variant() == definition.FieldTypePrimitive {
+		return typeScriptPrimitiveTypes[resolved.Primitive()]
+	}
+	return fieldType
+}
+
+func typeScriptFields(o *output, root *definition.Root, fields []*definition.Field) {
+	for _, field := range fields {
+		if field.IsArray() {
+			o.line("%s: %s[];", field.Name(), typeScriptType(root, field.FieldType()))
+			continue
+		}
+		o.line("%s: %s;", field.Name(), typeScriptType(root, field.FieldType()))
+	}
+}
+
+func typeScriptSchema(o *output, root *definition.Root) {
+	hash := root.Hash()
+	o.line("export const SCHEMA_NAME = %q;", root.Name())
+	o.line("export const SCHEMA_NAMESPACE = %q;", root.Namespace())
+	o.line("export const SCHEMA_HASH = %q;", hash.String())
+	o.line("export const SCHEMA_HASH_ALGORITHM = %q;", hash.Algorithm().String())
+}
+
+func typeScriptConstEnum(o *output, name string, names []string, values []int) {
+	o.blank()
+	o.line("export const enum %s {", name)
+	o.in()
+	for i, constantName := range names {
+		o.line("%s = %d,", constantName, values[i])
+	}
+	o.out()
+	o.line("}")
+}
+
+func typeScriptEnums(o *output, root *definition.Root) {
+	for _, enum := range root.Enums() {
+		var names []string
+		var values []int
+		for _, constant := range enum.Constants() {
+			names = append(names, constant.Name())
+			values = append(values, constant.Value())
+		}
+		typeScriptConstEnum(o, enum.Name(), names, values)
+	}
+}
+
+func typeScriptTypeIndices(o *output, root *definition.Root) {
+	var names []string
+	var indices []int
+	for _, component := range root.ComponentDataTypes() {
+		names = append(names, component.Name())
+		indices = append(indices, int(component.Index()))
+	}
+	typeScriptConstEnum(o, "ComponentTypeIndex", names, indices)
+
+	names, indices = nil, nil
+	for _, event := range root.Events() {
+		names = append(names, event.Name())
+		indices = append(indices, int(event.TypeIndex()))
+	}
+	typeScriptConstEnum(o, "EventTypeIndex", names, indices)
+
+	names, indices = nil, nil
+	for _, command := range root.Commands() {
+		names = append(names, command.Name())
+		indices = append(indices, int(command.TypeIndex()))
+	}
+	typeScriptConstEnum(o, "CommandTypeIndex", names, indices)
+
+	names, indices = nil, nil
+	for _, buffer := range root.Buffers() {
+		names = append(names, buffer.Name())
+		indices = append(indices, int(buffer.TypeIndex()))
+	}
+	typeScriptConstEnum(o, "BufferTypeIndex", names, indices)
+
+	names, indices = nil, nil
+	for _, archetype := range root.Archetypes() {
+		names = append(names, archetype.Name())
+		indices = append(indices, int(archetype.Index().Value()))
+	}
+	typeScriptConstEnum(o, "ArchetypeTypeIndex", names, indices)
+}
+
+func typeScriptInterface(o *output, root *definition.Root, name string, extends string, discriminator string, fields []*definition.Field) {
+	o.blank()
+	if extends != "" {
+		o.line("export interface %s extends %s {", name, extends)
+	} else {
+		o.line("export interface %s {", name)
+	}
+	o.in()
+	if discriminator != "" {
+		o.line("typeIndex: %s;", discriminator)
+	}
+	typeScriptFields(o, root, fields)
+	o.out()
+	o.line("}")
+}
+
+func typeScriptUnion(o *output, name string, members []string) {
+	o.blank()
+	if len(members) == 0 {
+		o.line("export type %s = never;", name)
+		return
+	}
+	o.line("export type %s = %s;", name, strings.Join(members, " | "))
+}
+
+// WriteTypeScript writes all definitions in root as a TypeScript module. Events and commands
+// get a typeIndex member, so the AnyEvent and AnyCommand unions can be narrowed on it. The unions
+// are not called Event and Command, since that would hide the DOM Event type.
+func WriteTypeScript(writer io.Writer, root *definition.Root) error {
+	o := newOutput(writer, "  ")
+	o.line("// Generated by scrawl-gen. Do not edit.")
+	o.blank()
+	typeScriptSchema(o, root)
+	typeScriptEnums(o, root)
+	typeScriptTypeIndices(o, root)
+
+	for _, userType := range root.UserTypes() {
+		typeScriptInterface(o, root, userType.TypeName(), "", "", userType.Fields())
+	}
+
+	for _, component := range root.ComponentDataTypes() {
+		extends := ""
+		if component.Base() != nil {
+			extends = component.Base().Name()
+		}
+		typeScriptInterface(o, root, component.Name(), extends, "", component.OwnFields())
+	}
+
+	for _, buffer := range root.Buffers() {
+		typeScriptInterface(o, root, buffer.Name(), "", "", buffer.Fields())
+	}
+
+	var eventNames []string
+	for _, event := range root.Events() {
+		typeScriptInterface(o, root, event.Name(), "", "EventTypeIndex."+event.Name(), event.Fields())
+		eventNames = append(eventNames, event.Name())
+	}
+	typeScriptUnion(o, "AnyEvent", eventNames)
+
+	var commandNames []string
+	for _, command := range root.Commands() {
+		typeScriptInterface(o, root, command.Name(), "", "CommandTypeIndex."+command.Name(), command.Fields())
+		commandNames = append(commandNames, command.Name())
+	}
+	typeScriptUnion(o, "AnyCommand", commandNames)
+
+	if o.err != nil {
+		return fmt.Errorf("could not write TypeScript: %v", o.err)
+	}
+
+	return nil
+}
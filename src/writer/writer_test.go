@@ -0,0 +1,73 @@
+/*
+
+MIT License
+
+Copyright (c) 2017 Peter Bjorklund
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+
+*/
+
+package writer
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/piot/scrawl-go/src/definition"
+	"github.com/piot/scrawl-go/src/parser"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in ../test/")
+
+func setupRoot(t *testing.T, filename string) *definition.Root {
+	octets, err := ioutil.ReadFile(filepath.Join("../test/", filename+".test.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, parseErr := parser.NewParser(string(octets), []string{"WorldPosition"}, []string{"WorldPositionComponent"})
+	if parseErr != nil {
+		t.Fatal(parseErr)
+	}
+
+	return p.Root()
+}
+
+func checkGolden(t *testing.T, filename string, generated string) {
+	goldenFilename := filepath.Join("../test/", filename+".out.txt")
+	if *updateGolden {
+		writeErr := ioutil.WriteFile(goldenFilename, []byte(generated), 0644)
+		if writeErr != nil {
+			t.Fatal(writeErr)
+		}
+	}
+
+	expectedOctets, readErr := ioutil.ReadFile(goldenFilename)
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+
+	expected := string(expectedOctets)
+	if expected != generated {
+		t.Errorf("mismatch with %v. Got:\n%v", goldenFilename, generated)
+	}
+}